@@ -23,6 +23,11 @@ import (
 
 // const modelName = "section-generator"
 
+func init() {
+	// Keep embeddings in memory for this instance and in Dgraph across instances and re-ingests
+	utils.SetEmbeddingCache(utils.NewLayeredEmbeddingCache(utils.NewMemoryEmbeddingCache(), dg.NewEmbeddingCache()))
+}
+
 // GetPubMedAccessions queries the PubMed API with MeSH terms and returns a list of PMIDs
 func GetPubMedAccessions(meshTerms string) ([]string, error) {
 	type PubMedSearchResult struct {
@@ -94,9 +99,10 @@ func GetPubMedDetails(meshTerms string) ([]*schemas.MedlineArticle, error) {
 // 	return string(chunksJSON), nil
 // }
 
-func ChunkBasedOnChoice(text string, useAI bool) (string, error) {
+// ChunkBasedOnChoice chunks text with the strategy named in config; empty fields take their defaults
+func ChunkBasedOnChoice(text string, config processors.StrategyConfig) (string, error) {
 	// Calling the function with the parameters
-	chunks, err := processors.ChoiceChunker(text, useAI)
+	chunks, err := processors.ChunkWithStrategy(text, config)
 
 	// Error handling
 	if err != nil {
@@ -112,6 +118,45 @@ func ChunkBasedOnChoice(text string, useAI bool) (string, error) {
 	return string(chunksJSON), nil
 }
 
+// ExtractTablesAndFigures returns only the table and figure caption chunks of text, with each
+// table's rows and columns as JSON in its metadata properties, for data extraction
+func ExtractTablesAndFigures(text string) (string, error) {
+	chunks := processors.ExtractTableChunks(text)
+
+	// Convert chunks to JSON format
+	chunksJSON, err := json.Marshal(chunks)
+	if err != nil {
+		return "", fmt.Errorf("error serializing chunks to JSON: %w", err)
+	}
+
+	return string(chunksJSON), nil
+}
+
+// CleanText cleans text with a named cleaning profile such as "embedding", "display" or "prompt"
+func CleanText(text, profile string) (string, error) {
+	cleaned, err := processors.CleanText(text, profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to clean the text: %w", err)
+	}
+
+	return cleaned, nil
+}
+
+// EmbeddingModels lists the embedding models a research can be embedded with
+func EmbeddingModels() []string {
+	return utils.AvailableEmbedders()
+}
+
+// EmbeddingCacheStats reports how many texts were served from the embedding cache and how many were embedded
+func EmbeddingCacheStats() utils.CacheStats {
+	return utils.EmbeddingCacheStats()
+}
+
+// ChunkingStrategies lists the names accepted in StrategyConfig.Strategy
+func ChunkingStrategies() []string {
+	return processors.AvailableStrategies()
+}
+
 // GetContentSections takes a topic string and content type string, returns a list of sections to cover
 func GetContentSections(topic, contentType string) ([]string, error) {
 	// Clean inputs
@@ -145,7 +190,7 @@ func GetContentSections(topic, contentType string) ([]string, error) {
 }
 
 // RetrieveAndChunk retrieves PubMed details, chunks the articles, and returns a list of JSON strings.
-func RetrieveAndChunk(title string, useAi bool) ([]string, error) {
+func RetrieveAndChunk(title string, config processors.StrategyConfig) ([]string, error) {
 	meshText, err := tools.GenerateAdvancedMeSHKeywords(title)
 	if err != nil {
 		return nil, fmt.Errorf("error generating advanced mesh keywords: %w", err)
@@ -155,7 +200,7 @@ func RetrieveAndChunk(title string, useAi bool) ([]string, error) {
 		return nil, fmt.Errorf("error retrieving articles: %s", err)
 	}
 
-	chunks, err := graph.ChunkAndEmbedManyMedlineRetrievals(articles, useAi)
+	chunks, err := graph.ChunkAndEmbedManyMedlineRetrievals(articles, config)
 	if err != nil {
 		return nil, fmt.Errorf("error chunking the multiple entries: %w", err)
 	}
@@ -173,6 +218,111 @@ func RetrieveAndChunk(title string, useAi bool) ([]string, error) {
 	return jsonStrings, nil
 }
 
+// RetrieveAndStore retrieves the PubMed articles for a title, chunks and embeds them, and stores
+// the articles and the user's chunks in Dgraph, linked to the research when researchID is not
+// empty. Returns the stored uids by PMID and chunk id, with how many nodes of each type were
+// created, updated, left unchanged or removed.
+func RetrieveAndStore(userID, researchID, title string, config processors.StrategyConfig) (*dg.IngestResult, error) {
+	meshText, err := tools.GenerateAdvancedMeSHKeywords(title)
+	if err != nil {
+		return nil, fmt.Errorf("error generating advanced mesh keywords: %w", err)
+	}
+
+	stored, err := graph.AddToDgraph(userID, researchID, meshText, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// SearchChunks returns the user's chunks most similar to the query, best first with their cosine
+// similarity as score. An empty researchID searches all of the user's chunks; expandParents
+// returns section-level parents in place of their sentence-window children.
+func SearchChunks(userID, researchID, query string, topK int, expandParents bool) ([]schemas.TextChunk, error) {
+	chunks, err := graph.RetrieveSimilarChunks(userID, researchID, query, topK, expandParents)
+	if err != nil {
+		return nil, fmt.Errorf("error searching chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// HybridSearch returns the user's chunks best matching the query by fulltext and by embedding,
+// fused by reciprocal rank and narrowed by section, publication type, year and MeSH filters. Each
+// result says which retrievers matched it. An empty researchID searches all of the user's chunks.
+func HybridSearch(userID, researchID, query string, filters schemas.SearchFilters, topK int) ([]schemas.RetrievedChunk, error) {
+	results, err := graph.HybridSearch(userID, researchID, query, filters, topK)
+	if err != nil {
+		return nil, fmt.Errorf("error searching chunks: %w", err)
+	}
+
+	return results, nil
+}
+
+// IngestDocument chunks and embeds an uploaded text, Markdown, HTML or JATS document and stores it
+// under one of the user's researches. Returns the JSON of the stored chunks.
+func IngestDocument(userID, researchID string, document schemas.Document, config processors.StrategyConfig) ([]string, error) {
+	// Embed with the research's model so its chunks stay comparable with each other
+	config, err := graph.WithResearchModel(userID, researchID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, metadata, err := graph.ChunkAndEmbedDocument(document, userID, researchID, config)
+	if err != nil {
+		return nil, fmt.Errorf("error processing document '%s': %w", document.Title, err)
+	}
+
+	predicate, err := graph.EmbeddingPredicate(userID, researchID, config.EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dg.AddDocumentToResearch(userID, researchID, predicate, metadata, chunks); err != nil {
+		return nil, fmt.Errorf("error storing the document: %w", err)
+	}
+
+	// Prepare a list of JSON strings
+	var jsonStrings []string
+	for _, chunk := range chunks {
+		jsonData, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling chunk to JSON: %w", err)
+		}
+		jsonStrings = append(jsonStrings, string(jsonData))
+	}
+
+	return jsonStrings, nil
+}
+
+// StartReembedding starts re-embedding every stored chunk with another embedding model. The new
+// vectors go to a versioned predicate and replace the current ones only once all are written.
+// It needs a token with the admin role.
+func StartReembedding(modelName, cleaningProfile string) (*schemas.ReembedJob, error) {
+	job, err := graph.StartReembedding(modelName, cleaningProfile)
+	if err != nil {
+		return nil, fmt.Errorf("error starting the re-embedding job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ContinueReembedding re-embeds up to pages pages of chunks; call it until the job's status is
+// "swapped". It needs a token with the admin role.
+func ContinueReembedding(jobID string, pages, pageSize int) (*schemas.ReembedJob, error) {
+	job, err := graph.ContinueReembedding(jobID, pages, pageSize)
+	if err != nil {
+		return job, fmt.Errorf("error continuing the re-embedding job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ReembeddingProgress reports how many chunks a re-embedding job has processed out of the total
+func ReembeddingProgress(jobID string) (*schemas.ReembedJob, error) {
+	return graph.ReembeddingProgress(jobID)
+}
+
 // Adds a user to the database
 func Signup(email, name, password string) (*schemas.User, error) {
 	// Hash the password
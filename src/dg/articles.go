@@ -0,0 +1,863 @@
+package dg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"my-modus-app/src/schemas"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// DefaultArticleBatchSize is the number of articles, with their chunks, written per transaction
+const DefaultArticleBatchSize = 20
+
+// Node types stored by StoreMedlineChunks and AddDocumentToResearch, as named by dgraph.type and
+// in the IngestResult report
+const (
+	typeArticle  = "MedlineArticleMetadata"
+	typeDocument = "DocumentMetadata"
+	typeAuthor   = "Author"
+	typeJournal  = "JournalInfo"
+	typeChunk    = "TextChunk"
+)
+
+// upsertPredicates is the predicate each node type is upserted on
+var upsertPredicates = map[string]string{
+	typeArticle:  "MedlineArticleMetadata.pmid",
+	typeDocument: "DocumentMetadata.id",
+	typeAuthor:   "Author.id",
+	typeJournal:  "JournalInfo.id",
+	typeChunk:    "TextChunk.id",
+}
+
+// IngestCounts is how many nodes of one type an ingest created, updated, found unchanged or removed
+type IngestCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+}
+
+// IngestResult maps what was stored to its Dgraph uid and reports what the ingest changed
+type IngestResult struct {
+	Articles  map[string]string        `json:"articles"`            // By PMID
+	Documents map[string]string        `json:"documents,omitempty"` // By DocumentMetadata.id
+	Chunks    map[string]string        `json:"chunks"`              // By TextChunk.id
+	Removed   []string                 `json:"removed,omitempty"`   // TextChunk.id of chunks no longer cut from their source
+	Report    map[string]*IngestCounts `json:"report"`              // By node type
+
+	counted map[string]bool // Nodes already counted, as authors can recur across batches
+}
+
+// newIngestResult returns an empty result with zero counts for every node type
+func newIngestResult() *IngestResult {
+	result := &IngestResult{
+		Articles:  map[string]string{},
+		Documents: map[string]string{},
+		Chunks:    map[string]string{},
+		Report:    map[string]*IngestCounts{},
+		counted:   map[string]bool{},
+	}
+	for nodeType := range upsertPredicates {
+		result.Report[nodeType] = &IngestCounts{}
+	}
+	return result
+}
+
+// count records a node's outcome once, however many batches it appears in
+func (r *IngestResult) count(node *upsertNode) {
+	key := node.nodeType + "\x00" + node.key
+	if r.counted[key] {
+		return
+	}
+	r.counted[key] = true
+
+	counts := r.Report[node.nodeType]
+	switch {
+	case node.existing == nil:
+		counts.Created++
+	case node.unchanged():
+		counts.Unchanged++
+	default:
+		counts.Updated++
+	}
+}
+
+// articleGroup is one article and the chunks cut from it
+type articleGroup struct {
+	metadata *schemas.MedlineArticleMetadata // Nil for chunks without an article
+	chunks   []schemas.TextChunk
+}
+
+// StoreMedlineChunks upserts the articles the chunks were cut from by PMID, their authors and
+// journals, and the user's chunks by TextChunk.id, so running it again updates nodes in place
+// rather than duplicating them. A non-empty researchID links the chunks to that research of the
+// user. The embeddings are written to predicate. Each batch of articles is one transaction.
+func StoreMedlineChunks(userID, researchID, predicate string, chunks []schemas.TextChunk, batchSize int) (*IngestResult, error) {
+	if !predicatePattern.MatchString(predicate) {
+		return nil, fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultArticleBatchSize
+	}
+
+	var research *userResearch
+	if researchID != "" {
+		found, err := findUserResearch(userID, researchID)
+		if err != nil {
+			return nil, err
+		}
+		research = found
+	}
+
+	result := newIngestResult()
+	groups := groupChunksByArticle(chunks)
+	for start := 0; start < len(groups); start += batchSize {
+		batch := groups[start:min(start+batchSize, len(groups))]
+		if err := storeArticleBatch(userID, researchID, research, batch, predicate, result); err != nil {
+			return result, fmt.Errorf("error storing articles %d-%d: %w", start+1, start+len(batch), err)
+		}
+	}
+
+	return result, nil
+}
+
+// groupChunksByArticle groups chunks by PMID, keeping the order articles first appear in
+func groupChunksByArticle(chunks []schemas.TextChunk) []articleGroup {
+	var groups []articleGroup
+	index := map[string]int{}
+	for _, chunk := range chunks {
+		key := ""
+		if chunk.Metadata.MedlineData != nil {
+			key = chunk.Metadata.MedlineData.PMID
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, articleGroup{metadata: chunk.Metadata.MedlineData})
+		}
+		groups[i].chunks = append(groups[i].chunks, chunk)
+	}
+	return groups
+}
+
+// upsertNode is one node of a batch, found through its query var by its upsert predicate
+type upsertNode struct {
+	nodeType string
+	name     string
+	key      string
+	fields   map[string]any // Predicates covered by the checksum, only written when they changed
+	edges    map[string]any // Links to other nodes of the batch, always written
+	checksum string
+	existing *existingNode
+
+	// Set on articles and documents: the authors and journal they link to, to unlink ones they no
+	// longer have, and the chunks cut from them, to remove the user's older chunks
+	authorKeys []string
+	journalKey string
+	chunkKeys  []string
+	// Set on chunks: the metadata node written with a changed chunk
+	metadata map[string]any
+}
+
+// ref points at the node from elsewhere in the mutation
+func (n *upsertNode) ref() map[string]any {
+	return map[string]any{"uid": fmt.Sprintf("uid(%s)", n.name)}
+}
+
+func (n *upsertNode) unchanged() bool {
+	return n.existing != nil && n.existing.Checksum == n.checksum
+}
+
+// addEdge appends target to a list edge of the node
+func (n *upsertNode) addEdge(predicate string, target *upsertNode) {
+	list, _ := n.edges[predicate].([]map[string]any)
+	n.edges[predicate] = append(list, target.ref())
+}
+
+// mutation is the node's JSON: its fields when created or changed, and its edges
+func (n *upsertNode) mutation() map[string]any {
+	node := map[string]any{"uid": n.ref()["uid"]}
+	if !n.unchanged() {
+		for predicate, value := range n.fields {
+			node[predicate] = value
+		}
+		node[n.nodeType+".checksum"] = n.checksum
+		node["dgraph.type"] = n.nodeType
+		if n.metadata != nil {
+			node["TextChunk.metadata"] = n.metadata
+		}
+	}
+	for predicate, value := range n.edges {
+		node[predicate] = value
+	}
+	return node
+}
+
+// existingNode is what a stored node looks like before the batch is written
+type existingNode struct {
+	UID              string   `json:"uid"`
+	Checksum         string   `json:"checksum"`
+	MeshTerms        []string `json:"MedlineArticleMetadata.mesh_terms"`
+	Substances       []string `json:"MedlineArticleMetadata.substances"`
+	PublicationTypes []string `json:"MedlineArticleMetadata.publication_types"`
+	Authors          []struct {
+		UID string `json:"uid"`
+		ID  string `json:"Author.id"`
+	} `json:"MedlineArticleMetadata.authors"`
+	Journal *struct {
+		UID string `json:"uid"`
+		ID  string `json:"JournalInfo.id"`
+	} `json:"MedlineArticleMetadata.journal_info"`
+	DocumentAuthors []struct {
+		UID string `json:"uid"`
+		ID  string `json:"Author.id"`
+	} `json:"DocumentMetadata.authors"`
+	// The user's chunks cut from an article or document, through their metadata
+	SourceChunks []struct {
+		Chunks []storedSourceChunk `json:"chunk"`
+	} `json:"chunks"`
+	Metadata *struct {
+		UID      string `json:"uid"`
+		Entities []struct {
+			UID string `json:"uid"`
+		} `json:"ChunkMetadata.entities"`
+	} `json:"TextChunk.metadata"`
+}
+
+// existingSelections are the extra predicates read for each node type to find stale values
+var existingSelections = map[string]string{
+	typeArticle: "MedlineArticleMetadata.mesh_terms MedlineArticleMetadata.substances MedlineArticleMetadata.publication_types " +
+		"MedlineArticleMetadata.authors { uid Author.id } MedlineArticleMetadata.journal_info { uid JournalInfo.id }",
+	typeDocument: "DocumentMetadata.authors { uid Author.id }",
+	typeChunk:    "TextChunk.metadata { uid ChunkMetadata.entities { uid } }",
+}
+
+// storedSourceChunk is a stored chunk of an article or document, with what removing it involves
+type storedSourceChunk struct {
+	UID      string `json:"uid"`
+	ID       string `json:"TextChunk.id"`
+	Metadata *struct {
+		UID      string `json:"uid"`
+		Entities []struct {
+			UID string `json:"uid"`
+		} `json:"ChunkMetadata.entities"`
+	} `json:"TextChunk.metadata"`
+	Researches []struct {
+		UID string `json:"uid"`
+	} `json:"~Research.associated_chunks"`
+}
+
+// inResearch reports whether the chunk belongs to the research, or to no research when researchUID is empty
+func (c storedSourceChunk) inResearch(researchUID string) bool {
+	if researchUID == "" {
+		return len(c.Researches) == 0
+	}
+	for _, research := range c.Researches {
+		if research.UID == researchUID {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceChunksSelection reads the user's chunks of an article or document, by the reverse edges
+// from their metadata; %s is the metadata predicate linking to the source
+const sourceChunksSelection = "chunks: ~%s { chunk: ~TextChunk.metadata @filter(eq(TextChunk.user_id, $user)) " +
+	"{ uid TextChunk.id TextChunk.metadata { uid ChunkMetadata.entities { uid } } ~Research.associated_chunks { uid } } }"
+
+// upsertBatch collects the nodes of one transaction; a node shared in the batch is added once
+type upsertBatch struct {
+	userID      string // Owner of the batch's chunks
+	researchUID string // Research the batch's chunks belong to, empty for chunks outside a research
+	nodes       []*upsertNode
+	byKey       map[string]*upsertNode
+}
+
+// add returns the batch's node of this type and key, creating it on first use. The checksum
+// covers the fields and anything else that identifies the node's content.
+func (b *upsertBatch) add(nodeType, key string, fields map[string]any, content ...any) *upsertNode {
+	if node, ok := b.byKey[nodeType+"\x00"+key]; ok {
+		return node
+	}
+	fields[upsertPredicates[nodeType]] = key
+	node := &upsertNode{
+		nodeType: nodeType,
+		name:     fmt.Sprintf("n%d", len(b.nodes)),
+		key:      key,
+		fields:   fields,
+		edges:    map[string]any{},
+		checksum: checksum(append([]any{fields}, content...)),
+	}
+	b.nodes = append(b.nodes, node)
+	b.byKey[nodeType+"\x00"+key] = node
+	return node
+}
+
+// variables holds the key of every node as a query variable named after its var
+func (b *upsertBatch) variables() (string, map[string]string) {
+	declarations := make([]string, 0, len(b.nodes))
+	variables := make(map[string]string, len(b.nodes))
+	for _, node := range b.nodes {
+		declarations = append(declarations, "$"+node.name+": string")
+		variables["$"+node.name] = node.key
+	}
+	return strings.Join(declarations, ", "), variables
+}
+
+// upsertQuery declares a var per node for the mutation to reference
+func (b *upsertBatch) upsertQuery() *dgraph.Query {
+	declarations, variables := b.variables()
+	blocks := make([]string, 0, len(b.nodes))
+	for _, node := range b.nodes {
+		blocks = append(blocks, fmt.Sprintf("%s as var(func: eq(%s, $%s))", node.name, upsertPredicates[node.nodeType], node.name))
+	}
+	return &dgraph.Query{
+		Query:     fmt.Sprintf("query upsert(%s) {\n\t%s\n}", declarations, strings.Join(blocks, "\n\t")),
+		Variables: variables,
+	}
+}
+
+// readExisting fills in the stored state of every node that already exists
+func (b *upsertBatch) readExisting() error {
+	declarations, variables := b.variables()
+	declarations += ", $user: string"
+	variables["$user"] = b.userID
+	blocks := make([]string, 0, len(b.nodes))
+	for _, node := range b.nodes {
+		selection := existingSelections[node.nodeType]
+		if predicate, ok := sourcePredicates[node.nodeType]; ok {
+			selection += " " + fmt.Sprintf(sourceChunksSelection, predicate)
+		}
+		blocks = append(blocks, fmt.Sprintf("%s(func: eq(%s, $%s)) { uid checksum: %s.checksum %s }",
+			node.name, upsertPredicates[node.nodeType], node.name, node.nodeType, selection))
+	}
+	query := fmt.Sprintf("query existing(%s) {\n\t%s\n}", declarations, strings.Join(blocks, "\n\t"))
+
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query, Variables: variables},
+	})
+	if err != nil {
+		return fmt.Errorf("error querying the stored nodes: %w", err)
+	}
+
+	var result map[string][]existingNode
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return fmt.Errorf("error parsing the stored nodes: %w", err)
+	}
+	for _, node := range b.nodes {
+		node.existing = nil
+		if found := result[node.name]; len(found) > 0 {
+			node.existing = &found[0]
+		}
+	}
+	return nil
+}
+
+// storeArticleBatch writes one batch of articles and their chunks in a single upsert, linking the
+// chunks to the research when there is one
+func storeArticleBatch(userID, researchID string, research *userResearch, batch []articleGroup, predicate string, result *IngestResult) error {
+	b := &upsertBatch{userID: userID, byKey: map[string]*upsertNode{}}
+	if research != nil {
+		b.researchUID = research.UID
+	}
+	var chunks []schemas.TextChunk
+	var chunkRefs []map[string]any
+	for _, group := range batch {
+		var article *upsertNode
+		if group.metadata != nil && group.metadata.PMID != "" {
+			article = addArticle(b, *group.metadata)
+		}
+
+		for i := range group.chunks {
+			group.chunks[i].UserID = userID
+		}
+		nodes, err := chunkNodes(group.chunks, predicate)
+		if err != nil {
+			return err
+		}
+		for i, node := range nodes {
+			chunk, err := addChunk(b, group.chunks[i].ID, node, article)
+			if err != nil {
+				return err
+			}
+			chunkRefs = append(chunkRefs, chunk.ref())
+		}
+		chunks = append(chunks, group.chunks...)
+	}
+
+	var extra []map[string]any
+	if research != nil && len(chunkRefs) > 0 {
+		researchNode, err := researchLinks(research, researchID, predicate, chunks, chunkRefs)
+		if err != nil {
+			return err
+		}
+		extra = append(extra, researchNode)
+	}
+	return b.write(result, extra)
+}
+
+// write stores the batch in a single upsert with any extra nodes, such as a research linking to
+// the batch's nodes, then records the uids and outcomes in result
+func (b *upsertBatch) write(result *IngestResult, extra []map[string]any) error {
+	if len(b.nodes) == 0 {
+		return nil
+	}
+
+	if err := b.readExisting(); err != nil {
+		return err
+	}
+	// A changed chunk loses the vectors running re-embedding jobs gave it, so they embed it again
+	reembedPredicates, err := runningReembedPredicates()
+	if err != nil {
+		return err
+	}
+
+	var sets, deletes []map[string]any
+	for _, node := range b.nodes {
+		result.count(node)
+		deletes = append(deletes, staleValues(node)...)
+		deletes = append(deletes, staleChunks(node, b.researchUID, result)...)
+		if node.nodeType == typeChunk && node.existing != nil && !node.unchanged() {
+			for _, predicate := range reembedPredicates {
+				deletes = append(deletes, map[string]any{"uid": node.existing.UID, predicate: nil})
+			}
+		}
+
+		// Unchanged chunks are left alone; other nodes may still gain edges
+		if node.nodeType == typeChunk && node.unchanged() {
+			continue
+		}
+		sets = append(sets, node.mutation())
+	}
+	sets = append(sets, extra...)
+
+	// Stale values and the new ones never overlap, so one transaction can hold both
+	request := &dgraph.Request{Query: b.upsertQuery()}
+	if len(deletes) > 0 {
+		data, err := json.Marshal(deletes)
+		if err != nil {
+			return fmt.Errorf("error marshaling stale values to JSON: %w", err)
+		}
+		request.Mutations = append(request.Mutations, &dgraph.Mutation{DelJson: string(data)})
+	}
+	if len(sets) > 0 {
+		data, err := json.Marshal(sets)
+		if err != nil {
+			return fmt.Errorf("error marshaling nodes to JSON: %w", err)
+		}
+		request.Mutations = append(request.Mutations, &dgraph.Mutation{SetJson: string(data)})
+	}
+	if len(request.Mutations) > 0 {
+		if _, err := executor.Execute(request); err != nil {
+			return fmt.Errorf("error executing Dgraph upsert: %w", err)
+		}
+	}
+
+	// Read the uids back, as nodes created through a var are not named in the response
+	if err := b.readExisting(); err != nil {
+		return err
+	}
+	for _, node := range b.nodes {
+		if node.existing == nil {
+			continue
+		}
+		switch node.nodeType {
+		case typeArticle:
+			result.Articles[node.key] = node.existing.UID
+		case typeDocument:
+			result.Documents[node.key] = node.existing.UID
+		case typeChunk:
+			result.Chunks[node.key] = node.existing.UID
+		}
+	}
+	return nil
+}
+
+// addArticle adds an article with its authors and journal to the batch
+func addArticle(b *upsertBatch, metadata schemas.MedlineArticleMetadata) *upsertNode {
+	fields := map[string]any{
+		"MedlineArticleMetadata.title":             metadata.Title,
+		"MedlineArticleMetadata.mesh_terms":        nonNil(metadata.MeshTerms),
+		"MedlineArticleMetadata.substances":        nonNil(metadata.Substances),
+		"MedlineArticleMetadata.publication_types": nonNil(metadata.PublicationTypes),
+		"MedlineArticleMetadata.language":          metadata.Language,
+		"MedlineArticleMetadata.doi":               metadata.DOI,
+		"MedlineArticleMetadata.pubmed_url":        metadata.PubMedURL,
+	}
+	if date := medlineDate(metadata.DateAdded); date != "" {
+		fields["MedlineArticleMetadata.date_added"] = date
+	}
+
+	var authorKeys []string
+	seen := map[string]bool{}
+	source := "pmid:" + metadata.PMID
+	for _, author := range metadata.Authors {
+		if key := authorKey(author, source); key != "" && !seen[key] {
+			seen[key] = true
+			authorKeys = append(authorKeys, key)
+		}
+	}
+	journal := metadata.JournalInfo
+
+	// Which authors and journal an article links to is part of its content
+	article := b.add(typeArticle, metadata.PMID, fields, authorKeys, journalKey(journal))
+	article.authorKeys = authorKeys
+	article.journalKey = journalKey(journal)
+
+	// Authors are shared between articles, identified as authorKey describes
+	linked := map[string]bool{}
+	for _, author := range metadata.Authors {
+		key := authorKey(author, source)
+		if key == "" || linked[key] {
+			continue
+		}
+		linked[key] = true
+		node := b.add(typeAuthor, key, map[string]any{
+			"Author.full_name":   author.FullName,
+			"Author.last_name":   author.LastName,
+			"Author.affiliation": author.Afiliation,
+			"Author.identifier":  author.Identifier,
+		})
+		article.addEdge("MedlineArticleMetadata.authors", node)
+		node.addEdge("Author.medline_metadata", article)
+	}
+
+	// JournalInfo also carries the article's volume, issue and pages, so those are part of its key
+	if article.journalKey != "" {
+		fields := map[string]any{
+			"JournalInfo.abbreviation": journal.Abbreviation,
+			"JournalInfo.full_title":   journal.FullTitle,
+			"JournalInfo.volume":       journal.Volume,
+			"JournalInfo.issue":        journal.Issue,
+			"JournalInfo.pages":        journal.Pages,
+		}
+		if date := medlineDate(journal.Date); date != "" {
+			fields["JournalInfo.date"] = date
+		}
+		node := b.add(typeJournal, article.journalKey, fields)
+		article.edges["MedlineArticleMetadata.journal_info"] = node.ref()
+		node.addEdge("JournalInfo.medline_metadata", article)
+	}
+
+	return article
+}
+
+// sourcePredicates is the metadata predicate linking a chunk to the node type it was cut from
+var sourcePredicates = map[string]string{
+	typeArticle:  "ChunkMetadata.medline_data",
+	typeDocument: "ChunkMetadata.document_data",
+}
+
+// addDocument adds an uploaded document with its authors to the batch
+func addDocument(b *upsertBatch, metadata schemas.DocumentMetadata) *upsertNode {
+	fields := map[string]any{
+		"DocumentMetadata.title":     metadata.Title,
+		"DocumentMetadata.source":    metadata.Source,
+		"DocumentMetadata.mime_type": metadata.MimeType,
+	}
+	if metadata.DateAdded != "" {
+		fields["DocumentMetadata.date_added"] = metadata.DateAdded
+	}
+
+	var authorKeys []string
+	seen := map[string]bool{}
+	source := "document:" + metadata.ID
+	for _, author := range metadata.Authors {
+		if key := authorKey(author, source); key != "" && !seen[key] {
+			seen[key] = true
+			authorKeys = append(authorKeys, key)
+		}
+	}
+
+	// The date added changes with every upload, so it stays out of the checksum
+	checksummed := make(map[string]any, len(fields))
+	for predicate, value := range fields {
+		if predicate != "DocumentMetadata.date_added" {
+			checksummed[predicate] = value
+		}
+	}
+	document := b.add(typeDocument, metadata.ID, fields, authorKeys)
+	document.checksum = checksum([]any{checksummed, authorKeys})
+	document.authorKeys = authorKeys
+
+	linked := map[string]bool{}
+	for _, author := range metadata.Authors {
+		key := authorKey(author, source)
+		if key == "" || linked[key] {
+			continue
+		}
+		linked[key] = true
+		node := b.add(typeAuthor, key, map[string]any{
+			"Author.full_name":   author.FullName,
+			"Author.last_name":   author.LastName,
+			"Author.affiliation": author.Afiliation,
+			"Author.identifier":  author.Identifier,
+		})
+		document.addEdge("DocumentMetadata.authors", node)
+	}
+	return document
+}
+
+// addChunk adds a chunk to the batch with a typed metadata node linked to its article or document.
+// The metadata's timestamp and source link stay out of the checksum, as neither is chunk content.
+func addChunk(b *upsertBatch, id string, chunk map[string]json.RawMessage, source *upsertNode) (*upsertNode, error) {
+	var metadata map[string]any
+	if err := json.Unmarshal(chunk["TextChunk.metadata"], &metadata); err != nil {
+		return nil, fmt.Errorf("error preparing chunk metadata for Dgraph: %w", err)
+	}
+	for _, predicate := range sourcePredicates {
+		delete(metadata, predicate)
+	}
+	timestamp := metadata["ChunkMetadata.timestamp"]
+	delete(metadata, "ChunkMetadata.timestamp")
+
+	fields := make(map[string]any, len(chunk))
+	for key, value := range chunk {
+		if key != "TextChunk.metadata" && key != "dgraph.type" {
+			fields[key] = value
+		}
+	}
+	node := b.add(typeChunk, id, fields, metadata)
+
+	metadata["ChunkMetadata.timestamp"] = timestamp
+	metadata["dgraph.type"] = "ChunkMetadata"
+	if entities, ok := metadata["ChunkMetadata.entities"].([]any); ok {
+		for _, entity := range entities {
+			if entity, ok := entity.(map[string]any); ok {
+				entity["dgraph.type"] = "EntityMention"
+			}
+		}
+	}
+	if source != nil {
+		metadata[sourcePredicates[source.nodeType]] = source.ref()
+		source.chunkKeys = append(source.chunkKeys, id)
+	}
+	node.metadata = metadata
+	return node, nil
+}
+
+// staleValues lists what an updated node no longer has: list values, authors and journal of an
+// article, authors of a document, or the metadata and entity nodes that a chunk's new metadata replaces
+func staleValues(node *upsertNode) []map[string]any {
+	if node.existing == nil || node.unchanged() {
+		return nil
+	}
+	existing := node.existing
+	var deletes []map[string]any
+
+	switch node.nodeType {
+	case typeArticle:
+		// List predicates accumulate values, so the ones the article lost are deleted
+		article := map[string]any{"uid": existing.UID}
+		for predicate, old := range map[string][]string{
+			"MedlineArticleMetadata.mesh_terms":        existing.MeshTerms,
+			"MedlineArticleMetadata.substances":        existing.Substances,
+			"MedlineArticleMetadata.publication_types": existing.PublicationTypes,
+		} {
+			current, _ := node.fields[predicate].([]string)
+			if stale := missingFrom(old, current); len(stale) > 0 {
+				article[predicate] = stale
+			}
+		}
+
+		var staleAuthors []map[string]any
+		for _, author := range existing.Authors {
+			if len(missingFrom([]string{author.ID}, node.authorKeys)) == 0 {
+				continue
+			}
+			staleAuthors = append(staleAuthors, map[string]any{"uid": author.UID})
+			deletes = append(deletes, map[string]any{
+				"uid":                     author.UID,
+				"Author.medline_metadata": []map[string]any{{"uid": existing.UID}},
+			})
+		}
+		if len(staleAuthors) > 0 {
+			article["MedlineArticleMetadata.authors"] = staleAuthors
+		}
+
+		if journal := existing.Journal; journal != nil && journal.ID != node.journalKey {
+			deletes = append(deletes, map[string]any{
+				"uid":                          journal.UID,
+				"JournalInfo.medline_metadata": []map[string]any{{"uid": existing.UID}},
+			})
+			if node.journalKey == "" {
+				article["MedlineArticleMetadata.journal_info"] = map[string]any{"uid": journal.UID}
+			}
+		}
+		if len(article) > 1 {
+			deletes = append(deletes, article)
+		}
+
+	case typeDocument:
+		var staleAuthors []map[string]any
+		for _, author := range existing.DocumentAuthors {
+			if len(missingFrom([]string{author.ID}, node.authorKeys)) > 0 {
+				staleAuthors = append(staleAuthors, map[string]any{"uid": author.UID})
+			}
+		}
+		if len(staleAuthors) > 0 {
+			deletes = append(deletes, map[string]any{"uid": existing.UID, "DocumentMetadata.authors": staleAuthors})
+		}
+
+	case typeChunk:
+		// The changed chunk gets a new metadata node, so the old one and its entities are removed
+		if metadata := existing.Metadata; metadata != nil {
+			deletes = append(deletes, map[string]any{"uid": metadata.UID})
+			for _, entity := range metadata.Entities {
+				deletes = append(deletes, map[string]any{"uid": entity.UID})
+			}
+		}
+	}
+	return deletes
+}
+
+// staleChunks removes the user's stored chunks of an article or document that its new chunks no
+// longer include, with their metadata, entities and research links, and reports them in result.
+// Only chunks of the same research are considered: the source's chunks in another research, or
+// outside any research when researchUID is set, were cut for that research and stay as they are.
+func staleChunks(node *upsertNode, researchUID string, result *IngestResult) []map[string]any {
+	if node.existing == nil {
+		return nil
+	}
+	var deletes []map[string]any
+	for _, metadata := range node.existing.SourceChunks {
+		for _, chunk := range metadata.Chunks {
+			if !chunk.inResearch(researchUID) || len(missingFrom([]string{chunk.ID}, node.chunkKeys)) == 0 {
+				continue
+			}
+			result.Removed = append(result.Removed, chunk.ID)
+			result.Report[typeChunk].Removed++
+
+			// A chunk other researches still link to only leaves this one
+			if len(chunk.Researches) > 1 {
+				deletes = append(deletes, map[string]any{
+					"uid":                        researchUID,
+					"Research.associated_chunks": []map[string]any{{"uid": chunk.UID}},
+				})
+				continue
+			}
+			deletes = append(deletes, map[string]any{"uid": chunk.UID})
+			if chunk.Metadata != nil {
+				deletes = append(deletes, map[string]any{"uid": chunk.Metadata.UID})
+				for _, entity := range chunk.Metadata.Entities {
+					deletes = append(deletes, map[string]any{"uid": entity.UID})
+				}
+			}
+			for _, research := range chunk.Researches {
+				deletes = append(deletes, map[string]any{
+					"uid":                        research.UID,
+					"Research.associated_chunks": []map[string]any{{"uid": chunk.UID}},
+				})
+			}
+		}
+	}
+	return deletes
+}
+
+// checksum hashes the JSON of a node's content; map keys marshal sorted, so it is stable
+func checksum(content any) string {
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// missingFrom returns the values of old that are not in current
+func missingFrom(old, current []string) []string {
+	keep := make(map[string]bool, len(current))
+	for _, value := range current {
+		keep[value] = true
+	}
+	var missing []string
+	for _, value := range old {
+		if !keep[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
+
+// nonNil keeps an empty list an empty JSON array, so it checksums the same as one read back
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// authorKey identifies an author by their AUID, such as an ORCID, when they have one. Names alone
+// are shared by different people, so otherwise the lowercased name is qualified by the affiliation,
+// or failing that by source, the article or document the author is listed on.
+func authorKey(author schemas.Author, source string) string {
+	if id := strings.ReplaceAll(normalizeKey(author.Identifier), " ", ""); id != "" {
+		// ORCIDs are written both bare and as URLs
+		for _, prefix := range []string{"https://orcid.org/", "http://orcid.org/"} {
+			id = strings.Replace(id, prefix, "", 1)
+		}
+		return id
+	}
+	name := author.FullName
+	if name == "" {
+		name = author.LastName
+	}
+	name = normalizeKey(name)
+	if name == "" {
+		return ""
+	}
+	if affiliation := normalizeKey(author.Afiliation); affiliation != "" {
+		return name + "|" + affiliation
+	}
+	return name + "|" + source
+}
+
+// normalizeKey lowercases text and collapses its whitespace
+func normalizeKey(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// journalKey identifies a journal issue and page range
+func journalKey(journal schemas.JournalInfo) string {
+	title := journal.Abbreviation
+	if title == "" {
+		title = journal.FullTitle
+	}
+	if title == "" {
+		return ""
+	}
+	return strings.ToLower(strings.Join([]string{title, journal.Volume, journal.Issue, journal.Pages}, "|"))
+}
+
+// medlineDateLayouts are the MEDLINE date forms, such as DP "2023 Jan 15" and EDAT "2023/01/15 06:00"
+var medlineDateLayouts = []string{
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006 Jan 2",
+	"2006 Jan",
+	"2006",
+}
+
+// medlineDate converts a MEDLINE date to RFC 3339 for Dgraph's datetime predicates. Ranges and
+// seasons such as "2023 Jan-Feb" or "2023 Spring" keep their year; anything else gives "".
+func medlineDate(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	for _, layout := range medlineDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	if fields := strings.Fields(value); len(fields) > 1 {
+		if t, err := time.Parse("2006", fields[0]); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
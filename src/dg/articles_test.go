@@ -0,0 +1,97 @@
+package dg
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"my-modus-app/src/schemas"
+)
+
+func TestAuthorKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		author schemas.Author
+		source string
+		want   string
+	}{
+		{"orcid", schemas.Author{FullName: "Smith, John", Identifier: "ORCID: 0000-0002-1825-0097"}, "pmid:1", "orcid:0000-0002-1825-0097"},
+		{"orcid url", schemas.Author{FullName: "Smith, John", Identifier: "ORCID: https://orcid.org/0000-0002-1825-0097"}, "pmid:2", "orcid:0000-0002-1825-0097"},
+		{"affiliation", schemas.Author{FullName: "Smith,  John", Afiliation: "Dept of Medicine, Oslo"}, "pmid:1", "smith, john|dept of medicine, oslo"},
+		{"name only", schemas.Author{FullName: "Smith, John"}, "pmid:1", "smith, john|pmid:1"},
+		{"last name", schemas.Author{LastName: "Smith J"}, "document:d1", "smith j|document:d1"},
+		{"nameless", schemas.Author{}, "pmid:1", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := authorKey(test.author, test.source); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStaleChunksRemovesChunksMissingFromTheNewSet(t *testing.T) {
+	stored := `{"uid": "0x1", "chunks": [{"chunk": [
+		{"uid": "0x2", "TextChunk.id": "kept", "~Research.associated_chunks": [{"uid": "0x6"}]},
+		{"uid": "0x3", "TextChunk.id": "old", "TextChunk.metadata": {"uid": "0x4", "ChunkMetadata.entities": [{"uid": "0x5"}]},
+		 "~Research.associated_chunks": [{"uid": "0x6"}]},
+		{"uid": "0x7", "TextChunk.id": "shared", "~Research.associated_chunks": [{"uid": "0x6"}, {"uid": "0x8"}]},
+		{"uid": "0x9", "TextChunk.id": "other", "~Research.associated_chunks": [{"uid": "0x8"}]},
+		{"uid": "0xa", "TextChunk.id": "loose"}
+	]}]}`
+
+	tests := []struct {
+		name        string
+		researchUID string
+		wantRemoved []string
+		wantDeleted []string // Chunks, metadata and entities
+		wantUnlink  []string // Chunks unlinked from the research
+	}{
+		{"same research", "0x6", []string{"old", "shared"}, []string{"0x3", "0x4", "0x5"}, []string{"0x3", "0x7"}},
+		{"another research", "0x8", []string{"shared", "other"}, []string{"0x9"}, []string{"0x7", "0x9"}},
+		{"outside a research", "", []string{"loose"}, []string{"0xa"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &upsertBatch{userID: "u1", researchUID: test.researchUID, byKey: map[string]*upsertNode{}}
+			article := addArticle(b, schemas.MedlineArticleMetadata{PMID: "1", Title: "Title"})
+			for _, id := range []string{"kept", "new"} {
+				chunk := map[string]json.RawMessage{"TextChunk.metadata": json.RawMessage(`{}`)}
+				if _, err := addChunk(b, id, chunk, article); err != nil {
+					t.Fatalf("addChunk: %v", err)
+				}
+			}
+			var existing existingNode
+			if err := json.Unmarshal([]byte(stored), &existing); err != nil {
+				t.Fatal(err)
+			}
+			article.existing = &existing
+
+			result := newIngestResult()
+			deletes := staleChunks(article, test.researchUID, result)
+			if fmt.Sprint(result.Removed) != fmt.Sprint(test.wantRemoved) || result.Report[typeChunk].Removed != len(test.wantRemoved) {
+				t.Errorf("removed %v, counted %d; want %v", result.Removed, result.Report[typeChunk].Removed, test.wantRemoved)
+			}
+
+			var deleted, unlinked []string
+			for _, value := range deletes {
+				links, unlink := value["Research.associated_chunks"].([]map[string]any)
+				if !unlink {
+					deleted = append(deleted, value["uid"].(string))
+					continue
+				}
+				if value["uid"] != test.researchUID {
+					t.Errorf("unlinked from research %v, want %s", value["uid"], test.researchUID)
+				}
+				unlinked = append(unlinked, links[0]["uid"].(string))
+			}
+			if fmt.Sprint(deleted) != fmt.Sprint(test.wantDeleted) {
+				t.Errorf("deleted %v, want %v", deleted, test.wantDeleted)
+			}
+			if fmt.Sprint(unlinked) != fmt.Sprint(test.wantUnlink) {
+				t.Errorf("unlinked %v, want %v", unlinked, test.wantUnlink)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package dgtest provides a fake Dgraph for tests of code that stores or reads through package dg
+package dgtest
+
+import (
+	"sync"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// FakeExecutor is an offline dg.Executor that records every request and schema change. Respond
+// answers each request with its JSON result; without it every request gets an empty result.
+type FakeExecutor struct {
+	Respond func(request *dgraph.Request) (string, error)
+
+	mu       sync.Mutex
+	requests []*dgraph.Request
+	schemas  []string
+}
+
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{}
+}
+
+func (f *FakeExecutor) Execute(request *dgraph.Request) (*dgraph.Response, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	respond := f.Respond
+	f.mu.Unlock()
+
+	if respond == nil {
+		return &dgraph.Response{Json: "{}"}, nil
+	}
+	result, err := respond(request)
+	if err != nil {
+		return nil, err
+	}
+	return &dgraph.Response{Json: result}, nil
+}
+
+func (f *FakeExecutor) AlterSchema(schema string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schemas = append(f.schemas, schema)
+	return nil
+}
+
+// Requests returns a copy of the requests received so far
+func (f *FakeExecutor) Requests() []*dgraph.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*dgraph.Request(nil), f.requests...)
+}
+
+// Mutations returns every mutation of the requests received so far, in order
+func (f *FakeExecutor) Mutations() []*dgraph.Mutation {
+	var mutations []*dgraph.Mutation
+	for _, request := range f.Requests() {
+		mutations = append(mutations, request.Mutations...)
+	}
+	return mutations
+}
+
+// Schemas returns the schema changes received so far
+func (f *FakeExecutor) Schemas() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.schemas...)
+}
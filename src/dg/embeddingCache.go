@@ -0,0 +1,113 @@
+package dg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// EmbeddingCache persists embeddings in Dgraph, one EmbeddingCache node per content-addressed key.
+// It implements utils.EmbeddingCache.
+type EmbeddingCache struct{}
+
+func NewEmbeddingCache() *EmbeddingCache {
+	return &EmbeddingCache{}
+}
+
+// embeddingCacheEntry is the stored form; the vector is kept as JSON since it is never searched
+type embeddingCacheEntry struct {
+	UID       string   `json:"uid,omitempty"`
+	Key       string   `json:"EmbeddingCache.key"`
+	Embedding string   `json:"EmbeddingCache.embedding"`
+	DType     []string `json:"dgraph.type,omitempty"`
+}
+
+// quoteKeys renders keys as a DQL string list; keys are hex hashes, so they need no escaping
+func quoteKeys(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = fmt.Sprintf("%q", key)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func (c *EmbeddingCache) GetMany(keys []string) (map[string][]float32, error) {
+	found := make(map[string][]float32)
+	if len(keys) == 0 {
+		return found, nil
+	}
+
+	query := fmt.Sprintf(`{
+		entries(func: eq(EmbeddingCache.key, %s)) {
+			EmbeddingCache.key
+			EmbeddingCache.embedding
+		}
+	}`, quoteKeys(keys))
+
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying the embedding cache: %w", err)
+	}
+
+	var result struct {
+		Entries []embeddingCacheEntry `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing the embedding cache query: %w", err)
+	}
+
+	for _, entry := range result.Entries {
+		var embedding []float32
+		if err := json.Unmarshal([]byte(entry.Embedding), &embedding); err != nil {
+			continue // A corrupt entry is a miss and gets overwritten
+		}
+		found[entry.Key] = embedding
+	}
+	return found, nil
+}
+
+// SetMany upserts the entries so a key is stored on one node however often it is written
+func (c *EmbeddingCache) SetMany(entries map[string][]float32) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString("{\n")
+	var mutations []*dgraph.Mutation
+	i := 0
+	for key, embedding := range entries {
+		vector, err := json.Marshal(embedding)
+		if err != nil {
+			return fmt.Errorf("error marshaling embedding to JSON: %w", err)
+		}
+		data, err := json.Marshal(embeddingCacheEntry{
+			UID:       fmt.Sprintf("uid(entry%d)", i),
+			Key:       key,
+			Embedding: string(vector),
+			DType:     []string{"EmbeddingCache"},
+		})
+		if err != nil {
+			return fmt.Errorf("error marshaling cache entry to JSON: %w", err)
+		}
+
+		fmt.Fprintf(&query, "\tentry%d as var(func: eq(EmbeddingCache.key, %q))\n", i, key)
+		mutations = append(mutations, &dgraph.Mutation{SetJson: string(data)})
+		i++
+	}
+	query.WriteString("}")
+
+	// Execute the Dgraph upsert
+	_, err := executor.Execute(&dgraph.Request{
+		Query:     &dgraph.Query{Query: query.String()},
+		Mutations: mutations,
+	})
+	if err != nil {
+		return fmt.Errorf("error executing Dgraph mutation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package dg_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/dg/dgtest"
+	"my-modus-app/src/utils"
+	"my-modus-app/src/utils/utilstest"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// fakeCacheStore keeps the EmbeddingCache nodes upserted through the fake executor and answers
+// key lookups from them
+type fakeCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]string
+	lookups int
+}
+
+var cacheKeyPattern = regexp.MustCompile(`"([0-9a-f]{64})"`)
+
+func (s *fakeCacheStore) respond(request *dgraph.Request) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, mutation := range request.Mutations {
+		var entry struct {
+			Key       string `json:"EmbeddingCache.key"`
+			Embedding string `json:"EmbeddingCache.embedding"`
+		}
+		if err := json.Unmarshal([]byte(mutation.SetJson), &entry); err != nil {
+			return "", err
+		}
+		s.entries[entry.Key] = entry.Embedding
+	}
+	if len(request.Mutations) > 0 || !strings.Contains(request.Query.Query, "entries(func: eq(EmbeddingCache.key") {
+		return `{}`, nil
+	}
+
+	s.lookups++
+	var entries []map[string]string
+	for _, match := range cacheKeyPattern.FindAllStringSubmatch(request.Query.Query, -1) {
+		if embedding, ok := s.entries[match[1]]; ok {
+			entries = append(entries, map[string]string{"EmbeddingCache.key": match[1], "EmbeddingCache.embedding": embedding})
+		}
+	}
+	data, err := json.Marshal(map[string]any{"entries": entries})
+	return string(data), err
+}
+
+func TestLayeredEmbeddingCacheReadsDgraphAfterRestart(t *testing.T) {
+	store := &fakeCacheStore{entries: map[string]string{}}
+	fake := dgtest.NewFakeExecutor()
+	fake.Respond = store.respond
+	dg.SetExecutor(fake)
+	defer dg.SetExecutor(nil)
+
+	// Each embedder has its own memory layer, as after a restart, over the shared Dgraph cache
+	newEmbedder := func(model *utilstest.HashEmbedder) *utils.CachedEmbedder {
+		return utils.NewCachedEmbedder(utils.NewLayeredEmbeddingCache(utils.NewMemoryEmbeddingCache(), dg.NewEmbeddingCache()), model)
+	}
+	modelA := utilstest.NewHashEmbedder("model-a", 16)
+
+	first := newEmbedder(modelA)
+	if _, err := first.Embed("Metformin lowers glucose.", "Knee surgery recovery."); err != nil {
+		t.Fatal(err)
+	}
+	if got := first.Stats(); got != (utils.CacheStats{Misses: 2}) {
+		t.Errorf("first embedder stats %+v, want 2 misses", got)
+	}
+	if len(store.entries) != 2 {
+		t.Fatalf("stored %d cache nodes, want 2", len(store.entries))
+	}
+
+	// Whitespace differences hit the stored entry; the memory layer then answers without Dgraph
+	restarted := newEmbedder(modelA)
+	for i := 0; i < 2; i++ {
+		if _, err := restarted.Embed("Metformin  lowers\nglucose."); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := restarted.Stats(); got != (utils.CacheStats{Hits: 2}) {
+		t.Errorf("restarted embedder stats %+v, want 2 hits", got)
+	}
+	if modelA.Calls() != 1 {
+		t.Errorf("model-a called %d times, want once for the first embedder", modelA.Calls())
+	}
+	if store.lookups != 2 {
+		t.Errorf("%d Dgraph lookups, want one per embedder", store.lookups)
+	}
+
+	// Another model never reads model-a's vectors
+	modelB := utilstest.NewHashEmbedder("model-b", 16)
+	other := newEmbedder(modelB)
+	if _, err := other.Embed("Metformin lowers glucose."); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Stats(); got != (utils.CacheStats{Misses: 1}) || modelB.Calls() != 1 {
+		t.Errorf("model-b stats %+v after %d calls, want a miss sent to the model", got, modelB.Calls())
+	}
+	if len(store.entries) != 3 {
+		t.Errorf("stored %d cache nodes, want model-b's vector stored separately", len(store.entries))
+	}
+}
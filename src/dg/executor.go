@@ -0,0 +1,31 @@
+package dg
+
+import "github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+
+// Executor runs Dgraph requests. The Modus host connection is the default; tests substitute a
+// fake such as dgtest.FakeExecutor.
+type Executor interface {
+	Execute(request *dgraph.Request) (*dgraph.Response, error)
+	AlterSchema(schema string) error
+}
+
+// hostExecutor sends requests to the Dgraph connection named hostName in modus.json
+type hostExecutor struct{}
+
+func (hostExecutor) Execute(request *dgraph.Request) (*dgraph.Response, error) {
+	return dgraph.Execute(hostName, request)
+}
+
+func (hostExecutor) AlterSchema(schema string) error {
+	return dgraph.AlterSchema(hostName, schema)
+}
+
+var executor Executor = hostExecutor{}
+
+// SetExecutor replaces where Dgraph requests go; nil restores the Modus host
+func SetExecutor(e Executor) {
+	if e == nil {
+		e = hostExecutor{}
+	}
+	executor = e
+}
@@ -0,0 +1,36 @@
+package dg
+
+import (
+	"testing"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+
+	"my-modus-app/src/dg/dgtest"
+)
+
+func TestHostExecutorCallsTheHost(t *testing.T) {
+	// A fake in the executor var must stay untouched: the host executor goes straight to Modus
+	fake := dgtest.NewFakeExecutor()
+	SetExecutor(fake)
+	defer SetExecutor(nil)
+
+	request := &dgraph.Request{Query: &dgraph.Query{Query: "{ q(func: has(User.id)) { uid } }"}}
+	if _, err := (hostExecutor{}).Execute(request); err != nil {
+		t.Fatal(err)
+	}
+	if err := (hostExecutor{}).AlterSchema("User.id: string @index(exact) ."); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Requests()) != 0 || len(fake.Schemas()) != 0 {
+		t.Errorf("host executor re-entered the executor var: %d requests, %d schemas", len(fake.Requests()), len(fake.Schemas()))
+	}
+
+	for name, calls := range map[string][]any{
+		"query":  dgraph.DgraphQueryCallStack.Pop(),
+		"schema": dgraph.DgraphAlterSchemaCallStack.Pop(),
+	} {
+		if len(calls) == 0 || *calls[0].(*string) != hostName {
+			t.Errorf("%s was not sent to the %q connection: %v", name, hostName, calls)
+		}
+	}
+}
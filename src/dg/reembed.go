@@ -0,0 +1,402 @@
+package dg
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"my-modus-app/src/schemas"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// embeddingVersionName identifies the single EmbeddingVersion node for chunk embeddings
+const embeddingVersionName = "TextChunk"
+
+// embeddingIndex is the HNSW index every embedding predicate is declared with, so similar_to can use it
+const embeddingIndex = `@index(hnsw(metric:"cosine"))`
+
+var (
+	// uidPattern guards uids that are written into queries rather than passed as variables
+	uidPattern = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	// predicatePattern limits versioned predicates to TextChunk.embedding followed by a safe suffix
+	predicatePattern = regexp.MustCompile(`^TextChunk\.embedding(_[A-Za-z0-9_]+)?$`)
+	// predicateSuffixUnsafe matches characters that cannot appear in a predicate name suffix
+	predicateSuffixUnsafe = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// ModelEmbeddingPredicate is the predicate of a model's own vectors, for chunks embedded with another
+// model than the active embedding version's. One HNSW index only holds vectors of one dimension.
+func ModelEmbeddingPredicate(model string) string {
+	suffix := strings.Trim(predicateSuffixUnsafe.ReplaceAllString(model, "_"), "_")
+	return schemas.DefaultEmbeddingPredicate + "_" + suffix
+}
+
+// StoredChunk is the part of a stored chunk needed to re-embed it
+type StoredChunk struct {
+	UID      string `json:"uid"`
+	ID       string `json:"TextChunk.id"`
+	Content  string `json:"TextChunk.content"`
+	Metadata struct {
+		OverlapLength int    `json:"ChunkMetadata.overlap_length"`
+		ChunkType     string `json:"ChunkMetadata.chunk_type"`
+	} `json:"TextChunk.metadata"`
+}
+
+// GetActiveEmbeddingVersion returns the predicate holding the embeddings in use, which is
+// TextChunk.embedding until a re-embedding job has swapped in another
+func GetActiveEmbeddingVersion() (*schemas.EmbeddingVersion, error) {
+	query := `
+		query version($name: string) {
+			version(func: eq(EmbeddingVersion.name, $name)) {
+				uid
+				EmbeddingVersion.name
+				EmbeddingVersion.predicate
+				EmbeddingVersion.model
+				EmbeddingVersion.dimension
+				EmbeddingVersion.updated_at
+			}
+		}
+	`
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query, Variables: map[string]string{"$name": embeddingVersionName}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying the embedding version: %w", err)
+	}
+
+	var result struct {
+		Version []schemas.EmbeddingVersion `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing the embedding version: %w", err)
+	}
+	if len(result.Version) == 0 || result.Version[0].Predicate == "" {
+		return &schemas.EmbeddingVersion{Name: embeddingVersionName, Predicate: schemas.DefaultEmbeddingPredicate}, nil
+	}
+	return &result.Version[0], nil
+}
+
+// EnsureEmbeddingPredicate declares a versioned vector predicate and its HNSW index before vectors are written to it
+func EnsureEmbeddingPredicate(predicate string) error {
+	if !predicatePattern.MatchString(predicate) {
+		return fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	if err := executor.AlterSchema(predicate + ": float32vector " + embeddingIndex + " ."); err != nil {
+		return fmt.Errorf("error declaring predicate %s: %w", predicate, err)
+	}
+	return nil
+}
+
+// missingEmbeddingVars declares missing as the chunks, other than section-level parents, that have
+// no vector in predicate: those not reached yet and those written or changed after a job passed them
+func missingEmbeddingVars(predicate string) string {
+	return fmt.Sprintf(`parentMetadata as var(func: eq(ChunkMetadata.chunk_type, "parent"))
+		var(func: uid(parentMetadata)) { parents as ~TextChunk.metadata }
+		missing as var(func: has(TextChunk.content)) @filter(NOT has(%s) AND NOT uid(parents))`, predicate)
+}
+
+// SwapEmbeddingVersion points the chunk embeddings at version.Predicate and moves every research
+// and the metadata of every chunk embedded there to the new model and predicate, in a single transaction. The
+// swap only happens while every chunk has a vector in the new predicate; it reports whether it did.
+func SwapEmbeddingVersion(version schemas.EmbeddingVersion) (bool, error) {
+	if !predicatePattern.MatchString(version.Predicate) {
+		return false, fmt.Errorf("invalid embedding predicate %q", version.Predicate)
+	}
+	version.UID = "uid(version)"
+	version.Name = embeddingVersionName
+	version.DType = []string{"EmbeddingVersion"}
+	data, err := json.Marshal(version)
+	if err != nil {
+		return false, fmt.Errorf("error marshaling embedding version to JSON: %w", err)
+	}
+	model, err := json.Marshal(version.Model)
+	if err != nil {
+		return false, fmt.Errorf("error marshaling model name: %w", err)
+	}
+	predicate, err := json.Marshal(version.Predicate)
+	if err != nil {
+		return false, fmt.Errorf("error marshaling predicate name: %w", err)
+	}
+	metadata, err := json.Marshal(map[string]any{
+		"uid":                               "uid(metadata)",
+		"ChunkMetadata.embedding_model":     version.Model,
+		"ChunkMetadata.embedding_dimension": version.Dimension,
+	})
+	if err != nil {
+		return false, fmt.Errorf("error marshaling chunk metadata to JSON: %w", err)
+	}
+
+	query := fmt.Sprintf(`{
+		version as var(func: eq(EmbeddingVersion.name, %q))
+		research as var(func: has(Research.id))
+		var(func: has(%s)) { metadata as TextChunk.metadata }
+		%s
+	}`, embeddingVersionName, version.Predicate, missingEmbeddingVars(version.Predicate))
+
+	// A chunk changed since the last check leaves the condition false and nothing is written
+	condition := "@if(eq(len(missing), 0))"
+	_, err = executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query},
+		Mutations: []*dgraph.Mutation{
+			{SetJson: string(data), Condition: condition},
+			{SetJson: string(metadata), Condition: condition},
+			{SetNquads: fmt.Sprintf("uid(research) <Research.embedding_model> %s .\nuid(research) <Research.embedding_predicate> %s .", model, predicate), Condition: condition},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error swapping the embedding version: %w", err)
+	}
+
+	active, err := GetActiveEmbeddingVersion()
+	if err != nil {
+		return false, err
+	}
+	return active.Predicate == version.Predicate, nil
+}
+
+// CountChunks returns the number of stored chunks
+func CountChunks() (int, error) {
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: `{ chunks(func: has(TextChunk.content)) { total: count(uid) } }`},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error counting chunks: %w", err)
+	}
+
+	var result struct {
+		Chunks []struct {
+			Total int `json:"total"`
+		} `json:"chunks"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return 0, fmt.Errorf("error parsing the chunk count: %w", err)
+	}
+	if len(result.Chunks) == 0 {
+		return 0, nil
+	}
+	return result.Chunks[0].Total, nil
+}
+
+// ListChunkPage returns up to first stored chunks in uid order after the given uid; an empty
+// after starts from the beginning
+func ListChunkPage(after string, first int) ([]StoredChunk, error) {
+	pagination := fmt.Sprintf("first: %d", first)
+	if after != "" {
+		if !uidPattern.MatchString(after) {
+			return nil, fmt.Errorf("invalid cursor %q", after)
+		}
+		pagination += ", after: " + after
+	}
+
+	query := fmt.Sprintf(`{
+		chunks(func: has(TextChunk.content), %s) {
+			uid
+			TextChunk.id
+			TextChunk.content
+			TextChunk.metadata {
+				ChunkMetadata.overlap_length
+				ChunkMetadata.chunk_type
+			}
+		}
+	}`, pagination)
+
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying chunks: %w", err)
+	}
+
+	var result struct {
+		Chunks []StoredChunk `json:"chunks"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing chunks: %w", err)
+	}
+	return result.Chunks, nil
+}
+
+// ListChunksMissingEmbedding returns up to first chunks, other than section-level parents, without
+// a vector in predicate
+func ListChunksMissingEmbedding(predicate string, first int) ([]StoredChunk, error) {
+	if !predicatePattern.MatchString(predicate) {
+		return nil, fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+
+	query := fmt.Sprintf(`{
+		%s
+		chunks(func: uid(missing), first: %d) {
+			uid
+			TextChunk.id
+			TextChunk.content
+			TextChunk.metadata {
+				ChunkMetadata.overlap_length
+				ChunkMetadata.chunk_type
+			}
+		}
+	}`, missingEmbeddingVars(predicate), first)
+
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying chunks: %w", err)
+	}
+
+	var result struct {
+		Chunks []StoredChunk `json:"chunks"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing chunks: %w", err)
+	}
+	return result.Chunks, nil
+}
+
+// runningReembedPredicates returns the predicates of the re-embedding jobs still running
+func runningReembedPredicates() ([]string, error) {
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{
+			Query:     `query jobs($status: string) { jobs(func: eq(ReembedJob.status, $status)) { ReembedJob.predicate } }`,
+			Variables: map[string]string{"$status": schemas.ReembedRunning},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying the re-embedding jobs: %w", err)
+	}
+
+	var result struct {
+		Jobs []schemas.ReembedJob `json:"jobs"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing the re-embedding jobs: %w", err)
+	}
+	var predicates []string
+	for _, job := range result.Jobs {
+		if predicatePattern.MatchString(job.Predicate) {
+			predicates = append(predicates, job.Predicate)
+		}
+	}
+	return predicates, nil
+}
+
+// SetChunkEmbeddings writes vectors by chunk uid to the given embedding predicate
+func SetChunkEmbeddings(predicate string, vectors map[string][]float32) error {
+	if !predicatePattern.MatchString(predicate) {
+		return fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	nodes := make([]map[string]interface{}, 0, len(vectors))
+	for uid, vector := range vectors {
+		nodes = append(nodes, map[string]interface{}{"uid": uid, predicate: vector})
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("error marshaling embeddings to JSON: %w", err)
+	}
+
+	// Execute the Dgraph mutation
+	_, err = executor.Execute(&dgraph.Request{
+		Mutations: []*dgraph.Mutation{
+			{
+				SetJson: string(data),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error executing Dgraph mutation: %w", err)
+	}
+	return nil
+}
+
+// SaveReembedJob creates or updates a job by its ID
+func SaveReembedJob(job *schemas.ReembedJob) error {
+	job.UID = "uid(job)"
+	job.DType = []string{"ReembedJob"}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error marshaling job to JSON: %w", err)
+	}
+
+	_, err = executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{
+			Query:     `query job($id: string) { job as var(func: eq(ReembedJob.id, $id)) }`,
+			Variables: map[string]string{"$id": job.ID},
+		},
+		Mutations: []*dgraph.Mutation{
+			{
+				SetJson: string(data),
+			},
+		},
+	})
+	job.UID = ""
+	if err != nil {
+		return fmt.Errorf("error saving the re-embedding job: %w", err)
+	}
+	return nil
+}
+
+// GetReembedJob loads a job by its ID
+func GetReembedJob(id string) (*schemas.ReembedJob, error) {
+	query := `
+		query job($id: string) {
+			job(func: eq(ReembedJob.id, $id)) {
+				ReembedJob.id
+				ReembedJob.model
+				ReembedJob.predicate
+				ReembedJob.cleaning_profile
+				ReembedJob.cursor
+				ReembedJob.processed
+				ReembedJob.total
+				ReembedJob.dimension
+				ReembedJob.status
+				ReembedJob.error
+				ReembedJob.started_at
+				ReembedJob.updated_at
+			}
+		}
+	`
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query, Variables: map[string]string{"$id": id}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying the re-embedding job: %w", err)
+	}
+
+	var result struct {
+		Job []schemas.ReembedJob `json:"job"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing the re-embedding job: %w", err)
+	}
+	if len(result.Job) == 0 {
+		return nil, fmt.Errorf("re-embedding job %s not found", id)
+	}
+	return &result.Job[0], nil
+}
+
+// chunkNodes marshals chunks for a mutation, writing each embedding to the active predicate
+func chunkNodes(chunks []schemas.TextChunk, predicate string) ([]map[string]json.RawMessage, error) {
+	nodes := make([]map[string]json.RawMessage, 0, len(chunks))
+	for _, chunk := range chunks {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling chunk to JSON: %w", err)
+		}
+		var node map[string]json.RawMessage
+		if err := json.Unmarshal(data, &node); err != nil {
+			return nil, fmt.Errorf("error preparing chunk for Dgraph: %w", err)
+		}
+		if predicate != schemas.DefaultEmbeddingPredicate {
+			node[predicate] = node[schemas.DefaultEmbeddingPredicate]
+			delete(node, schemas.DefaultEmbeddingPredicate)
+		}
+		node["dgraph.type"] = json.RawMessage(`["TextChunk"]`)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
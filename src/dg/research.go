@@ -18,7 +18,7 @@ func AddResearchAsJSON(research *schemas.Research) (map[string]string, error) {
 	}
 
 	// Execute the Dgraph mutation
-	response, err := dgraph.Execute(hostName, &dgraph.Request{
+	response, err := executor.Execute(&dgraph.Request{
 		Mutations: []*dgraph.Mutation{
 			{
 				SetJson: string(data),
@@ -31,3 +31,130 @@ func AddResearchAsJSON(research *schemas.Research) (map[string]string, error) {
 
 	return response.Uids, nil
 }
+
+// userResearch is a research node found for its owner
+type userResearch struct {
+	UID                string `json:"uid"`
+	EmbeddingModel     string `json:"Research.embedding_model"`
+	EmbeddingPredicate string `json:"Research.embedding_predicate"`
+}
+
+// findUserResearch looks up a research, only if it belongs to the user
+func findUserResearch(userID, researchID string) (*userResearch, error) {
+	query := `
+		query research($research: string, $user: string) {
+			research(func: eq(Research.id, $research)) @cascade(Research.user) {
+				uid
+				Research.embedding_model
+				Research.embedding_predicate
+				Research.user @filter(eq(User.id, $user)) {
+					uid
+				}
+			}
+		}
+	`
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{
+			Query:     query,
+			Variables: map[string]string{"$research": researchID, "$user": userID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying the research: %w", err)
+	}
+
+	var result struct {
+		Research []userResearch `json:"research"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing the research query: %w", err)
+	}
+	if len(result.Research) == 0 {
+		return nil, fmt.Errorf("research %s not found for user %s", researchID, userID)
+	}
+	return &result.Research[0], nil
+}
+
+// GetResearchEmbedding returns the embedding model chosen for a research and the predicate its
+// vectors are stored in, both "" before its first ingest
+func GetResearchEmbedding(userID, researchID string) (model, predicate string, err error) {
+	research, err := findUserResearch(userID, researchID)
+	if err != nil {
+		return "", "", err
+	}
+	return research.EmbeddingModel, research.EmbeddingPredicate, nil
+}
+
+// AddDocumentToResearch upserts an uploaded document by DocumentMetadata.id and the user's chunks
+// by TextChunk.id under a research owned by the user, so uploading the same document again updates
+// it in place. The embeddings are written to predicate. The first document fixes the research's
+// embedding model and predicate; later ones must use the same.
+func AddDocumentToResearch(userID, researchID, predicate string, document schemas.DocumentMetadata, chunks []schemas.TextChunk) (*IngestResult, error) {
+	if !predicatePattern.MatchString(predicate) {
+		return nil, fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	research, err := findUserResearch(userID, researchID)
+	if err != nil {
+		return nil, err
+	}
+	if document.ID == "" {
+		return nil, fmt.Errorf("document '%s' has no id", document.Title)
+	}
+
+	b := &upsertBatch{userID: userID, researchUID: research.UID, byKey: map[string]*upsertNode{}}
+	documentNode := addDocument(b, document)
+	for i := range chunks {
+		chunks[i].UserID = userID
+	}
+	nodes, err := chunkNodes(chunks, predicate)
+	if err != nil {
+		return nil, err
+	}
+	var chunkRefs []map[string]any
+	for i, node := range nodes {
+		chunk, err := addChunk(b, chunks[i].ID, node, documentNode)
+		if err != nil {
+			return nil, err
+		}
+		chunkRefs = append(chunkRefs, chunk.ref())
+	}
+
+	// Link the document and its chunks to the research in the same transaction
+	researchNode, err := researchLinks(research, researchID, predicate, chunks, chunkRefs)
+	if err != nil {
+		return nil, err
+	}
+	researchNode["Research.documents"] = []map[string]any{documentNode.ref()}
+
+	result := newIngestResult()
+	if err := b.write(result, []map[string]any{researchNode}); err != nil {
+		return result, fmt.Errorf("error storing document %s: %w", document.ID, err)
+	}
+	return result, nil
+}
+
+// researchLinks is the research node linking to the chunks through refs. The first embedded chunk
+// fixes the research's embedding model and the predicate its vectors are written to, which every
+// other chunk must match.
+func researchLinks(research *userResearch, researchID, predicate string, chunks []schemas.TextChunk, refs []map[string]any) (map[string]any, error) {
+	node := map[string]any{"uid": research.UID, "Research.associated_chunks": refs}
+	for _, chunk := range chunks {
+		model := chunk.Metadata.EmbeddingModel
+		if model == "" {
+			continue
+		}
+		if research.EmbeddingModel != "" && model != research.EmbeddingModel {
+			return nil, fmt.Errorf("research %s uses embedding model %s, not %s", researchID, research.EmbeddingModel, model)
+		}
+		if research.EmbeddingPredicate != "" && predicate != research.EmbeddingPredicate {
+			return nil, fmt.Errorf("research %s stores its embeddings in %s, not %s", researchID, research.EmbeddingPredicate, predicate)
+		}
+		if research.EmbeddingModel == "" {
+			research.EmbeddingModel = model
+			research.EmbeddingPredicate = predicate
+			node["Research.embedding_model"] = model
+			node["Research.embedding_predicate"] = predicate
+		}
+	}
+	return node, nil
+}
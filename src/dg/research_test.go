@@ -0,0 +1,100 @@
+package dg_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/dg/dgtest"
+	"my-modus-app/src/schemas"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+func TestAddDocumentToResearchUpsertsDocumentAndLinksChunks(t *testing.T) {
+	fake := dgtest.NewFakeExecutor()
+	fake.Respond = func(request *dgraph.Request) (string, error) {
+		if request.Query != nil && strings.Contains(request.Query.Query, "research(func: eq(Research.id") {
+			return `{"research": [{"uid": "0x10"}]}`, nil
+		}
+		return `{}`, nil
+	}
+	dg.SetExecutor(fake)
+	defer dg.SetExecutor(nil)
+
+	document := schemas.DocumentMetadata{ID: "doc-1", Title: "Protocol"}
+	chunks := []schemas.TextChunk{
+		{ID: "c1", Content: "First part.", Embedding: []float32{1, 0}, Metadata: schemas.ChunkMetadata{EmbeddingModel: "model-a", DocumentData: &document}},
+		{ID: "c2", Content: "Second part.", Embedding: []float32{0, 1}, Metadata: schemas.ChunkMetadata{EmbeddingModel: "model-a", DocumentData: &document}},
+	}
+	predicate := dg.ModelEmbeddingPredicate("model-a")
+	if _, err := dg.AddDocumentToResearch("user-1", "research-1", predicate, document, chunks); err != nil {
+		t.Fatal(err)
+	}
+
+	var upsert *dgraph.Request
+	for _, request := range fake.Requests() {
+		if len(request.Mutations) > 0 {
+			upsert = request
+		}
+	}
+	if upsert == nil || !strings.Contains(upsert.Query.Query, "eq(DocumentMetadata.id, $n0)") {
+		t.Fatalf("document not upserted by its id: %+v", upsert)
+	}
+
+	var nodes []map[string]any
+	if err := json.Unmarshal([]byte(upsert.Mutations[len(upsert.Mutations)-1].SetJson), &nodes); err != nil {
+		t.Fatal(err)
+	}
+	var research map[string]any
+	for _, node := range nodes {
+		if node["uid"] == "0x10" {
+			research = node
+		}
+		if metadata, ok := node["TextChunk.metadata"].(map[string]any); ok {
+			if node[predicate] == nil {
+				t.Errorf("chunk %v has no vector in %s", node["TextChunk.id"], predicate)
+			}
+			if ref, _ := metadata["ChunkMetadata.document_data"].(map[string]any); ref["uid"] != "uid(n0)" {
+				t.Errorf("chunk %v not linked to the upserted document", node["TextChunk.id"])
+			}
+		}
+	}
+	if research == nil {
+		t.Fatal("research node not in the upsert")
+	}
+	if links, _ := research["Research.associated_chunks"].([]any); len(links) != 2 {
+		t.Errorf("research links %d chunks, want 2", len(links))
+	}
+	if research["Research.embedding_model"] != "model-a" || research["Research.embedding_predicate"] != predicate {
+		t.Errorf("research model %v in %v, want the chunks' model in %s",
+			research["Research.embedding_model"], research["Research.embedding_predicate"], predicate)
+	}
+}
+
+func TestAddDocumentToResearchKeepsTheResearchPredicate(t *testing.T) {
+	fake := dgtest.NewFakeExecutor()
+	fake.Respond = func(request *dgraph.Request) (string, error) {
+		if request.Query != nil && strings.Contains(request.Query.Query, "research(func: eq(Research.id") {
+			return `{"research": [{"uid": "0x10", "Research.embedding_model": "model-a",
+				"Research.embedding_predicate": "TextChunk.embedding_model_a"}]}`, nil
+		}
+		return `{}`, nil
+	}
+	dg.SetExecutor(fake)
+	defer dg.SetExecutor(nil)
+
+	document := schemas.DocumentMetadata{ID: "doc-1", Title: "Protocol"}
+	chunks := []schemas.TextChunk{
+		{ID: "c1", Content: "First part.", Embedding: []float32{1, 0}, Metadata: schemas.ChunkMetadata{EmbeddingModel: "model-a", DocumentData: &document}},
+	}
+	if _, err := dg.AddDocumentToResearch("user-1", "research-1", schemas.DefaultEmbeddingPredicate, document, chunks); err == nil {
+		t.Error("expected an error writing the research's vectors to another predicate")
+	}
+	for _, request := range fake.Requests() {
+		if len(request.Mutations) > 0 {
+			t.Fatalf("mutation sent for a rejected document: %+v", request.Mutations)
+		}
+	}
+}
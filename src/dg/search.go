@@ -0,0 +1,292 @@
+package dg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"my-modus-app/src/schemas"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+const (
+	// searchOversampling is how many nearest neighbours are first fetched per result wanted, as the
+	// user and research filters apply after similar_to has picked its candidates
+	searchOversampling = 10
+	// maxSearchCandidates bounds the fulltext matches fetched for one search
+	maxSearchCandidates = 1000
+)
+
+// chunkSelection reads a stored chunk with its metadata; the embedding is selected separately
+// as it lives in the active embedding predicate
+const chunkSelection = `
+	uid
+	TextChunk.id
+	TextChunk.user_id
+	TextChunk.content
+	TextChunk.metadata {
+		ChunkMetadata.start_index
+		ChunkMetadata.end_index
+		ChunkMetadata.overlap_length
+		ChunkMetadata.section
+		ChunkMetadata.chunk_type
+		ChunkMetadata.properties
+		ChunkMetadata.parent_id
+		ChunkMetadata.child_ids
+		ChunkMetadata.citations
+		ChunkMetadata.cited_references
+		ChunkMetadata.keywords
+		ChunkMetadata.entity_types
+		ChunkMetadata.timestamp
+		ChunkMetadata.confidence
+		ChunkMetadata.embedding_model
+		ChunkMetadata.embedding_dimension
+		ChunkMetadata.entities {
+			EntityMention.text
+			EntityMention.type
+			EntityMention.normalized
+			EntityMention.start_index
+			EntityMention.end_index
+			EntityMention.source
+		}
+		ChunkMetadata.medline_data {
+			MedlineArticleMetadata.pmid
+			MedlineArticleMetadata.title
+			MedlineArticleMetadata.mesh_terms
+			MedlineArticleMetadata.substances
+			MedlineArticleMetadata.publication_types
+			MedlineArticleMetadata.language
+			MedlineArticleMetadata.date_added
+			MedlineArticleMetadata.doi
+			MedlineArticleMetadata.pubmed_url
+			MedlineArticleMetadata.authors {
+				Author.full_name
+				Author.last_name
+				Author.affiliation
+			}
+			MedlineArticleMetadata.journal_info {
+				JournalInfo.abbreviation
+				JournalInfo.full_title
+				JournalInfo.volume
+				JournalInfo.issue
+				JournalInfo.pages
+				JournalInfo.date
+			}
+		}
+		ChunkMetadata.document_data {
+			DocumentMetadata.id
+			DocumentMetadata.title
+			DocumentMetadata.source
+			DocumentMetadata.mime_type
+			DocumentMetadata.date_added
+			DocumentMetadata.authors {
+				Author.full_name
+				Author.last_name
+				Author.affiliation
+			}
+		}
+	}`
+
+// storedChunk is a chunk as read back, with its embedding under the alias "embedding"
+type storedChunk struct {
+	schemas.TextChunk
+	Vector vectorJSON `json:"embedding"`
+}
+
+// vectorJSON reads a float32vector, which Dgraph may return as an array or as a string holding one
+type vectorJSON []float32
+
+func (v *vectorJSON) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		data = []byte(text)
+	}
+	var vector []float32
+	if err := json.Unmarshal(data, &vector); err != nil {
+		return fmt.Errorf("invalid vector: %w", err)
+	}
+	*v = vector
+	return nil
+}
+
+// toChunks returns the chunks with their embeddings in TextChunk.Embedding
+func toChunks(stored []storedChunk) []schemas.TextChunk {
+	chunks := make([]schemas.TextChunk, len(stored))
+	for i, chunk := range stored {
+		chunks[i] = chunk.TextChunk
+		chunks[i].Embedding = chunk.Vector
+	}
+	return chunks
+}
+
+// researchScope returns a var block collecting the research's chunks and the filter clause
+// limiting a search to them, or empty strings when researchID is empty
+func researchScope(userID, researchID string) (string, string, error) {
+	if researchID == "" {
+		return "", "", nil
+	}
+	research, err := findUserResearch(userID, researchID)
+	if err != nil {
+		return "", "", err
+	}
+	if !uidPattern.MatchString(research.UID) {
+		return "", "", fmt.Errorf("invalid research uid %q", research.UID)
+	}
+	scope := fmt.Sprintf("var(func: uid(%s)) { scoped as Research.associated_chunks }", research.UID)
+	return scope, " AND uid(scoped)", nil
+}
+
+// SearchChunks returns up to k of the user's chunks nearest to vector through the HNSW index of
+// the embedding predicate. A non-empty researchID limits the search to the chunks of that research.
+// similar_to picks its nearest neighbours before the user and research filters apply, so the
+// neighbours fetched are doubled until k of them pass or the index has no more. Scores and order
+// are left for the caller to compute.
+func SearchChunks(userID, researchID, predicate string, vector []float32, k int) ([]schemas.TextChunk, error) {
+	if !predicatePattern.MatchString(predicate) {
+		return nil, fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	scope, filter, err := researchScope(userID, researchID)
+	if err != nil {
+		return nil, err
+	}
+
+	vectorText, err := json.Marshal(vector)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling the query vector: %w", err)
+	}
+
+	for candidates := k * searchOversampling; ; candidates *= 2 {
+		query := fmt.Sprintf(`
+			query search($vector: float32vector, $user: string) {
+				%s
+				nearest as var(func: similar_to(%s, %d, $vector))
+				fetched(func: uid(nearest)) { count: count(uid) }
+				chunks(func: uid(nearest)) @filter(eq(TextChunk.user_id, $user)%s) {
+					%s
+					embedding: %s
+				}
+			}
+		`, scope, predicate, candidates, filter, chunkSelection, predicate)
+
+		response, err := executor.Execute(&dgraph.Request{
+			Query: &dgraph.Query{
+				Query:     query,
+				Variables: map[string]string{"$vector": string(vectorText), "$user": userID},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error searching chunks: %w", err)
+		}
+
+		var result struct {
+			Fetched []struct {
+				Count int `json:"count"`
+			} `json:"fetched"`
+			Chunks []storedChunk `json:"chunks"`
+		}
+		if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+			return nil, fmt.Errorf("error parsing the chunk search: %w", err)
+		}
+		fetched := 0
+		if len(result.Fetched) > 0 {
+			fetched = result.Fetched[0].Count
+		}
+		if len(result.Chunks) >= k || fetched < candidates {
+			return toChunks(result.Chunks), nil
+		}
+	}
+}
+
+// SearchChunksText returns up to limit of the user's chunks whose content matches any word of the
+// query through the fulltext index, with their embeddings from predicate. Fulltext matches are
+// unranked, so the caller ranks them. A non-empty researchID limits the search to that research.
+func SearchChunksText(userID, researchID, predicate, text string, limit int) ([]schemas.TextChunk, error) {
+	if !predicatePattern.MatchString(predicate) {
+		return nil, fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	if limit <= 0 || strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	scope, filter, err := researchScope(userID, researchID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		query search($text: string, $user: string) {
+			%s
+			chunks(func: anyoftext(TextChunk.content, $text), first: %d) @filter(eq(TextChunk.user_id, $user)%s) {
+				%s
+				embedding: %s
+			}
+		}
+	`, scope, min(limit, maxSearchCandidates), filter, chunkSelection, predicate)
+
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{
+			Query:     query,
+			Variables: map[string]string{"$text": text, "$user": userID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching chunk text: %w", err)
+	}
+
+	var result struct {
+		Chunks []storedChunk `json:"chunks"`
+	}
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing the chunk text search: %w", err)
+	}
+	return toChunks(result.Chunks), nil
+}
+
+// GetChunksByID returns the user's chunks with the given TextChunk.id values, such as the parents
+// of search results, with their embeddings from predicate
+func GetChunksByID(userID, predicate string, ids []string) ([]schemas.TextChunk, error) {
+	if !predicatePattern.MatchString(predicate) {
+		return nil, fmt.Errorf("invalid embedding predicate %q", predicate)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	declarations := []string{"$user: string"}
+	variables := map[string]string{"$user": userID}
+	blocks := make([]string, 0, len(ids))
+	for i, id := range ids {
+		name := fmt.Sprintf("c%d", i)
+		declarations = append(declarations, "$"+name+": string")
+		variables["$"+name] = id
+		blocks = append(blocks, fmt.Sprintf("%s(func: eq(TextChunk.id, $%s)) @filter(eq(TextChunk.user_id, $user)) { %s\n embedding: %s }",
+			name, name, chunkSelection, predicate))
+	}
+	query := fmt.Sprintf("query chunks(%s) {\n%s\n}", strings.Join(declarations, ", "), strings.Join(blocks, "\n"))
+
+	response, err := executor.Execute(&dgraph.Request{
+		Query: &dgraph.Query{Query: query, Variables: variables},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying chunks: %w", err)
+	}
+
+	var result map[string][]storedChunk
+	if err := json.Unmarshal([]byte(response.Json), &result); err != nil {
+		return nil, fmt.Errorf("error parsing chunks: %w", err)
+	}
+	var chunks []schemas.TextChunk
+	for i := range ids {
+		chunks = append(chunks, toChunks(result[fmt.Sprintf("c%d", i)])...)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,75 @@
+package dg_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/dg/dgtest"
+	"my-modus-app/src/schemas"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// rankedIndex answers similar_to searches over owners, the users of the chunks in index order,
+// applying the user filter after picking the nearest like Dgraph does
+func rankedIndex(owners []string) func(request *dgraph.Request) (string, error) {
+	candidates := regexp.MustCompile(`similar_to\([^,]+, (\d+),`)
+	return func(request *dgraph.Request) (string, error) {
+		n, _ := strconv.Atoi(candidates.FindStringSubmatch(request.Query.Query)[1])
+		nearest := owners[:min(n, len(owners))]
+		var chunks []map[string]any
+		for i, owner := range nearest {
+			if owner == request.Query.Variables["$user"] {
+				chunks = append(chunks, map[string]any{"TextChunk.id": strconv.Itoa(i), "TextChunk.user_id": owner, "embedding": []float32{1, 0}})
+			}
+		}
+		data, err := json.Marshal(map[string]any{"fetched": []map[string]int{{"count": len(nearest)}}, "chunks": chunks})
+		return string(data), err
+	}
+}
+
+func TestSearchChunksWidensUntilEnoughOfTheUsersChunks(t *testing.T) {
+	// The user's 5 chunks rank after 75 chunks of other users, with 200 more behind them
+	var owners []string
+	for i := 0; i < 280; i++ {
+		owner := "other"
+		if i >= 75 && i < 80 {
+			owner = "user-1"
+		}
+		owners = append(owners, owner)
+	}
+
+	tests := []struct {
+		name         string
+		user         string
+		k            int
+		wantChunks   int
+		wantRequests int
+	}{
+		{"widened to k hits", "user-1", 3, 5, 3},   // 30, 60, then 120 neighbours
+		{"index exhausted", "user-1", 10, 5, 3},    // 100, 200, then 400 of the 280 neighbours
+		{"user without chunks", "user-2", 3, 0, 5}, // 30 to 480 neighbours
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := dgtest.NewFakeExecutor()
+			fake.Respond = rankedIndex(owners)
+			dg.SetExecutor(fake)
+			defer dg.SetExecutor(nil)
+
+			chunks, err := dg.SearchChunks(test.user, "", schemas.DefaultEmbeddingPredicate, []float32{1, 0}, test.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != test.wantChunks {
+				t.Errorf("found %d chunks, want %d", len(chunks), test.wantChunks)
+			}
+			if len(fake.Requests()) != test.wantRequests {
+				t.Errorf("sent %d searches, want %d", len(fake.Requests()), test.wantRequests)
+			}
+		})
+	}
+}
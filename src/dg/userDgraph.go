@@ -21,7 +21,7 @@ func AddUserAsJSON(user *schemas.User) (map[string]string, error) {
 	}
 
 	// Execute the Dgraph mutation
-	response, err := dgraph.Execute(hostName, &dgraph.Request{
+	response, err := executor.Execute(&dgraph.Request{
 		Mutations: []*dgraph.Mutation{
 			{
 				SetJson: string(data),
@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"my-modus-app/src/dg"
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+)
+
+// ChunkRetriever is where chunk searches read stored chunks from. Dgraph is the default;
+// vectorindex.LocalChunkStore serves the same searches in process for local development.
+type ChunkRetriever interface {
+	// SearchChunks returns up to k of the user's chunks nearest to vector, with their embeddings.
+	// A non-empty researchID limits the search to that research.
+	SearchChunks(userID, researchID string, vector []float32, k int) ([]schemas.TextChunk, error)
+	// LexicalSearch returns up to k of the user's chunks matching words of the query, best first with
+	// their BM25 score. A non-empty researchID limits the search to that research.
+	LexicalSearch(userID, researchID, query string, k int) ([]schemas.TextChunk, error)
+	// ChunksByID returns the user's chunks with the given ids, with their embeddings
+	ChunksByID(userID string, ids []string) ([]schemas.TextChunk, error)
+	// EmbeddingModel returns the model queries must be embedded with to compare with the chunks
+	EmbeddingModel(userID, researchID string) (string, error)
+}
+
+// lexicalOversampling is how many fulltext matches are fetched per lexical result wanted, so
+// BM25 can rank more than Dgraph's arbitrary first few
+const lexicalOversampling = 10
+
+var chunkRetriever ChunkRetriever = DgraphRetriever{}
+
+// SetChunkRetriever replaces the store chunk searches read from; nil restores Dgraph
+func SetChunkRetriever(retriever ChunkRetriever) {
+	if retriever == nil {
+		retriever = DgraphRetriever{}
+	}
+	chunkRetriever = retriever
+}
+
+// DgraphRetriever searches the HNSW index of the research's embedding predicate in Dgraph, or of
+// the active one outside a research
+type DgraphRetriever struct{}
+
+// searchPredicate is the predicate holding the vectors a search compares with
+func searchPredicate(userID, researchID string) (string, error) {
+	if researchID != "" {
+		_, predicate, err := dg.GetResearchEmbedding(userID, researchID)
+		if err != nil {
+			return "", err
+		}
+		if predicate != "" {
+			return predicate, nil
+		}
+	}
+	version, err := dg.GetActiveEmbeddingVersion()
+	if err != nil {
+		return "", err
+	}
+	return version.Predicate, nil
+}
+
+func (DgraphRetriever) SearchChunks(userID, researchID string, vector []float32, k int) ([]schemas.TextChunk, error) {
+	predicate, err := searchPredicate(userID, researchID)
+	if err != nil {
+		return nil, err
+	}
+	return dg.SearchChunks(userID, researchID, predicate, vector, k)
+}
+
+// LexicalSearch ranks the fulltext matches with BM25. Dgraph does not score fulltext hits, so
+// term statistics come from the matches fetched rather than from every stored chunk.
+func (DgraphRetriever) LexicalSearch(userID, researchID, query string, k int) ([]schemas.TextChunk, error) {
+	predicate, err := searchPredicate(userID, researchID)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := dg.SearchChunksText(userID, researchID, predicate, query, k*lexicalOversampling)
+	if err != nil {
+		return nil, err
+	}
+	return rankLexically(query, matches, k), nil
+}
+
+// rankLexically returns up to k of the chunks best matching the query by BM25, with Score set
+func rankLexically(query string, chunks []schemas.TextChunk, k int) []schemas.TextChunk {
+	index := processors.NewBM25Index()
+	byID := make(map[string]schemas.TextChunk, len(chunks))
+	for _, chunk := range chunks {
+		index.Add(chunk.ID, chunk.Content)
+		byID[chunk.ID] = chunk
+	}
+
+	results := index.Search(query, k, nil)
+	ranked := make([]schemas.TextChunk, 0, len(results))
+	for _, result := range results {
+		chunk := byID[result.ID]
+		chunk.Score = result.Score
+		ranked = append(ranked, chunk)
+	}
+	return ranked
+}
+
+func (DgraphRetriever) ChunksByID(userID string, ids []string) ([]schemas.TextChunk, error) {
+	version, err := dg.GetActiveEmbeddingVersion()
+	if err != nil {
+		return nil, err
+	}
+	return dg.GetChunksByID(userID, version.Predicate, ids)
+}
+
+// EmbeddingModel returns the research's model, or else the model of the active embedding version
+func (DgraphRetriever) EmbeddingModel(userID, researchID string) (string, error) {
+	if researchID != "" {
+		model, _, err := dg.GetResearchEmbedding(userID, researchID)
+		if err != nil {
+			return "", err
+		}
+		if model != "" {
+			return model, nil
+		}
+	}
+	version, err := dg.GetActiveEmbeddingVersion()
+	if err != nil {
+		return "", err
+	}
+	return version.Model, nil
+}
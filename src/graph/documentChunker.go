@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+)
+
+// ChunkAndEmbedDocument chunks and embeds an uploaded document. Its MIME type picks the parser
+// unless config names a strategy explicitly, and every chunk records the document, not MEDLINE.
+// Chunk IDs are scoped to the user's research, like those of ingested articles.
+func ChunkAndEmbedDocument(document schemas.Document, userID, researchID string, config processors.StrategyConfig) ([]schemas.TextChunk, schemas.DocumentMetadata, error) {
+	if strings.TrimSpace(document.Content) == "" {
+		return nil, schemas.DocumentMetadata{}, fmt.Errorf("document '%s' has no content", document.Title)
+	}
+	// Without an id, a document is identified by its content so uploading it again updates it in place
+	if document.ID == "" {
+		sum := sha256.Sum256([]byte(document.Title + "\x00" + document.Content))
+		document.ID = hex.EncodeToString(sum[:16])
+	}
+	metadata := schemas.ConvertDocumentToMetadata(document, time.Now().UTC().Format(time.RFC3339))
+
+	// Let the MIME type choose the parser when no strategy was asked for
+	strategy, err := processors.StrategyForMimeType(document.MimeType)
+	if err != nil {
+		return nil, metadata, err
+	}
+	if config.Strategy == "" || config.Strategy == processors.StrategyAuto {
+		config.Strategy = strategy
+	}
+
+	// Uploaded documents carry no MeSH headings or substances; the dictionary still finds species and outcomes
+	chunks, err := prepareChunks(document.Content, config, nil, nil)
+	if err != nil {
+		return nil, metadata, fmt.Errorf("error chunking the document: %w", err)
+	}
+	processors.AnnotateKeywords(chunks, nil, nil)
+	if err := embedChunks(chunks, config); err != nil {
+		return nil, metadata, err
+	}
+
+	for i := range chunks {
+		chunks[i].UserID = userID
+		chunks[i].Metadata.DocumentData = &metadata
+	}
+	processors.AssignContentIDs(chunks, processors.ChunkScope(userID, researchID))
+
+	return chunks, metadata, nil
+}
@@ -1,182 +1,100 @@
 package graph
 
-// import (
-// 	"encoding/json"
-// 	"fmt"
-// 	"my-modus-app/src/schemas"
-// 	"my-modus-app/src/utils"
-// 	"time"
-
-// 	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
-// )
-
-// const dgraphConn = "dg-conn"
-
-// // AddToDgraph processes articles and adds them to Dgraph
-// func AddToDgraph(meshText string, useAi bool) (map[string]string, error) {
-// 	articles, err := utils.GetPubMedDetails(meshText)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error retrieving articles: %s", err)
-// 	}
-
-// 	chunks, err := ChunkAndEmbedManyMedlineRetrievals(articles, useAi)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error chunking the multiple entries: %w", err)
-// 	}
-
-// 	uids := make(map[string]string)
-// 	for _, chunk := range chunks {
-// 		// Create the Dgraph mutation
-// 		mutation, err := createDgraphMutation(chunk)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("error creating mutation for chunk: %w", err)
-// 		}
-
-// 		// Execute the mutation
-// 		chunkUids, err := AddChunkWithMutation(mutation)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("error adding chunk to Dgraph: %w", err)
-// 		}
-
-// 		// Merge the UIDs
-// 		for k, v := range chunkUids {
-// 			uids[k] = v
-// 		}
-// 	}
-
-// 	return uids, nil
-// }
-
-// // createDgraphMutation creates a properly formatted mutation for Dgraph
-// func createDgraphMutation(chunk schemas.TextChunk) (string, error) {
-// 	// Create the Dgraph-specific structure
-// 	dgraphChunk := struct {
-// 		Uid       string         `json:"uid,omitempty"`
-// 		Type      string         `json:"dgraph.type,omitempty"`
-// 		schemas.TextChunk
-// 	}{
-// 		Type:      "TextChunk",
-// 		TextChunk: chunk,
-// 	}
-
-// 	// Convert timestamp to RFC3339 format
-// 	if !chunk.Metadata.Timestamp.IsZero() {
-// 		dgraphChunk.Metadata.Timestamp = chunk.Metadata.Timestamp.Format(time.RFC3339)
-// 	}
-
-// 	// Marshal to JSON
-// 	data, err := json.Marshal(dgraphChunk)
-// 	if err != nil {
-// 		return "", fmt.Errorf("error marshaling chunk: %w", err)
-// 	}
-
-// 	return string(data), nil
-// }
-
-// // AddChunkWithMutation executes a Dgraph mutation
-// func AddChunkWithMutation(mutation string) (map[string]string, error) {
-// 	// Create the mutation request
-// 	request := &dgraph.Request{
-// 		Mutations: []*dgraph.Mutation{
-// 			{
-// 				SetJson: mutation,
-// 			},
-// 		},
-// 		CommitNow: true,
-// 	}
-
-// 	// Execute the mutation
-// 	response, err := dgraph.Execute(dgraphConn, request)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error executing Dgraph mutation: %w", err)
-// 	}
-
-// 	return response.Uids, nil
-// }
-
-// // AddChunksInBatch adds multiple chunks in a single transaction
-// func AddChunksInBatch(chunks []schemas.TextChunk) (map[string]string, error) {
-// 	var mutations []string
-// 	for _, chunk := range chunks {
-// 		mutation, err := createDgraphMutation(chunk)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("error creating mutation for chunk: %w", err)
-// 		}
-// 		mutations = append(mutations, mutation)
-// 	}
-
-// 	// Create batch mutation request
-// 	var dgraphMutations []*dgraph.Mutation
-// 	for _, mut := range mutations {
-// 		dgraphMutations = append(dgraphMutations, &dgraph.Mutation{
-// 			SetJson: mut,
-// 		})
-// 	}
-
-// 	request := &dgraph.Request{
-// 		Mutations: dgraphMutations,
-// 		CommitNow: true,
-// 	}
-
-// 	// Execute batch mutation
-// 	response, err := dgraph.Execute(dgraphConn, request)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error executing batch mutation: %w", err)
-// 	}
-
-// 	return response.Uids, nil
-// }
-
-// // QueryChunks retrieves chunks based on criteria
-// func QueryChunks(userId string, keywords []string) ([]schemas.TextChunk, error) {
-// 	// Construct the DQL query
-// 	query := `
-// 		query chunks($userId: string, $keywords: string) {
-// 			chunks(func: type(TextChunk)) @filter(
-// 				eq(userId, $userId) AND anyofterms(keywords, $keywords)
-// 			) {
-// 				id
-// 				userId
-// 				content
-// 				score
-// 				metadata {
-// 					expand(_all_)
-// 					medlineData {
-// 						expand(_all_)
-// 						authors {
-// 							expand(_all_)
-// 						}
-// 					}
-// 				}
-// 				relations {
-// 					expand(_all_)
-// 				}
-// 			}
-// 		}
-// 	`
-
-// 	// Create variables map
-// 	vars := map[string]string{
-// 		"$userId":   userId,
-// 		"$keywords": strings.Join(keywords, " "),
-// 	}
-
-// 	// Execute query
-// 	resp, err := dgraph.Execute(dgraphConn, &dgraph.Request{
-// 		Query: query,
-// 		Vars:  vars,
-// 	})
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error executing query: %w", err)
-// 	}
-
-// 	// Parse response
-// 	var result struct {
-// 		Chunks []schemas.TextChunk `json:"chunks"`
-// 	}
-// 	if err := json.Unmarshal(resp.Json, &result); err != nil {
-// 		return nil, fmt.Errorf("error unmarshaling response: %w", err)
-// 	}
-
-// 	return result.Chunks, nil
-// }
+import (
+	"fmt"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/processors"
+	"my-modus-app/src/utils"
+)
+
+// AddToDgraph retrieves the PubMed articles matching the MeSH query, chunks and embeds them, and
+// stores the articles, their authors and journals and the user's chunks in Dgraph. A non-empty
+// researchID links the chunks to that research, embedded with its model. Running it again for the
+// same articles updates the stored nodes instead of adding new ones.
+func AddToDgraph(userID, researchID, meshText string, config processors.StrategyConfig) (*dg.IngestResult, error) {
+	config, err := WithResearchModel(userID, researchID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	articles, err := utils.GetPubMedDetails(meshText)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving articles: %w", err)
+	}
+
+	chunks, err := ChunkAndEmbedManyMedlineRetrievals(articles, config)
+	if err != nil {
+		return nil, fmt.Errorf("error chunking the multiple entries: %w", err)
+	}
+
+	// Chunk IDs hash the user, research, article and content so a repeated ingest finds the same chunks
+	processors.AssignContentIDs(chunks, processors.ChunkScope(userID, researchID))
+
+	predicate, err := EmbeddingPredicate(userID, researchID, config.EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := dg.StoreMedlineChunks(userID, researchID, predicate, chunks, dg.DefaultArticleBatchSize)
+	if err != nil {
+		return stored, fmt.Errorf("error adding chunks to Dgraph: %w", err)
+	}
+
+	return stored, nil
+}
+
+// WithResearchModel sets config to embed with the research's model, so its chunks stay comparable
+// with each other. An empty researchID or a research without chunks yet leaves config as it is.
+func WithResearchModel(userID, researchID string, config processors.StrategyConfig) (processors.StrategyConfig, error) {
+	if researchID == "" {
+		return config, nil
+	}
+	researchModel, _, err := dg.GetResearchEmbedding(userID, researchID)
+	if err != nil {
+		return config, fmt.Errorf("error looking up the research: %w", err)
+	}
+	if researchModel != "" {
+		if config.EmbeddingModel != "" && config.EmbeddingModel != researchModel {
+			return config, fmt.Errorf("research %s uses embedding model %s, not %s", researchID, researchModel, config.EmbeddingModel)
+		}
+		config.EmbeddingModel = researchModel
+	}
+	return config, nil
+}
+
+// EmbeddingPredicate returns the Dgraph predicate for the vectors of the research's chunks embedded
+// with model, "" being the default model: the research's own once it has one, the active embedding
+// version's for that version's model, or else the model's own predicate, declared on first use.
+// Each predicate's HNSW index thus only holds vectors of one model and dimension.
+func EmbeddingPredicate(userID, researchID, model string) (string, error) {
+	if researchID != "" {
+		_, predicate, err := dg.GetResearchEmbedding(userID, researchID)
+		if err != nil {
+			return "", fmt.Errorf("error looking up the research: %w", err)
+		}
+		if predicate != "" {
+			return predicate, nil
+		}
+	}
+
+	version, err := dg.GetActiveEmbeddingVersion()
+	if err != nil {
+		return "", err
+	}
+	if model == "" {
+		model = utils.DefaultEmbeddingModel
+	}
+	activeModel := version.Model
+	if activeModel == "" {
+		activeModel = utils.DefaultEmbeddingModel
+	}
+	if model == activeModel {
+		return version.Predicate, nil
+	}
+
+	predicate := dg.ModelEmbeddingPredicate(model)
+	if err := dg.EnsureEmbeddingPredicate(predicate); err != nil {
+		return "", err
+	}
+	return predicate, nil
+}
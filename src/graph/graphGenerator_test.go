@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/dg/dgtest"
+	"my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+func TestEmbeddingPredicateKeepsOneModelPerPredicate(t *testing.T) {
+	tests := []struct {
+		name         string
+		research     string // Research node the fake returns
+		model        string
+		want         string
+		wantDeclared bool
+	}{
+		{"default model", `{"uid": "0x10"}`, "", schemas.DefaultEmbeddingPredicate, false},
+		{"active model by name", `{"uid": "0x10"}`, utils.DefaultEmbeddingModel, schemas.DefaultEmbeddingPredicate, false},
+		{"other model", `{"uid": "0x10"}`, utils.MiniLMModel, dg.ModelEmbeddingPredicate(utils.MiniLMModel), true},
+		{"research predicate", `{"uid": "0x10", "Research.embedding_model": "minilm",
+			"Research.embedding_predicate": "TextChunk.embedding_minilm"}`, utils.MiniLMModel, "TextChunk.embedding_minilm", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := dgtest.NewFakeExecutor()
+			fake.Respond = func(request *dgraph.Request) (string, error) {
+				if strings.Contains(request.Query.Query, "research(func: eq(Research.id") {
+					return `{"research": [` + test.research + `]}`, nil
+				}
+				return `{}`, nil
+			}
+			dg.SetExecutor(fake)
+			defer dg.SetExecutor(nil)
+
+			predicate, err := EmbeddingPredicate("user-1", "research-1", test.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if predicate != test.want {
+				t.Errorf("predicate %s, want %s", predicate, test.want)
+			}
+			if declared := len(fake.Schemas()) > 0; declared != test.wantDeclared {
+				t.Errorf("predicate declared %v, want %v: %v", declared, test.wantDeclared, fake.Schemas())
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+)
+
+const (
+	// rrfK damps the lead of the top ranks in reciprocal rank fusion; 60 is the value from the
+	// original RRF paper and works without tuning
+	rrfK = 60
+	// hybridOversampling is how many candidates each retriever returns per result wanted, so
+	// filters and fusion have enough to choose from
+	hybridOversampling = 5
+)
+
+// HybridSearch returns the user's top k chunks for the query, fusing BM25 fulltext matches and
+// vector matches by reciprocal rank fusion. Filters apply to both retrievers' candidates before
+// ranking. Each result records which retrievers matched it and its rank and score in each.
+func HybridSearch(userID, researchID, query string, filters schemas.SearchFilters, topK int) ([]schemas.RetrievedChunk, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	if topK <= 0 {
+		topK = DefaultTopK
+	}
+
+	// Step 1: Retrieve the candidates passing the filters from both retrievers
+	lexical, vector, err := filteredCandidates(userID, researchID, query, filters, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 2: Fuse the rankings, each chunk scoring 1/(rrfK + rank) per retriever
+	results := make(map[string]*schemas.RetrievedChunk)
+	var order []string
+	fuse := func(retriever string, ranked []schemas.TextChunk) {
+		for i, chunk := range ranked {
+			rank := i + 1
+			result, ok := results[chunk.ID]
+			if !ok {
+				result = &schemas.RetrievedChunk{Chunk: chunk}
+				results[chunk.ID] = result
+				order = append(order, chunk.ID)
+			}
+			result.Score += 1 / float64(rrfK+rank)
+			result.MatchedBy = append(result.MatchedBy, retriever)
+			if retriever == schemas.RetrieverLexical {
+				result.LexicalRank, result.LexicalScore = rank, chunk.Score
+			} else {
+				result.VectorRank, result.VectorScore = rank, chunk.Score
+				// Keep the embedding, which lexical matches may lack
+				result.Chunk.Embedding = chunk.Embedding
+			}
+		}
+	}
+	fuse(schemas.RetrieverLexical, lexical)
+	fuse(schemas.RetrieverVector, vector)
+
+	// Step 3: Rank by fused score, by chunk id on ties so the order is deterministic
+	fused := make([]schemas.RetrievedChunk, 0, len(order))
+	for _, id := range order {
+		result := results[id]
+		result.Chunk.Score = result.Score
+		fused = append(fused, *result)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].Chunk.ID < fused[j].Chunk.ID
+	})
+	return fused[:min(topK, len(fused))], nil
+}
+
+// filteredCandidates returns each retriever's candidates that pass the filters, best first. The
+// filters apply after retrieval, so the candidates fetched are doubled until each retriever has
+// topK times hybridOversampling passing ones or has no more to return.
+func filteredCandidates(userID, researchID, query string, filters schemas.SearchFilters, topK int) ([]schemas.TextChunk, []schemas.TextChunk, error) {
+	wanted := topK * hybridOversampling
+	for candidates := wanted; ; candidates *= 2 {
+		lexical, err := chunkRetriever.LexicalSearch(userID, researchID, query, candidates)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error in lexical search: %w", err)
+		}
+		vector, err := vectorMatches(userID, researchID, query, candidates)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error in vector search: %w", err)
+		}
+
+		lexicalExhausted, vectorExhausted := len(lexical) < candidates, len(vector) < candidates
+		lexical, vector = filterChunks(lexical, filters), filterChunks(vector, filters)
+		if (len(lexical) >= wanted || lexicalExhausted) && (len(vector) >= wanted || vectorExhausted) {
+			return lexical, vector, nil
+		}
+	}
+}
+
+// filterChunks keeps the chunks passing the filters, in order
+func filterChunks(chunks []schemas.TextChunk, filters schemas.SearchFilters) []schemas.TextChunk {
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if matchesFilters(chunk, filters) {
+			kept = append(kept, chunk)
+		}
+	}
+	return kept
+}
+
+// matchesFilters reports whether a chunk passes every set filter. Publication type, year and MeSH
+// filters need article metadata, so chunks without it fail them; the year also falls back to a
+// document's date added.
+func matchesFilters(chunk schemas.TextChunk, filters schemas.SearchFilters) bool {
+	if len(filters.Sections) > 0 && !containsFold(filters.Sections, chunk.Metadata.Section) {
+		return false
+	}
+
+	article := chunk.Metadata.MedlineData
+	if len(filters.PublicationTypes) > 0 {
+		if article == nil || !anyFold(filters.PublicationTypes, article.PublicationTypes) {
+			return false
+		}
+	}
+	if len(filters.MeshTerms) > 0 {
+		if article == nil {
+			return false
+		}
+		headings := make([]string, len(article.MeshTerms))
+		for i, term := range article.MeshTerms {
+			headings[i] = processors.NormalizeMeSHHeading(term)
+		}
+		wanted := make([]string, len(filters.MeshTerms))
+		for i, term := range filters.MeshTerms {
+			wanted[i] = processors.NormalizeMeSHHeading(term)
+		}
+		if !anyFold(wanted, headings) {
+			return false
+		}
+	}
+
+	if filters.YearFrom > 0 || filters.YearTo > 0 {
+		year := chunkYear(chunk)
+		if year == 0 || (filters.YearFrom > 0 && year < filters.YearFrom) || (filters.YearTo > 0 && year > filters.YearTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkYear is the publication year of a chunk's article, or else the year it or its document was
+// added, or 0 when unknown
+func chunkYear(chunk schemas.TextChunk) int {
+	var dates []string
+	if article := chunk.Metadata.MedlineData; article != nil {
+		dates = append(dates, article.JournalInfo.Date, article.DateAdded)
+	}
+	if document := chunk.Metadata.DocumentData; document != nil {
+		dates = append(dates, document.DateAdded)
+	}
+	for _, date := range dates {
+		// Stored dates are RFC3339 and MEDLINE ones start with the year, so the first four characters suffice
+		if len(date) >= 4 {
+			if year, err := strconv.Atoi(date[:4]); err == nil && year > 0 {
+				return year
+			}
+		}
+	}
+	return 0
+}
+
+// containsFold reports whether values holds value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyFold reports whether any of wanted is in values, ignoring case
+func anyFold(wanted, values []string) bool {
+	for _, value := range values {
+		if containsFold(wanted, value) {
+			return true
+		}
+	}
+	return false
+}
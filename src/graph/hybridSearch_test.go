@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+	"my-modus-app/src/utils/utilstest"
+	"my-modus-app/src/vectorindex"
+)
+
+// useLocalStore embeds the chunks with the fake default model and serves searches from a
+// LocalChunkStore holding them under one research
+func useLocalStore(t *testing.T, chunks []schemas.TextChunk) {
+	t.Helper()
+	utilstest.UseFakeModels()
+	embedder, err := utils.GetEmbedder("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range chunks {
+		vectors, err := embedder.Embed(chunks[i].Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[i].Embedding = vectors[0]
+		chunks[i].Metadata.EmbeddingModel = embedder.ModelName()
+	}
+
+	store := vectorindex.NewLocalChunkStore(func() vectorindex.Index { return vectorindex.NewBruteForceIndex() })
+	if err := store.AddChunks("user-1", "research-1", chunks); err != nil {
+		t.Fatal(err)
+	}
+	SetChunkRetriever(store)
+	t.Cleanup(func() { SetChunkRetriever(nil) })
+}
+
+// publishedIn is article metadata with a journal date in the year
+func publishedIn(pmid string, year int) *schemas.MedlineArticleMetadata {
+	return &schemas.MedlineArticleMetadata{PMID: pmid, JournalInfo: schemas.JournalInfo{Date: fmt.Sprintf("%d-01-01", year)}}
+}
+
+func TestHybridSearchFusesRanksAndRecordsProvenance(t *testing.T) {
+	useLocalStore(t, []schemas.TextChunk{
+		{ID: "both", Content: "Metformin lowers glucose in adults."},
+		{ID: "drug", Content: "Metformin dosing follows kidney function."},
+		{ID: "knee", Content: "Rehabilitation follows knee surgery."},
+	})
+
+	results, err := HybridSearch("user-1", "research-1", "metformin glucose", schemas.SearchFilters{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		id            string
+		matchedBy     string
+		lexical, vect int
+		score         float64
+	}{
+		{"both", "[lexical vector]", 1, 1, 2.0 / (rrfK + 1)},
+		{"drug", "[lexical vector]", 2, 2, 2.0 / (rrfK + 2)},
+		{"knee", "[vector]", 0, 3, 1.0 / (rrfK + 3)},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		w := want[i]
+		if result.Chunk.ID != w.id {
+			t.Errorf("result %d is %s, want %s", i, result.Chunk.ID, w.id)
+			continue
+		}
+		if fmt.Sprint(result.MatchedBy) != w.matchedBy || result.LexicalRank != w.lexical || result.VectorRank != w.vect {
+			t.Errorf("%s matched by %v at lexical %d, vector %d; want %s at %d, %d",
+				w.id, result.MatchedBy, result.LexicalRank, result.VectorRank, w.matchedBy, w.lexical, w.vect)
+		}
+		if math.Abs(result.Score-w.score) > 1e-12 || result.Chunk.Score != result.Score {
+			t.Errorf("%s scored %v, want %v", w.id, result.Score, w.score)
+		}
+		if w.lexical > 0 && result.LexicalScore <= 0 {
+			t.Errorf("%s has no BM25 score", w.id)
+		}
+	}
+}
+
+func TestHybridSearchFindsFilteredChunksBeyondTheFirstCandidates(t *testing.T) {
+	// Twelve close matches from 2010 rank before the one chunk from 2020
+	var chunks []schemas.TextChunk
+	for i := 0; i < 12; i++ {
+		chunks = append(chunks, schemas.TextChunk{
+			ID:       fmt.Sprintf("old-%d", i),
+			Content:  fmt.Sprintf("Metformin lowers glucose in cohort %d.", i),
+			Metadata: schemas.ChunkMetadata{Section: "Results", MedlineData: publishedIn("1", 2010)},
+		})
+	}
+	chunks = append(chunks,
+		schemas.TextChunk{
+			ID:       "recent",
+			Content:  "Long term follow up of patients on metformin across several clinics and regions.",
+			Metadata: schemas.ChunkMetadata{Section: "Results", MedlineData: publishedIn("2", 2020)},
+		},
+		schemas.TextChunk{
+			ID:       "recent-methods",
+			Content:  "Metformin glucose measurements were taken monthly.",
+			Metadata: schemas.ChunkMetadata{Section: "Methods", MedlineData: publishedIn("2", 2020)},
+		},
+	)
+	useLocalStore(t, chunks)
+
+	filters := schemas.SearchFilters{YearFrom: 2015, Sections: []string{"results"}}
+	results, err := HybridSearch("user-1", "research-1", "metformin glucose", filters, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Chunk.ID != "recent" {
+		t.Fatalf("got %+v, want only the recent results chunk", results)
+	}
+	if results[0].LexicalRank != 1 || results[0].VectorRank != 1 {
+		t.Errorf("ranks %d and %d, want 1 among the filtered candidates", results[0].LexicalRank, results[0].VectorRank)
+	}
+}
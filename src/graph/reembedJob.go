@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+
+	"github.com/google/uuid"
+	"github.com/hypermodeinc/modus/sdk/go/pkg/auth"
+)
+
+// DefaultReembedPageSize is the number of stored chunks read and embedded per page
+const DefaultReembedPageSize = 100
+
+// AdminRole is the JWT role claim allowed to start and continue re-embedding jobs
+const AdminRole = "admin"
+
+// adminClaims are the JWT claims read to authorize a re-embedding job
+type adminClaims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles"`
+}
+
+// StartReembedding creates a job that will re-embed every stored chunk with the given model into
+// a new versioned predicate. Run it with ContinueReembedding. Both act on every user's chunks, so
+// the caller's token must carry the admin role.
+func StartReembedding(modelName, cleaningProfile string) (*schemas.ReembedJob, error) {
+	if err := authorizeReembedding(); err != nil {
+		return nil, err
+	}
+	embedder, err := utils.GetEmbedder(modelName)
+	if err != nil {
+		return nil, err
+	}
+	if cleaningProfile == "" {
+		cleaningProfile = processors.ProfileEmbedding
+	}
+	if _, err := processors.NewTextCleanerForProfile(cleaningProfile); err != nil {
+		return nil, err
+	}
+
+	// Every job writes to a fresh predicate, so the embeddings in use are never touched
+	now := time.Now().UTC()
+	predicate := dg.ModelEmbeddingPredicate(embedder.ModelName()) + "_" + now.Format("20060102150405")
+	if err := dg.EnsureEmbeddingPredicate(predicate); err != nil {
+		return nil, err
+	}
+
+	total, err := dg.CountChunks()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &schemas.ReembedJob{
+		ID:              uuid.NewString(),
+		Model:           embedder.ModelName(),
+		Predicate:       predicate,
+		CleaningProfile: cleaningProfile,
+		Total:           total,
+		Dimension:       embedder.Dimension(),
+		Status:          schemas.ReembedRunning,
+		StartedAt:       now.Format(time.RFC3339),
+		UpdatedAt:       now.Format(time.RFC3339),
+	}
+	if err := dg.SaveReembedJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ContinueReembedding processes up to pages pages of chunks, saving the job's cursor after each
+// so an interrupted or failed call can simply be repeated. Past the last chunk it catches up on
+// chunks written or changed behind the cursor, and once none is left the new predicate is swapped in.
+func ContinueReembedding(jobID string, pages, pageSize int) (*schemas.ReembedJob, error) {
+	if err := authorizeReembedding(); err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultReembedPageSize
+	}
+
+	job, err := dg.GetReembedJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != schemas.ReembedRunning {
+		return job, nil
+	}
+
+	embedder, err := utils.GetEmbedder(job.Model)
+	if err != nil {
+		return failReembedJob(job, err)
+	}
+	cleaner, err := processors.NewTextCleanerForProfile(job.CleaningProfile)
+	if err != nil {
+		return failReembedJob(job, err)
+	}
+	batcher := utils.NewBatchEmbedder(embedder.Embed, utils.DefaultEmbeddingBatchConfig())
+
+	for page := 0; page < pages; page++ {
+		chunks, err := dg.ListChunkPage(job.Cursor, pageSize)
+		if err != nil {
+			return job, recordReembedError(job, err)
+		}
+
+		// Past the cursor's end, embed what was written or changed behind it
+		catchingUp := len(chunks) == 0
+		if catchingUp {
+			chunks, err = dg.ListChunksMissingEmbedding(job.Predicate, pageSize)
+			if err != nil {
+				return job, recordReembedError(job, err)
+			}
+		}
+
+		// All chunks are done: point the chunk embeddings at the new predicate
+		if len(chunks) == 0 {
+			swapped, err := dg.SwapEmbeddingVersion(schemas.EmbeddingVersion{
+				Predicate: job.Predicate,
+				Model:     job.Model,
+				Dimension: job.Dimension,
+				UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+			})
+			if err != nil {
+				return job, recordReembedError(job, err)
+			}
+			// A chunk changed just before the swap; the next page embeds it
+			if !swapped {
+				continue
+			}
+			job.Status = schemas.ReembedSwapped
+			job.Error = ""
+			return job, saveReembedJob(job)
+		}
+
+		var uids, texts []string
+		for _, chunk := range chunks {
+			// Parents carry no embedding of their own
+			if chunk.Metadata.ChunkType == processors.ChunkTypeParent {
+				continue
+			}
+			text, err := cleaner.Clean(processors.StripOverlap(schemas.TextChunk{
+				Content:  chunk.Content,
+				Metadata: schemas.ChunkMetadata{OverlapLength: chunk.Metadata.OverlapLength},
+			}))
+			if err != nil {
+				return job, recordReembedError(job, fmt.Errorf("error cleaning chunk %s: %w", chunk.ID, err))
+			}
+			uids = append(uids, chunk.UID)
+			texts = append(texts, text)
+		}
+
+		vectors, err := batcher.Embed(texts)
+		if err != nil {
+			return job, recordReembedError(job, err)
+		}
+		byUID := make(map[string][]float32, len(uids))
+		for i, uid := range uids {
+			byUID[uid] = vectors[i]
+		}
+		if err := dg.SetChunkEmbeddings(job.Predicate, byUID); err != nil {
+			return job, recordReembedError(job, err)
+		}
+
+		// The cursor only moves once the page is written, so a retry redoes at most one page
+		if !catchingUp {
+			job.Cursor = chunks[len(chunks)-1].UID
+			job.Processed += len(chunks)
+		}
+		job.Error = ""
+		if err := saveReembedJob(job); err != nil {
+			return job, err
+		}
+	}
+
+	return job, nil
+}
+
+// ReembeddingProgress returns a job with its cursor, processed and total counts
+func ReembeddingProgress(jobID string) (*schemas.ReembedJob, error) {
+	return dg.GetReembedJob(jobID)
+}
+
+// authorizeReembedding returns an error unless the request's JWT carries the admin role
+func authorizeReembedding() error {
+	claims, err := auth.GetJWTClaims[adminClaims]()
+	if err != nil {
+		return fmt.Errorf("re-embedding needs an admin token: %w", err)
+	}
+	if !slices.Contains(claims.Roles, AdminRole) {
+		return fmt.Errorf("user %q is not allowed to re-embed chunks", claims.Subject)
+	}
+	return nil
+}
+
+func saveReembedJob(job *schemas.ReembedJob) error {
+	job.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return dg.SaveReembedJob(job)
+}
+
+// recordReembedError keeps the job running so the page is retried on the next call
+func recordReembedError(job *schemas.ReembedJob, err error) error {
+	job.Error = err.Error()
+	if saveErr := saveReembedJob(job); saveErr != nil {
+		return fmt.Errorf("%w (and saving the job failed: %v)", err, saveErr)
+	}
+	return err
+}
+
+// failReembedJob stops a job that cannot continue, such as one whose model is no longer configured
+func failReembedJob(job *schemas.ReembedJob, err error) (*schemas.ReembedJob, error) {
+	job.Status = schemas.ReembedFailed
+	return job, recordReembedError(job, err)
+}
@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"my-modus-app/src/dg"
+	"my-modus-app/src/dg/dgtest"
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+	"my-modus-app/src/utils/utilstest"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/dgraph"
+)
+
+// fakeReembedStore answers the queries of one re-embedding job over a cursor pass of two chunks,
+// one of them a parent, and one chunk changed behind the cursor
+type fakeReembedStore struct {
+	job       schemas.ReembedJob
+	caughtUp  bool
+	swapped   bool
+	embedded  map[string]bool
+	swapConds []string
+}
+
+func (s *fakeReembedStore) respond(request *dgraph.Request) (string, error) {
+	query := ""
+	if request.Query != nil {
+		query = request.Query.Query
+	}
+	switch {
+	case strings.Contains(query, "job(func: eq(ReembedJob.id"):
+		data, err := json.Marshal(map[string][]schemas.ReembedJob{"job": {s.job}})
+		return string(data), err
+	case strings.Contains(query, "version(func: eq(EmbeddingVersion.name"):
+		if s.swapped {
+			return `{"version": [{"EmbeddingVersion.predicate": "` + s.job.Predicate + `"}]}`, nil
+		}
+		return `{}`, nil
+	case strings.Contains(query, "uid(missing), first:"):
+		if s.caughtUp {
+			return `{"chunks": []}`, nil
+		}
+		s.caughtUp = true
+		return `{"chunks": [{"uid": "0x9", "TextChunk.id": "changed", "TextChunk.content": "Changed after the cursor passed."}]}`, nil
+	case strings.Contains(query, "has(TextChunk.content), first:"):
+		if strings.Contains(query, "after:") {
+			return `{"chunks": []}`, nil
+		}
+		return `{"chunks": [
+			{"uid": "0x1", "TextChunk.id": "child", "TextChunk.content": "Metformin lowered HbA1c."},
+			{"uid": "0x2", "TextChunk.id": "parent", "TextChunk.content": "Results", "TextChunk.metadata": {"ChunkMetadata.chunk_type": "parent"}}
+		]}`, nil
+	case strings.Contains(query, "missing as var"):
+		for _, mutation := range request.Mutations {
+			s.swapConds = append(s.swapConds, mutation.Condition)
+		}
+		s.swapped = true
+		return `{}`, nil
+	}
+
+	// Vectors written to the job's predicate
+	for _, mutation := range request.Mutations {
+		var nodes []map[string]any
+		if json.Unmarshal([]byte(mutation.SetJson), &nodes) == nil {
+			for _, node := range nodes {
+				if _, ok := node[s.job.Predicate]; ok {
+					s.embedded[node["uid"].(string)] = true
+				}
+			}
+		}
+	}
+	return `{}`, nil
+}
+
+func TestContinueReembeddingCatchesUpBeforeSwapping(t *testing.T) {
+	utilstest.UseFakeModels()
+	t.Setenv("CLAIMS", `{"sub": "operator", "roles": ["admin"]}`)
+	model := utils.AvailableEmbedders()[0]
+
+	store := &fakeReembedStore{
+		job: schemas.ReembedJob{
+			ID:              "job-1",
+			Model:           model,
+			Predicate:       "TextChunk.embedding_test",
+			CleaningProfile: processors.ProfileEmbedding,
+			Status:          schemas.ReembedRunning,
+		},
+		embedded: map[string]bool{},
+	}
+	fake := dgtest.NewFakeExecutor()
+	fake.Respond = store.respond
+	dg.SetExecutor(fake)
+	defer dg.SetExecutor(nil)
+
+	job, err := ContinueReembedding("job-1", 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != schemas.ReembedSwapped {
+		t.Fatalf("job status %q, want swapped", job.Status)
+	}
+	if !store.embedded["0x1"] || !store.embedded["0x9"] || store.embedded["0x2"] {
+		t.Errorf("embedded %v, want the child and the changed chunk but not the parent", store.embedded)
+	}
+	if job.Processed != 2 {
+		t.Errorf("processed %d, want the 2 chunks of the cursor pass", job.Processed)
+	}
+	for _, condition := range store.swapConds {
+		if !strings.Contains(condition, "len(missing), 0") {
+			t.Errorf("swap mutation without the missing-chunk condition: %q", condition)
+		}
+	}
+}
+
+func TestReembeddingNeedsTheAdminRole(t *testing.T) {
+	fake := dgtest.NewFakeExecutor()
+	dg.SetExecutor(fake)
+	defer dg.SetExecutor(nil)
+
+	tests := []struct {
+		name    string
+		claims  string
+		allowed bool
+	}{
+		{"no token", "", false},
+		{"user token", `{"sub": "user-1", "roles": ["user"]}`, false},
+		{"admin token", `{"sub": "operator", "roles": ["user", "admin"]}`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CLAIMS", test.claims)
+			if err := authorizeReembedding(); (err == nil) != test.allowed {
+				t.Errorf("authorizeReembedding() = %v, allowed %v", err, test.allowed)
+			}
+			if test.allowed {
+				return
+			}
+			if _, err := StartReembedding(utils.AvailableEmbedders()[0], ""); err == nil {
+				t.Error("StartReembedding ran without the admin role")
+			}
+			if _, err := ContinueReembedding("job-1", 1, 10); err == nil {
+				t.Error("ContinueReembedding ran without the admin role")
+			}
+		})
+	}
+	if len(fake.Requests()) != 0 {
+		t.Errorf("unauthorized calls sent %d Dgraph requests", len(fake.Requests()))
+	}
+}
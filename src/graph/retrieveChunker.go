@@ -8,45 +8,130 @@ import (
 	"my-modus-app/src/utils"
 )
 
-func ChunkAndEmbedOneMedlineRetrieval(article schemas.MedlineArticle, ai bool) ([]schemas.TextChunk, error) {
-	useAI := ai
-	text := article.Abstract
+func ChunkAndEmbedOneMedlineRetrieval(article schemas.MedlineArticle, config processors.StrategyConfig) ([]schemas.TextChunk, error) {
+	chunks, err := chunkMedlineArticle(article, config)
+	if err != nil {
+		return nil, err
+	}
+	processors.AnnotateKeywords(chunks, nil, article.MeshTerms)
+
+	if err := embedChunks(chunks, config); err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
 
+// chunkMedlineArticle chunks the abstract of one article and tags its entities, without keywords
+// or embeddings
+func chunkMedlineArticle(article schemas.MedlineArticle, config processors.StrategyConfig) ([]schemas.TextChunk, error) {
 	// Convert the article to metadata
 	metadata := schemas.ConvertToMetadata(article)
 
-	// Chunk the text using the processor
-	chunks, err := processors.ChoiceChunker(text, useAI)
+	chunks, err := prepareChunks(article.Abstract, config, article.MeshTerms, article.Substances)
 	if err != nil {
-		return nil, fmt.Errorf("error chunking the abstract: %s", err)
+		return nil, fmt.Errorf("error chunking the abstract: %w", err)
 	}
 
 	// Update the metadata for each chunk
 	for i := range chunks {
-		chunks[i].Metadata.MedlineData = metadata
-		embedding, err := utils.GetEmbeddingsForTextWithOpenAI(chunks[i].Content)
+		chunks[i].Metadata.MedlineData = &metadata
+	}
+
+	return chunks, nil
+}
+
+// prepareChunks chunks text, drops low-quality chunks and annotates entities using the given MeSH
+// headings and substances. Keywords are left to the caller, which knows the corpus they are
+// weighted against.
+func prepareChunks(text string, config processors.StrategyConfig, meshTerms, substances []string) ([]schemas.TextChunk, error) {
+	config = config.WithDefaults()
+
+	// Chunk the text using the processor
+	chunks, err := processors.ChunkWithStrategy(text, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Score every chunk and drop junk such as copyright or funding statements before embedding
+	chunks = processors.ScoreAndFilterChunks(chunks, config.Chunking, *config.MinConfidence)
 
+	// Tag diseases, chemicals, genes, species and outcomes using the MeSH and RN fields
+	dictionary := processors.NewEntityDictionary(meshTerms, substances)
+	processors.AnnotateEntities(chunks, dictionary, config.EntityModel)
+
+	return chunks, nil
+}
+
+// embedChunks embeds the chunks in as few batched requests as the configured limits allow
+func embedChunks(chunks []schemas.TextChunk, config processors.StrategyConfig) error {
+	config = config.WithDefaults()
+
+	// Embed a cleaned copy of the content; the stored content stays original so offsets remain valid
+	cleaner, err := processors.NewTextCleanerForProfile(config.CleaningProfile)
+	if err != nil {
+		return fmt.Errorf("error creating the text cleaner: %w", err)
+	}
+
+	var texts []string
+	var indexes []int
+	for i := range chunks {
+		// Parents are only returned as context for their children, which carry the embeddings
+		if chunks[i].Metadata.ChunkType == processors.ChunkTypeParent {
+			continue
+		}
+		embeddingText, err := cleaner.Clean(processors.StripOverlap(chunks[i]))
 		if err != nil {
-			return nil, fmt.Errorf("error generating the embedding of the chunk: %v", err)
+			return fmt.Errorf("error cleaning the chunk: %w", err)
 		}
-		chunks[i].Embedding = embedding
+		texts = append(texts, embeddingText)
+		indexes = append(indexes, i)
 	}
 
-	return chunks, nil
+	embedder, err := utils.GetEmbedder(config.EmbeddingModel)
+	if err != nil {
+		return err
+	}
+	embeddings, err := utils.NewBatchEmbedder(embedder.Embed, config.Embedding).Embed(texts)
+	if err != nil {
+		return fmt.Errorf("error generating the embeddings of the chunks: %w", err)
+	}
+	for i, index := range indexes {
+		chunks[index].Embedding = embeddings[i]
+		chunks[index].Metadata.EmbeddingModel = embedder.ModelName()
+		chunks[index].Metadata.EmbeddingDimension = len(embeddings[i])
+	}
+
+	return nil
 }
 
-// Modify the function signature to accept pointer slice
-func ChunkAndEmbedManyMedlineRetrievals(articles []*schemas.MedlineArticle, ai bool) ([]schemas.TextChunk, error) {
-	var allChunks []schemas.TextChunk // Now just a single slice of TextChunk
+// ChunkAndEmbedManyMedlineRetrievals chunks every article first, then embeds the chunks of all
+// articles together so batches are filled across articles
+func ChunkAndEmbedManyMedlineRetrievals(articles []*schemas.MedlineArticle, config processors.StrategyConfig) ([]schemas.TextChunk, error) {
+	var allChunks []schemas.TextChunk  // Now just a single slice of TextChunk
+	ends := make([]int, len(articles)) // End of each article's chunks in allChunks
 
-	for _, article := range articles {
+	for i, article := range articles {
 		// Chunk a single article
-		chunks, err := ChunkAndEmbedOneMedlineRetrieval(*article, ai)
+		chunks, err := chunkMedlineArticle(*article, config)
 		if err != nil {
 			return nil, fmt.Errorf("error processing article with PMID %s: %s", article.PMID, err)
 		}
 		// Append the chunks to the overall slice
 		allChunks = append(allChunks, chunks...)
+		ends[i] = len(allChunks)
+	}
+
+	// Weigh keywords against every retrieved article, so phrases common to all of them rank low
+	extractor := processors.NewKeywordExtractor(allChunks, 0)
+	start := 0
+	for i, article := range articles {
+		processors.AnnotateKeywords(allChunks[start:ends[i]], extractor, article.MeshTerms)
+		start = ends[i]
+	}
+
+	if err := embedChunks(allChunks, config); err != nil {
+		return nil, err
 	}
 
 	return allChunks, nil
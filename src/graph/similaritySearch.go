@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+)
+
+// DefaultTopK is the number of chunks a search returns when none is asked for
+const DefaultTopK = 10
+
+// RetrieveSimilarChunks embeds the query with the model the chunks were embedded with and returns
+// the user's top k chunks by cosine similarity, filled into Score. A non-empty researchID limits
+// the search to that research. With expandParents, sentence-window matches are replaced by their
+// section-level parent.
+func RetrieveSimilarChunks(userID, researchID, query string, topK int, expandParents bool) ([]schemas.TextChunk, error) {
+	if topK <= 0 {
+		topK = DefaultTopK
+	}
+
+	matches, err := vectorMatches(userID, researchID, query, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	if expandParents {
+		if matches, err = expandToStoredParents(userID, matches); err != nil {
+			return nil, err
+		}
+	}
+	return matches[:min(topK, len(matches))], nil
+}
+
+// vectorMatches returns up to k of the user's chunks nearest the query, best first with their cosine
+// similarity as score
+func vectorMatches(userID, researchID, query string, k int) ([]schemas.TextChunk, error) {
+	embedder, queryEmbedding, err := embedQuery(userID, researchID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := chunkRetriever.SearchChunks(userID, researchID, queryEmbedding, k)
+	if err != nil {
+		return nil, err
+	}
+
+	// Score the candidates, skipping any embedded with another model than the query
+	matches := make([]schemas.TextChunk, 0, len(candidates))
+	for _, chunk := range candidates {
+		if err := utils.CheckEmbeddingModel(embedder.ModelName(), queryEmbedding, chunk); err != nil {
+			log.Printf("Skipping search result: %v", err)
+			continue
+		}
+		chunk.Score = utils.CosineSimilarity(queryEmbedding, chunk.Embedding)
+		matches = append(matches, chunk)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches, nil
+}
+
+// embedQuery embeds the query, cleaned like chunk text, with the model the chunks were embedded with
+func embedQuery(userID, researchID, query string) (utils.Embedder, []float32, error) {
+	model, err := chunkRetriever.EmbeddingModel(userID, researchID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	embedder, err := utils.GetEmbedder(model)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleaned, err := processors.CleanText(query, processors.ProfileEmbedding)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error cleaning the query: %w", err)
+	}
+	embeddings, err := embedder.Embed(cleaned)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error embedding the query: %w", err)
+	}
+	return embedder, embeddings[0], nil
+}
+
+// expandToStoredParents fetches the parents of the matches and replaces each child with its parent
+func expandToStoredParents(userID string, matches []schemas.TextChunk) ([]schemas.TextChunk, error) {
+	var parentIDs []string
+	seen := map[string]bool{}
+	for _, match := range matches {
+		if id := match.Metadata.ParentID; id != "" && !seen[id] {
+			seen[id] = true
+			parentIDs = append(parentIDs, id)
+		}
+	}
+
+	parents, err := chunkRetriever.ChunksByID(userID, parentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching parent chunks: %w", err)
+	}
+	return processors.ExpandToParents(matches, parents), nil
+}
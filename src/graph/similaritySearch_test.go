@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"sort"
+	"testing"
+
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+	"my-modus-app/src/utils/utilstest"
+)
+
+// sliceRetriever serves chunk searches from a slice by brute-force cosine similarity
+type sliceRetriever struct {
+	chunks []schemas.TextChunk
+	model  string
+}
+
+func (r sliceRetriever) SearchChunks(userID, researchID string, vector []float32, k int) ([]schemas.TextChunk, error) {
+	ranked := append([]schemas.TextChunk(nil), r.chunks...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return utils.CosineSimilarity(vector, ranked[i].Embedding) > utils.CosineSimilarity(vector, ranked[j].Embedding)
+	})
+	return ranked[:min(k, len(ranked))], nil
+}
+
+func (r sliceRetriever) LexicalSearch(userID, researchID, query string, k int) ([]schemas.TextChunk, error) {
+	return rankLexically(query, r.chunks, k), nil
+}
+
+func (r sliceRetriever) ChunksByID(userID string, ids []string) ([]schemas.TextChunk, error) {
+	var found []schemas.TextChunk
+	for _, chunk := range r.chunks {
+		for _, id := range ids {
+			if chunk.ID == id {
+				found = append(found, chunk)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r sliceRetriever) EmbeddingModel(userID, researchID string) (string, error) {
+	return r.model, nil
+}
+
+// testArticles are two structured abstracts on unrelated topics
+var testArticles = []*schemas.MedlineArticle{
+	{
+		PMID:      "1",
+		Title:     "Metformin in type 2 diabetes",
+		Abstract:  "BACKGROUND: Metformin is the first-line therapy for type 2 diabetes in adults. RESULTS: Metformin lowered glycated haemoglobin compared with placebo over twelve months of treatment.",
+		MeshTerms: []string{"Diabetes Mellitus, Type 2", "Metformin"},
+	},
+	{
+		PMID:      "2",
+		Title:     "Exercise after knee surgery",
+		Abstract:  "BACKGROUND: Knee replacement surgery is followed by months of rehabilitation. RESULTS: Supervised exercise improved walking distance and knee flexion after surgery.",
+		MeshTerms: []string{"Arthroplasty, Replacement, Knee", "Exercise Therapy"},
+	},
+}
+
+func TestChunkAndEmbedThenRetrieveWithFakeModels(t *testing.T) {
+	utilstest.UseFakeModels()
+
+	chunks, err := ChunkAndEmbedManyMedlineRetrievals(testArticles, processors.StrategyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want at least one per article", len(chunks))
+	}
+	embedder, err := utils.GetEmbedder("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		if chunk.Metadata.MedlineData == nil {
+			t.Errorf("chunk %q lost its article", chunk.Content)
+		}
+		if len(chunk.Embedding) != embedder.Dimension() || chunk.Metadata.EmbeddingModel != embedder.ModelName() {
+			t.Errorf("chunk %q embedded with %s in %d dimensions", chunk.Content, chunk.Metadata.EmbeddingModel, len(chunk.Embedding))
+		}
+		if len(chunk.Metadata.Keywords) == 0 {
+			t.Errorf("chunk %q has no keywords", chunk.Content)
+		}
+	}
+
+	SetChunkRetriever(sliceRetriever{chunks: chunks, model: embedder.ModelName()})
+	defer SetChunkRetriever(nil)
+
+	matches, err := RetrieveSimilarChunks("user", "", "supervised exercise and walking after knee surgery", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Metadata.MedlineData.PMID != "2" {
+		t.Fatalf("expected the knee surgery article first, got %+v", matches)
+	}
+	if matches[0].Score <= 0 {
+		t.Errorf("match scored %v, want its cosine similarity", matches[0].Score)
+	}
+}
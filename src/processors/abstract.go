@@ -0,0 +1,155 @@
+package processors
+
+import (
+	"regexp"
+	"strings"
+
+	models "my-modus-app/src/schemas"
+)
+
+// NLM categories used for labels in structured abstracts
+const (
+	AbstractBackground  = "BACKGROUND"
+	AbstractObjective   = "OBJECTIVE"
+	AbstractMethods     = "METHODS"
+	AbstractResults     = "RESULTS"
+	AbstractConclusions = "CONCLUSIONS"
+	AbstractUnassigned  = "UNASSIGNED"
+)
+
+// abstractLabels maps the labels seen in PubMed abstracts to their NLM category
+var abstractLabels = map[string]string{
+	"BACKGROUND":                        AbstractBackground,
+	"INTRODUCTION":                      AbstractBackground,
+	"CONTEXT":                           AbstractBackground,
+	"RATIONALE":                         AbstractBackground,
+	"BACKGROUND AND AIMS":               AbstractBackground,
+	"BACKGROUND AND OBJECTIVES":         AbstractBackground,
+	"BACKGROUND AND PURPOSE":            AbstractBackground,
+	"OBJECTIVE":                         AbstractObjective,
+	"OBJECTIVES":                        AbstractObjective,
+	"AIM":                               AbstractObjective,
+	"AIMS":                              AbstractObjective,
+	"PURPOSE":                           AbstractObjective,
+	"GOAL":                              AbstractObjective,
+	"GOALS":                             AbstractObjective,
+	"HYPOTHESIS":                        AbstractObjective,
+	"IMPORTANCE":                        AbstractObjective,
+	"METHODS":                           AbstractMethods,
+	"METHOD":                            AbstractMethods,
+	"METHODOLOGY":                       AbstractMethods,
+	"MATERIALS AND METHODS":             AbstractMethods,
+	"MATERIAL AND METHODS":              AbstractMethods,
+	"PATIENTS AND METHODS":              AbstractMethods,
+	"SUBJECTS AND METHODS":              AbstractMethods,
+	"DESIGN":                            AbstractMethods,
+	"STUDY DESIGN":                      AbstractMethods,
+	"DESIGN, SETTING, AND PARTICIPANTS": AbstractMethods,
+	"SETTING":                           AbstractMethods,
+	"SETTINGS":                          AbstractMethods,
+	"PARTICIPANTS":                      AbstractMethods,
+	"PATIENTS":                          AbstractMethods,
+	"SUBJECTS":                          AbstractMethods,
+	"INTERVENTION":                      AbstractMethods,
+	"INTERVENTIONS":                     AbstractMethods,
+	"MEASUREMENTS":                      AbstractMethods,
+	"MAIN OUTCOME MEASURES":             AbstractMethods,
+	"MAIN OUTCOMES AND MEASURES":        AbstractMethods,
+	"DATA SOURCES":                      AbstractMethods,
+	"STUDY SELECTION":                   AbstractMethods,
+	"DATA EXTRACTION":                   AbstractMethods,
+	"RESULTS":                           AbstractResults,
+	"RESULT":                            AbstractResults,
+	"FINDINGS":                          AbstractResults,
+	"METHODS AND FINDINGS":              AbstractResults,
+	"MAIN RESULTS":                      AbstractResults,
+	"OUTCOMES":                          AbstractResults,
+	"CONCLUSIONS":                       AbstractConclusions,
+	"CONCLUSION":                        AbstractConclusions,
+	"CONCLUSIONS AND RELEVANCE":         AbstractConclusions,
+	"INTERPRETATION":                    AbstractConclusions,
+	"IMPLICATIONS":                      AbstractConclusions,
+	"DISCUSSION":                        AbstractConclusions,
+	"SIGNIFICANCE":                      AbstractConclusions,
+	"AUTHORS' CONCLUSIONS":              AbstractConclusions,
+	"CLINICAL RELEVANCE":                AbstractConclusions,
+}
+
+// abstractLabelPattern matches a candidate label at the start of the text, a line or a sentence
+var abstractLabelPattern = regexp.MustCompile(`(?m)(?:^|[.!?]\s+)([A-Za-z][A-Za-z ,&/'-]{1,60}?)\s*:\s*`)
+
+// maxStructuredAbstractLength bounds the text treated as a structured abstract; longer text is a
+// full article whose inline "Background:" style labels must not override its headers
+const maxStructuredAbstractLength = 6000
+
+// NormalizeAbstractLabel maps a structured-abstract label to its NLM category,
+// returning an empty string when the label is not recognised
+func NormalizeAbstractLabel(label string) string {
+	key := strings.ToUpper(strings.Join(strings.Fields(label), " "))
+	key = strings.ReplaceAll(key, "&", "AND")
+	return abstractLabels[key]
+}
+
+// SplitStructuredAbstract splits "BACKGROUND: ... METHODS: ..." style abstracts into sections whose
+// title is the normalized NLM category. The boolean is false when fewer than two labels are found or
+// the text is longer than an abstract.
+func (se *SectionExtractor) SplitStructuredAbstract(text string) ([]Section, bool) {
+	if len(text) > maxStructuredAbstractLength {
+		return nil, false
+	}
+
+	type label struct {
+		category           string
+		labelStart, bodyAt int
+	}
+
+	var labels []label
+	for _, match := range abstractLabelPattern.FindAllStringSubmatchIndex(text, -1) {
+		category := NormalizeAbstractLabel(text[match[2]:match[3]])
+		if category == "" {
+			continue
+		}
+		labels = append(labels, label{category: category, labelStart: match[2], bodyAt: match[1]})
+	}
+
+	if len(labels) < 2 {
+		return nil, false
+	}
+
+	var sections []Section
+
+	// Keep any unlabeled lead-in text rather than dropping it
+	if lead := strings.TrimSpace(text[:labels[0].labelStart]); lead != "" {
+		sections = append(sections, Section{Title: AbstractUnassigned, Content: lead, Type: "PubMed"})
+	}
+
+	for i, l := range labels {
+		end := len(text)
+		if i+1 < len(labels) {
+			end = labels[i+1].labelStart
+		}
+		content := strings.TrimSpace(text[l.bodyAt:end])
+		if content == "" {
+			continue
+		}
+		sections = append(sections, Section{Title: l.category, Content: content, Type: "PubMed"})
+	}
+
+	return sections, len(sections) > 0
+}
+
+// FilterChunksBySection keeps only the chunks whose section matches the given NLM category or title
+func FilterChunksBySection(chunks []models.TextChunk, section string) []models.TextChunk {
+	want := section
+	if category := NormalizeAbstractLabel(section); category != "" {
+		want = category
+	}
+
+	var filtered []models.TextChunk
+	for _, chunk := range chunks {
+		if strings.EqualFold(chunk.Metadata.Section, want) {
+			filtered = append(filtered, chunk)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,44 @@
+package processors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitStructuredAbstract(t *testing.T) {
+	se := NewSectionExtractor()
+	sections, ok := se.SplitStructuredAbstract("BACKGROUND:Obesity is common. METHODS: We enrolled 40 adults. Conclusions:Weight fell.")
+	if !ok {
+		t.Fatal("expected a structured abstract")
+	}
+
+	var titles []string
+	for _, section := range sections {
+		titles = append(titles, section.Title)
+	}
+	if got := strings.Join(titles, ","); got != "BACKGROUND,METHODS,CONCLUSIONS" {
+		t.Errorf("titles = %s", got)
+	}
+	if sections[0].Content != "Obesity is common." {
+		t.Errorf("first section = %q", sections[0].Content)
+	}
+}
+
+func TestExtractSectionsIgnoresInlineLabelsInFullText(t *testing.T) {
+	body := strings.Repeat("The cohort was followed for five years with annual visits. ", 120)
+	text := "INTRODUCTION\n\nBackground: prior work is sparse. " + body +
+		"\n\nMETHODS\n\n" + body + "\n\nDISCUSSION\n\nConclusion: the effect is modest. " + body
+
+	sections, err := NewSectionExtractor().ExtractSections(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) == 0 || sections[0].Title != "INTRODUCTION" {
+		t.Fatalf("expected header-based sections, got %+v", sections)
+	}
+	for _, section := range sections {
+		if section.Type == "PubMed" {
+			t.Fatalf("full text was split as a structured abstract into %q", section.Title)
+		}
+	}
+}
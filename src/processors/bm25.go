@@ -0,0 +1,130 @@
+package processors
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2  // Term frequency saturation
+	bm25B  = 0.75 // Document length normalisation
+)
+
+// SearchTerms lowercases text and splits it into the words lexical search matches on, without stopwords
+func SearchTerms(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		word = strings.Trim(word, "-")
+		if word == "" || stopwords[word] {
+			continue
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// BM25Result is one document matching a lexical search
+type BM25Result struct {
+	ID    string
+	Score float64
+}
+
+// BM25Index ranks documents against a query with Okapi BM25
+type BM25Index struct {
+	documents   map[string]map[string]int // Term counts of each document
+	postings    map[string]map[string]bool
+	lengths     map[string]int
+	totalLength int
+}
+
+func NewBM25Index() *BM25Index {
+	return &BM25Index{
+		documents: make(map[string]map[string]int),
+		postings:  make(map[string]map[string]bool),
+		lengths:   make(map[string]int),
+	}
+}
+
+// Len is the number of documents in the index
+func (b *BM25Index) Len() int {
+	return len(b.documents)
+}
+
+// Add indexes a document, replacing any earlier document with the same id
+func (b *BM25Index) Add(id, text string) {
+	b.Remove(id)
+
+	terms := SearchTerms(text)
+	counts := make(map[string]int)
+	for _, term := range terms {
+		counts[term]++
+		if b.postings[term] == nil {
+			b.postings[term] = make(map[string]bool)
+		}
+		b.postings[term][id] = true
+	}
+	b.documents[id] = counts
+	b.lengths[id] = len(terms)
+	b.totalLength += len(terms)
+}
+
+// Remove drops a document; removing an unknown id does nothing
+func (b *BM25Index) Remove(id string) {
+	counts, ok := b.documents[id]
+	if !ok {
+		return
+	}
+	for term := range counts {
+		delete(b.postings[term], id)
+		if len(b.postings[term]) == 0 {
+			delete(b.postings, term)
+		}
+	}
+	b.totalLength -= b.lengths[id]
+	delete(b.documents, id)
+	delete(b.lengths, id)
+}
+
+// Search returns up to k documents containing a query term, best first. A non-nil filter keeps
+// only the ids it accepts.
+func (b *BM25Index) Search(query string, k int, filter func(id string) bool) []BM25Result {
+	if k <= 0 || len(b.documents) == 0 {
+		return nil
+	}
+
+	// Count each distinct query term once
+	seen := make(map[string]bool)
+	scores := make(map[string]float64)
+	averageLength := float64(b.totalLength) / float64(len(b.documents))
+	for _, term := range SearchTerms(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		matching := b.postings[term]
+		idf := math.Log(1 + (float64(len(b.documents))-float64(len(matching))+0.5)/(float64(len(matching))+0.5))
+		for id := range matching {
+			if filter != nil && !filter(id) {
+				continue
+			}
+			frequency := float64(b.documents[id][term])
+			norm := 1 - bm25B + bm25B*float64(b.lengths[id])/math.Max(averageLength, 1)
+			scores[id] += idf * frequency * (bm25K1 + 1) / (frequency + bm25K1*norm)
+		}
+	}
+
+	results := make([]BM25Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, BM25Result{ID: id, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results[:min(k, len(results))]
+}
@@ -5,10 +5,7 @@ import (
 	"fmt"
 	"log"
 	models "my-modus-app/src/schemas"
-	"strings"
-
-	generativeModel "github.com/hypermodeinc/modus/sdk/go/pkg/models"
-	"github.com/hypermodeinc/modus/sdk/go/pkg/models/openai"
+	"my-modus-app/src/utils"
 )
 
 // ChunkingStrategy interface for different chunking strategies
@@ -18,11 +15,12 @@ type ChunkingStrategy interface {
 
 // ChunkingConfig holds configuration for chunking
 type ChunkingConfig struct {
-	MaxChunkSize       int  `json:"max_chunk_size"`
-	MinChunkSize       int  `json:"min_chunk_size"`
-	ChunkOverlap       int  `json:"chunk_overlap"`
-	PreserveParagraphs bool `json:"preserve_paragraphs"`
-	PreserveSentences  bool `json:"preserve_sentences"`
+	MaxChunkSize       int    `json:"max_chunk_size"`
+	MinChunkSize       int    `json:"min_chunk_size"`
+	ChunkOverlap       int    `json:"chunk_overlap"`
+	OverlapUnit        string `json:"overlap_unit"` // OverlapTokens (default) or OverlapSentences
+	PreserveParagraphs bool   `json:"preserve_paragraphs"`
+	PreserveSentences  bool   `json:"preserve_sentences"`
 	// SectionHeaders     []string `json:"section_headers"`
 }
 
@@ -99,10 +97,7 @@ func FallbackToLLMChunking(
 	modelName string,
 ) ([]models.TextChunk, error) {
 	// Get the model from the available models
-	model, err := generativeModel.GetModel[openai.ChatModel](modelName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get model: %w", err)
-	}
+	model := utils.GetChatModel(modelName)
 
 	// Updated Instruction for LLM
 	instruction := `
@@ -120,7 +115,7 @@ Return the output strictly as a JSON array of objects, each representing a secti
   {
     "Title": "<Title of the section>",
     "Content": "<Content of the section>",
-    "Type": "<Type of the section>",
+    "Type": "<Type of the section>"
   },
   ...
 ]
@@ -160,30 +155,15 @@ Return the output strictly as a JSON array of objects, each representing a secti
 Here is the document to analyze:
 `
 
-	// Append the text to the instruction
-	prompt := fmt.Sprintf("Here is the document to analyze\n\n%s", text)
-
-	// Prepare input for the model
-	input, err := model.CreateInput(
-		openai.NewSystemMessage(instruction),
-		openai.NewUserMessage(prompt),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create model input: %w", err)
-	}
-
-	// Set model parameters
-	input.Temperature = 0.2 // Minimize randomness for strict compliance
-	input.MaxTokens = 2048  // Adjust token limit for larger outputs
-
-	// Invoke the model
-	output, err := model.Invoke(input)
+	// The model echoes section text, so it sees and is checked against the prompt-cleaned document.
+	// Its sections are then located in the original text, which chunks and offsets must match.
+	cleaned, err := CleanText(text, ProfilePrompt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, fmt.Errorf("failed to clean text for the prompt: %w", err)
 	}
 
-	// Extract the raw JSON part from the model's response and clean it
-	cleanedOutput := output.Choices[0].Message.Content
+	// Append the text to the instruction
+	prompt := fmt.Sprintf("Here is the document to analyze\n\n%s", cleaned)
 
 	// Create a ChunkingConfig from the provided parameters
 	config := ChunkingConfig{
@@ -194,20 +174,23 @@ Here is the document to analyze:
 		PreserveSentences:  preserveSentences,
 	}
 
-	// Remove backticks and extra newlines, only keep valid JSON
-	cleanedOutput = strings.TrimPrefix(cleanedOutput, "```json\n")
-	cleanedOutput = strings.TrimSuffix(cleanedOutput, "\n```")
-
-	// Ensure the cleaned output is a valid JSON array
-	if !strings.HasPrefix(cleanedOutput, "[") || !strings.HasSuffix(cleanedOutput, "]") {
-		return nil, fmt.Errorf("invalid JSON format: %s", cleanedOutput)
+	// Ask the model, retrying when the output cannot be parsed or does not match the text
+	var sections []Section
+	for attempt := 1; attempt <= maxLLMAttempts; attempt++ {
+		sections, err = requestLLMSections(model, instruction, prompt, cleaned)
+		if err == nil {
+			sections, err = LocateSections(text, sections)
+		}
+		if err == nil {
+			break
+		}
+		log.Printf("LLM chunking attempt %d/%d failed: %v", attempt, maxLLMAttempts, err)
 	}
 
-	// Unmarshal the cleaned output to sections
-	var sections []Section
-	err = json.Unmarshal([]byte(cleanedOutput), &sections)
+	// Fall back to rule-based chunking rather than failing the whole document
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		log.Printf("Falling back to rule-based chunking: %v", err)
+		return NewChunker(config).ProcessText(text, maxChunkSize, minChunkSize, chunkOverlap, preserveParagraphs, preserveSentences)
 	}
 
 	// Initialize the Chunker with the provided configuration
@@ -229,64 +212,50 @@ Here is the document to analyze:
 	return allChunks, nil
 }
 
-func ChoiceChunker(text string, use_ai bool) ([]models.TextChunk, error) {
-	// Initialize parameters directly within the function
-	modelName := "section-generator" // Set model name to 'section-generator as seen in the modus.json'
-	maxChunkSize := 1000             // Set max chunk size
-	minChunkSize := 500              // Set min chunk size
-	chunkOverlap := 50               // Set chunk overlap
-	preserveParagraphs := true       // Set preserve paragraphs flag
-	preserveSentences := true        // Set preserve sentences flag
-	if use_ai {
-		// Call FallbackToLLMChunking to process the document and get the chunked JSON response
-		allChunks, err := FallbackToLLMChunking(
-			text,
-			maxChunkSize,
-			minChunkSize,
-			chunkOverlap,
-			preserveParagraphs,
-			preserveSentences,
-			modelName,
-		)
-		if err != nil {
-			log.Printf("Error in chunking document: %v", err)
-			return nil, fmt.Errorf("failed to process document: %w", err)
-		}
+// maxLLMAttempts is how many times the model is asked before falling back to rule-based chunking
+const maxLLMAttempts = 2
 
-		return allChunks, nil
-	} else {
-		// Validate parameters
-		if maxChunkSize <= 0 || minChunkSize <= 0 {
-			return nil, fmt.Errorf("chunk size values must be greater than 0")
-		}
-		if chunkOverlap < 0 {
-			return nil, fmt.Errorf("chunk overlap must be non-negative")
-		}
+// requestLLMSections invokes the model once and returns the validated sections from its output
+func requestLLMSections(model utils.ChatModel, instruction, prompt, source string) ([]Section, error) {
+	// Invoke the model
+	content, err := model.Complete(utils.ChatRequest{
+		System:      instruction,
+		User:        prompt,
+		Temperature: 0.2,  // Minimize randomness for strict compliance
+		MaxTokens:   2048, // Adjust token limit for larger outputs
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Create config for the chunker
-		config := ChunkingConfig{
-			MaxChunkSize:       maxChunkSize,
-			MinChunkSize:       minChunkSize,
-			ChunkOverlap:       chunkOverlap,
-			PreserveParagraphs: preserveParagraphs,
-			PreserveSentences:  preserveSentences,
-		}
+	// Extract the JSON array, tolerating fences, prose, trailing commas and truncation
+	cleanedOutput, err := ExtractJSONArray(content)
+	if err != nil {
+		return nil, err
+	}
 
-		// Initialize chunker with config
-		chunker := NewChunker(config)
-
-		// Process the text
-		chunks, err := chunker.ProcessText(
-			text,
-			maxChunkSize,
-			minChunkSize,
-			chunkOverlap,
-			preserveParagraphs,
-			preserveSentences,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error chunking text: %w", err)
-		}
-		return chunks, nil
+	// Unmarshal the cleaned output to sections
+	var sections []Section
+	if err := json.Unmarshal([]byte(cleanedOutput), &sections); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
+
+	return ValidateSections(sections, source)
+}
+
+// ChoiceChunker chunks text with the section strategy, or with the LLM strategy when use_ai is set.
+// Use ChunkWithStrategy to pick any other registered strategy or change the sizes.
+func ChoiceChunker(text string, use_ai bool) ([]models.TextChunk, error) {
+	config := DefaultStrategyConfig()
+	config.Strategy = StrategySection
+	if use_ai {
+		config.Strategy = StrategyLLM
+	}
+
+	chunks, err := ChunkWithStrategy(text, config)
+	if err != nil {
+		log.Printf("Error in chunking document: %v", err)
+		return nil, fmt.Errorf("failed to process document: %w", err)
+	}
+	return chunks, nil
 }
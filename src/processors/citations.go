@@ -0,0 +1,283 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	models "my-modus-app/src/schemas"
+)
+
+// Citation marker styles recognised by ExtractCitations
+const (
+	CitationNumeric     = "numeric"
+	CitationSuperscript = "superscript"
+	CitationAuthorYear  = "author-year"
+)
+
+// Citation is one in-text citation marker and the references it resolves to
+type Citation struct {
+	Marker     string   `json:"marker"`
+	Style      string   `json:"style"`
+	Numbers    []int    `json:"numbers,omitempty"`
+	References []string `json:"references,omitempty"` // Identifiers of the resolved references
+}
+
+// Reference is one entry of a parsed reference list
+type Reference struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+	ID    string `json:"id"` // DOI, PMID or a positional ref-N identifier
+}
+
+// maxCitationRange caps how many numbers a range like [3-7] expands to
+const maxCitationRange = 100
+
+const authorYearItem = `[A-Z][\p{L}'’-]+(?:\s+(?:et al\.?|and|&)(?:\s+[A-Z][\p{L}'’-]+)?)?,?\s+\d{4}[a-z]?`
+
+var (
+	numericCitationPattern     = regexp.MustCompile(`\[(\d+(?:\s*[-–—]\s*\d+)?(?:\s*[,;]\s*\d+(?:\s*[-–—]\s*\d+)?)*)\]`)
+	superscriptCitationPattern = regexp.MustCompile(`[⁰¹²³⁴⁵⁶⁷⁸⁹]+(?:[⁻–,][⁰¹²³⁴⁵⁶⁷⁸⁹]+)*`)
+	authorYearCitationPattern  = regexp.MustCompile(`\((` + authorYearItem + `(?:\s*;\s*` + authorYearItem + `)*)\)`)
+	citationYearPattern        = regexp.MustCompile(`\d{4}`)
+	referenceHeadingPattern    = regexp.MustCompile(`(?im)^\s*#*\s*(references|bibliography|literature cited|works cited)\s*:?\s*$`)
+	referenceNumberPattern     = regexp.MustCompile(`^\s*\[?(\d+)[\].)]?\s+(.*)$`)
+	doiPattern                 = regexp.MustCompile(`10\.\d{4,9}/[^\s"<>]+`)
+	pmidPattern                = regexp.MustCompile(`PMID:?\s*(\d+)`)
+)
+
+var (
+	// spatialUnits take a superscript power on their own, as in "cm³"
+	spatialUnits = map[string]bool{"m": true, "cm": true, "mm": true, "km": true, "µm": true, "μm": true, "nm": true, "dm": true, "ft": true}
+	// perUnits take one as the last part of a compound unit, as in "m/s²"
+	perUnits = map[string]bool{"s": true, "h": true, "min": true, "l": true, "ml": true, "dl": true, "g": true, "kg": true, "mol": true}
+)
+
+var superscriptDigits = strings.NewReplacer(
+	"⁰", "0", "¹", "1", "²", "2", "³", "3", "⁴", "4",
+	"⁵", "5", "⁶", "6", "⁷", "7", "⁸", "8", "⁹", "9", "⁻", "-",
+)
+
+// ExtractCitations finds numeric, range, superscript and author-year citation markers in text and
+// resolves them against references when a reference list is available
+func ExtractCitations(text string, references []Reference) []Citation {
+	var citations []Citation
+
+	for _, match := range numericCitationPattern.FindAllStringSubmatch(text, -1) {
+		citations = append(citations, Citation{Marker: match[0], Style: CitationNumeric, Numbers: expandCitationNumbers(match[1])})
+	}
+	for _, loc := range superscriptCitationIndexes(text) {
+		marker := text[loc[0]:loc[1]]
+		numbers := expandCitationNumbers(superscriptDigits.Replace(marker))
+		citations = append(citations, Citation{Marker: marker, Style: CitationSuperscript, Numbers: numbers})
+	}
+	for _, match := range authorYearCitationPattern.FindAllStringSubmatch(text, -1) {
+		citations = append(citations, Citation{Marker: match[0], Style: CitationAuthorYear})
+	}
+
+	for i := range citations {
+		citations[i].References = resolveCitation(citations[i], references)
+	}
+	return citations
+}
+
+// superscriptCitationIndexes locates the superscript citation markers of text, skipping powers: a
+// superscript after a digit, as in "10⁹", or after a unit, as in "kg/m²"
+func superscriptCitationIndexes(text string) [][]int {
+	var markers [][]int
+	for _, loc := range superscriptCitationPattern.FindAllStringIndex(text, -1) {
+		if !isPower(text[:loc[0]]) {
+			markers = append(markers, loc)
+		}
+	}
+	return markers
+}
+
+// removeSuperscriptCitations deletes the superscript citation markers of text, keeping powers
+func removeSuperscriptCitations(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range superscriptCitationIndexes(text) {
+		out.WriteString(text[last:loc[0]])
+		last = loc[1]
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// isPower reports whether a superscript following before is an exponent rather than a citation
+func isPower(before string) bool {
+	last, _ := utf8.DecodeLastRuneInString(before)
+	if unicode.IsDigit(last) {
+		return true
+	}
+
+	// Take the unit-like word ending right before the superscript, such as "kg/m"
+	start := len(before)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(before[:start])
+		if !unicode.IsLetter(r) && r != '/' && r != '·' {
+			break
+		}
+		start -= size
+	}
+	parts := strings.FieldsFunc(before[start:], func(r rune) bool { return r == '/' || r == '·' })
+	if len(parts) == 0 {
+		return false
+	}
+	unit := parts[len(parts)-1]
+	return spatialUnits[unit] || (len(parts) > 1 && perUnits[strings.ToLower(unit)])
+}
+
+// expandCitationNumbers turns "1, 3-5" into [1 3 4 5]
+func expandCitationNumbers(list string) []int {
+	var numbers []int
+	for _, part := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ';' }) {
+		bounds := strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '–' || r == '—' })
+		if len(bounds) == 0 {
+			continue
+		}
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			continue
+		}
+		to := from
+		if len(bounds) > 1 {
+			if n, err := strconv.Atoi(strings.TrimSpace(bounds[1])); err == nil && n >= from && n-from < maxCitationRange {
+				to = n
+			}
+		}
+		for n := from; n <= to; n++ {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// resolveCitation maps a citation to reference identifiers, by number or by surname and year
+func resolveCitation(citation Citation, references []Reference) []string {
+	if len(references) == 0 {
+		return nil
+	}
+
+	var ids []string
+	if citation.Style != CitationAuthorYear {
+		for _, n := range citation.Numbers {
+			for _, ref := range references {
+				if ref.Index == n {
+					ids = append(ids, ref.ID)
+					break
+				}
+			}
+		}
+		return ids
+	}
+
+	inner := strings.Trim(citation.Marker, "()")
+	for _, item := range strings.Split(inner, ";") {
+		fields := strings.Fields(item)
+		year := citationYearPattern.FindString(item)
+		if len(fields) == 0 || year == "" {
+			continue
+		}
+		surname := strings.ToLower(strings.TrimRight(fields[0], ","))
+		for _, ref := range references {
+			lower := strings.ToLower(ref.Text)
+			if strings.Contains(lower, surname) && strings.Contains(lower, year) {
+				ids = append(ids, ref.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// ParseReferenceList parses the reference list that follows a "References" heading, if any
+func ParseReferenceList(text string) []Reference {
+	loc := referenceHeadingPattern.FindStringIndex(text)
+	if loc == nil {
+		return nil
+	}
+
+	var references []Reference
+	numbered := false
+	for _, line := range strings.Split(text[loc[1]:], "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if match := referenceNumberPattern.FindStringSubmatch(line); match != nil && (numbered || len(references) == 0) {
+			index, _ := strconv.Atoi(match[1])
+			references = append(references, Reference{Index: index, Text: match[2]})
+			numbered = true
+			continue
+		}
+		// In a numbered list unnumbered lines continue the previous entry, otherwise each line is an entry
+		if numbered {
+			references[len(references)-1].Text += " " + line
+			continue
+		}
+		references = append(references, Reference{Index: len(references) + 1, Text: line})
+	}
+
+	for i := range references {
+		references[i].ID = referenceID(references[i])
+	}
+	return references
+}
+
+// referenceID picks the most stable identifier available for a reference
+func referenceID(ref Reference) string {
+	if doi := doiPattern.FindString(ref.Text); doi != "" {
+		return "doi:" + strings.TrimRight(doi, ".,;)")
+	}
+	if match := pmidPattern.FindStringSubmatch(ref.Text); match != nil {
+		return "pmid:" + match[1]
+	}
+	return fmt.Sprintf("ref-%d", ref.Index)
+}
+
+// AnnotateCitations parses the reference list of text and fills each chunk's citation markers and
+// resolved reference identifiers. Chunks of the reference list itself are left unannotated, as its
+// "[1] ..." entries are not citations.
+func AnnotateCitations(chunks []models.TextChunk, text string) {
+	references := ParseReferenceList(text)
+	referenceStart := -1
+	if loc := referenceHeadingPattern.FindStringIndex(text); loc != nil {
+		referenceStart = loc[0]
+	}
+
+	for i := range chunks {
+		content := chunks[i].Content
+		if isReferenceHeading(chunks[i].Metadata.Section) || (referenceStart > 0 && chunks[i].Metadata.StartIndex >= referenceStart) {
+			chunks[i].Metadata.Citations, chunks[i].Metadata.CitedReferences = nil, nil
+			continue
+		}
+		// A chunk running into the reference list is only annotated up to its heading
+		if loc := referenceHeadingPattern.FindStringIndex(content); loc != nil {
+			content = content[:loc[0]]
+		}
+
+		var markers, resolved []string
+		seen := make(map[string]bool)
+		for _, citation := range ExtractCitations(content, references) {
+			markers = append(markers, citation.Marker)
+			for _, id := range citation.References {
+				if !seen[id] {
+					seen[id] = true
+					resolved = append(resolved, id)
+				}
+			}
+		}
+		chunks[i].Metadata.Citations = markers
+		chunks[i].Metadata.CitedReferences = resolved
+	}
+}
+
+// isReferenceHeading reports whether a section title is a reference list heading
+func isReferenceHeading(title string) bool {
+	return title != "" && referenceHeadingPattern.MatchString(strings.TrimSpace(title))
+}
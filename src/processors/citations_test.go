@@ -0,0 +1,39 @@
+package processors
+
+import (
+	"reflect"
+	"testing"
+
+	models "my-modus-app/src/schemas"
+)
+
+func TestExtractCitationsSkipsPowers(t *testing.T) {
+	text := "Obesity¹ was defined as a BMI over 30 kg/m², an area of 4 cm³ or 10⁹ cells, and acceleration in m/s² was noted.²,³"
+
+	var markers []string
+	for _, citation := range ExtractCitations(text, nil) {
+		markers = append(markers, citation.Marker)
+	}
+	if want := []string{"¹", "²,³"}; !reflect.DeepEqual(markers, want) {
+		t.Errorf("markers = %q, want %q", markers, want)
+	}
+}
+
+func TestAnnotateCitationsSkipsReferenceList(t *testing.T) {
+	body := "Metformin lowers glucose [1]. It is first-line therapy [2]."
+	text := body + "\n\nReferences\n[1] Smith J. Metformin. PMID: 111\n[2] Jones K. Diabetes care. PMID: 222\n"
+	referencesStart := len(body) + 2
+
+	chunks := []models.TextChunk{
+		{Content: body},
+		{Content: text[referencesStart:], Metadata: models.ChunkMetadata{StartIndex: referencesStart}},
+	}
+	AnnotateCitations(chunks, text)
+
+	if want := []string{"pmid:111", "pmid:222"}; !reflect.DeepEqual(chunks[0].Metadata.CitedReferences, want) {
+		t.Errorf("body references = %q, want %q", chunks[0].Metadata.CitedReferences, want)
+	}
+	if len(chunks[1].Metadata.Citations) != 0 {
+		t.Errorf("reference list annotated with %q", chunks[1].Metadata.Citations)
+	}
+}
@@ -0,0 +1,162 @@
+package processors
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Whitespace handling modes for CleaningRules.Whitespace
+const (
+	WhitespaceKeep       = ""
+	WhitespaceCollapse   = "collapse"   // Everything becomes single spaces
+	WhitespaceParagraphs = "paragraphs" // Collapse within paragraphs but keep blank lines between them
+)
+
+// Built-in cleaning profiles
+const (
+	ProfileEmbedding = "embedding"
+	ProfileDisplay   = "display"
+	ProfilePrompt    = "prompt"
+)
+
+// CleaningRules configures what TextCleaner changes
+type CleaningRules struct {
+	Whitespace         string `json:"whitespace"`
+	RemoveCitations    bool   `json:"remove_citations"`
+	DecodeHTMLEntities bool   `json:"decode_html_entities"`
+	StripTags          bool   `json:"strip_tags"`
+	NormalizeUnicode   bool   `json:"normalize_unicode"`
+	JoinHyphenation    bool   `json:"join_hyphenation"`
+}
+
+// cleaningProfiles holds the named rule sets; chunk content itself is never cleaned so offsets stay valid
+var cleaningProfiles = map[string]CleaningRules{
+	// Text sent to the embeddings model: no markers or markup that would add noise to the vector
+	ProfileEmbedding: {
+		Whitespace:         WhitespaceCollapse,
+		RemoveCitations:    true,
+		DecodeHTMLEntities: true,
+		StripTags:          true,
+		NormalizeUnicode:   true,
+		JoinHyphenation:    true,
+	},
+	// Text shown to users: citations and paragraphs kept
+	ProfileDisplay: {
+		Whitespace:         WhitespaceParagraphs,
+		DecodeHTMLEntities: true,
+		JoinHyphenation:    true,
+	},
+	// Text placed in LLM prompts: compact, but citations kept so the model can refer to them
+	ProfilePrompt: {
+		Whitespace:         WhitespaceCollapse,
+		DecodeHTMLEntities: true,
+		StripTags:          true,
+		NormalizeUnicode:   true,
+		JoinHyphenation:    true,
+	},
+}
+
+var (
+	horizontalSpace = regexp.MustCompile(`[ \t\f\v\p{Zs}]+`)
+	blankLines      = regexp.MustCompile(`\s*\n\s*\n\s*`)
+	singleNewline   = regexp.MustCompile(`[ \t]*\n[ \t]*`)
+	anyWhitespace   = regexp.MustCompile(`\s+`)
+	lineHyphenation = regexp.MustCompile(`(\p{L})-[ \t]*\n[ \t]*(\p{Ll})`)
+	spaceBeforeMark = regexp.MustCompile(`\s+([.,;:!?])`)
+	// markupTag only matches tags whose name starts with a letter, so comparisons such as
+	// "aged <65 years and BMI >30" in plain text survive
+	markupTag = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9:-]*(?:\s[^<>]*)?/?>`)
+)
+
+// unicodeCompatibility maps ligatures, invisible characters and typographic variants to plain forms
+var unicodeCompatibility = strings.NewReplacer(
+	"\u00a0", " ", "\u2009", " ", "\u202f", " ", "\u200b", "", "\u200c", "", "\u200d", "", "\ufeff", "", "\u00ad", "",
+	"ﬁ", "fi", "ﬂ", "fl", "ﬀ", "ff", "ﬃ", "ffi", "ﬄ", "ffl",
+	"\u2018", "'", "\u2019", "'", "\u201c", "\"", "\u201d", "\"", "\u2032", "'", "\u2033", "\"",
+	"\u2212", "-", "\u2010", "-", "\u2011", "-", "\u2026", "...",
+)
+
+// TextCleaner prepares text for a particular use according to its rules
+type TextCleaner struct {
+	rules CleaningRules
+}
+
+// NewTextCleaner returns a cleaner with the embedding profile
+func NewTextCleaner() *TextCleaner {
+	return &TextCleaner{rules: cleaningProfiles[ProfileEmbedding]}
+}
+
+// NewTextCleanerWithRules returns a cleaner with custom rules
+func NewTextCleanerWithRules(rules CleaningRules) *TextCleaner {
+	return &TextCleaner{rules: rules}
+}
+
+// NewTextCleanerForProfile returns a cleaner for a named profile
+func NewTextCleanerForProfile(profile string) (*TextCleaner, error) {
+	rules, ok := cleaningProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown cleaning profile %q, expected one of %s", profile, strings.Join(CleaningProfiles(), ", "))
+	}
+	return &TextCleaner{rules: rules}, nil
+}
+
+// RegisterCleaningProfile adds or replaces a named cleaning profile
+func RegisterCleaningProfile(name string, rules CleaningRules) {
+	cleaningProfiles[name] = rules
+}
+
+// CleaningProfiles lists the registered profile names in sorted order
+func CleaningProfiles() []string {
+	names := make([]string, 0, len(cleaningProfiles))
+	for name := range cleaningProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Clean applies the rules in a fixed order: markup, entities, Unicode, hyphenation, citations, whitespace
+func (tc *TextCleaner) Clean(text string) (string, error) {
+	cleaned := text
+	if tc.rules.StripTags {
+		cleaned = markupTag.ReplaceAllString(cleaned, " ")
+	}
+	if tc.rules.DecodeHTMLEntities {
+		cleaned = html.UnescapeString(cleaned)
+	}
+	if tc.rules.NormalizeUnicode {
+		cleaned = unicodeCompatibility.Replace(cleaned)
+	}
+	if tc.rules.JoinHyphenation {
+		cleaned = lineHyphenation.ReplaceAllString(cleaned, "$1$2")
+	}
+	if tc.rules.RemoveCitations {
+		// Only citation markers go; parentheses holding statistics such as "(95% CI 1.2–3.4)" stay
+		cleaned = numericCitationPattern.ReplaceAllString(cleaned, "")
+		cleaned = removeSuperscriptCitations(cleaned)
+		cleaned = authorYearCitationPattern.ReplaceAllString(cleaned, "")
+		cleaned = spaceBeforeMark.ReplaceAllString(cleaned, "$1")
+	}
+
+	switch tc.rules.Whitespace {
+	case WhitespaceCollapse:
+		cleaned = anyWhitespace.ReplaceAllString(cleaned, " ")
+	case WhitespaceParagraphs:
+		cleaned = blankLines.ReplaceAllString(cleaned, "\n\n")
+		cleaned = horizontalSpace.ReplaceAllString(cleaned, " ")
+		cleaned = singleNewline.ReplaceAllString(cleaned, "\n")
+	}
+	return strings.TrimSpace(cleaned), nil
+}
+
+// CleanText cleans text with a named profile
+func CleanText(text, profile string) (string, error) {
+	cleaner, err := NewTextCleanerForProfile(profile)
+	if err != nil {
+		return "", err
+	}
+	return cleaner.Clean(text)
+}
@@ -0,0 +1,25 @@
+package processors
+
+import "testing"
+
+func TestCleanKeepsClinicalComparisons(t *testing.T) {
+	tests := []struct {
+		name, profile, in, want string
+	}{
+		{"comparisons", ProfileEmbedding, "Adults aged <65 years and BMI >30 were enrolled.", "Adults aged <65 years and BMI >30 were enrolled."},
+		{"units", ProfileEmbedding, "Mean BMI was 31 kg/m² at baseline.¹", "Mean BMI was 31 kg/m² at baseline."},
+		{"markup", ProfileEmbedding, "<p>Glucose <i>fell</i> by 2 mmol/L.</p>", "Glucose fell by 2 mmol/L."},
+		{"prompt comparisons", ProfilePrompt, "HbA1c <7% and eGFR >60 ml/min", "HbA1c <7% and eGFR >60 ml/min"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CleanText(test.in, test.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
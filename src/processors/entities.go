@@ -0,0 +1,292 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	models "my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+)
+
+// Biomedical entity types stored in ChunkMetadata.EntityTypes
+const (
+	EntityDisease  = "Disease"
+	EntityChemical = "Chemical"
+	EntityGene     = "Gene"
+	EntitySpecies  = "Species"
+	EntityOutcome  = "Outcome"
+)
+
+// Sources of an entity mention
+const (
+	EntitySourceDictionary = "dictionary"
+	EntitySourceLLM        = "llm"
+)
+
+// diseaseIndicators mark a MeSH heading as a disease or condition
+var diseaseIndicators = []string{
+	"disease", "syndrome", "disorder", "neoplasm", "cancer", "carcinoma", "tumor", "tumour", "infection",
+	"mellitus", "hypertension", "failure", "injury", "insufficiency", "obesity", "asthma", "stroke",
+	"depression", "dementia", "sepsis", "fibrosis", "anemia", "anaemia", "pain", "covid-19",
+}
+
+// diseaseSuffixes mark single words such as "arthritis" or "tuberculosis" as diseases
+var diseaseSuffixes = []string{"itis", "osis", "emia", "aemia", "oma", "pathy", "plegia"}
+
+// speciesTerms are MeSH check tags and common model organisms
+var speciesTerms = map[string]string{
+	"humans": "Homo sapiens", "human": "Homo sapiens", "mice": "Mus musculus", "mouse": "Mus musculus",
+	"rats": "Rattus norvegicus", "rat": "Rattus norvegicus", "zebrafish": "Danio rerio", "dogs": "Canis familiaris",
+	"swine": "Sus scrofa", "cattle": "Bos taurus", "drosophila melanogaster": "Drosophila melanogaster",
+	"escherichia coli": "Escherichia coli", "saccharomyces cerevisiae": "Saccharomyces cerevisiae",
+	"caenorhabditis elegans": "Caenorhabditis elegans",
+}
+
+// outcomeTerms are common clinical outcome measures
+var outcomeTerms = []string{
+	"mortality", "survival", "overall survival", "progression-free survival", "quality of life", "adverse events",
+	"adverse effects", "hospitalization", "readmission", "recurrence", "remission", "response rate", "incidence",
+	"hba1c", "blood pressure", "body weight", "length of stay", "treatment outcome", "pain score",
+}
+
+// geneMentionPattern finds gene symbols followed by a genetic context word, e.g. "BRCA1 mutation"
+var geneMentionPattern = regexp.MustCompile(`\b([A-Z][A-Z0-9]{1,7}(?:-[A-Z0-9]+)?)\s+(?:gene|genes|mutation|mutations|variant|variants|polymorphism|polymorphisms|expression|allele|alleles)\b`)
+
+// EntityDictionary maps lowercase surface forms to their type and normalized name
+type EntityDictionary struct {
+	entries map[string]dictionaryEntry
+	pattern *regexp.Regexp
+}
+
+type dictionaryEntry struct {
+	Type       string
+	Normalized string
+}
+
+// NewEntityDictionary builds a dictionary from an article's MeSH headings and RN substances
+// on top of the built-in species and outcome vocabularies
+func NewEntityDictionary(meshTerms, substances []string) *EntityDictionary {
+	ed := &EntityDictionary{entries: make(map[string]dictionaryEntry)}
+
+	for term, normalized := range speciesTerms {
+		ed.add(term, EntitySpecies, normalized)
+	}
+	for _, term := range outcomeTerms {
+		ed.add(term, EntityOutcome, term)
+	}
+	for _, substance := range substances {
+		ed.add(substance, EntityChemical, substance)
+	}
+	for _, term := range meshTerms {
+		heading := NormalizeMeSHHeading(term)
+		if _, ok := speciesTerms[strings.ToLower(heading)]; ok {
+			continue // Check tags such as "Humans" keep their species name
+		}
+		if entityType := classifyMeSHHeading(heading); entityType != "" {
+			ed.add(heading, entityType, heading)
+		}
+	}
+
+	ed.compile()
+	return ed
+}
+
+// add registers a surface form and, for inverted headings like "Diabetes Mellitus, Type 2", its natural order
+func (ed *EntityDictionary) add(term, entityType, normalized string) {
+	term = strings.TrimSpace(term)
+	if len(term) < 3 {
+		return
+	}
+	ed.entries[strings.ToLower(term)] = dictionaryEntry{Type: entityType, Normalized: normalized}
+	if head, tail, ok := strings.Cut(term, ", "); ok && !strings.Contains(tail, ",") {
+		ed.entries[strings.ToLower(tail+" "+head)] = dictionaryEntry{Type: entityType, Normalized: normalized}
+	}
+}
+
+// compile builds one case-insensitive alternation, longest terms first so they win over their prefixes
+func (ed *EntityDictionary) compile() {
+	terms := make([]string, 0, len(ed.entries))
+	for term := range ed.entries {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+	for i, term := range terms {
+		terms[i] = regexp.QuoteMeta(term)
+	}
+	if len(terms) > 0 {
+		ed.pattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(terms, "|") + `)\b`)
+	}
+}
+
+// classifyMeSHHeading guesses the entity type of a MeSH heading, or returns "" when it is none of ours
+func classifyMeSHHeading(heading string) string {
+	lower := strings.ToLower(heading)
+	for _, term := range outcomeTerms {
+		if lower == term {
+			return EntityOutcome
+		}
+	}
+	for _, indicator := range diseaseIndicators {
+		if strings.Contains(lower, indicator) {
+			return EntityDisease
+		}
+	}
+	for _, word := range strings.FieldsFunc(lower, isWordSeparator) {
+		for _, suffix := range diseaseSuffixes {
+			if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+				return EntityDisease
+			}
+		}
+	}
+	return ""
+}
+
+// Extract finds dictionary and gene-pattern mentions in text
+func (ed *EntityDictionary) Extract(text string) []models.EntityMention {
+	var mentions []models.EntityMention
+	if ed.pattern != nil {
+		for _, loc := range ed.pattern.FindAllStringIndex(text, -1) {
+			entry := ed.entries[strings.ToLower(text[loc[0]:loc[1]])]
+			mentions = append(mentions, models.EntityMention{
+				Text:       text[loc[0]:loc[1]],
+				Type:       entry.Type,
+				Normalized: entry.Normalized,
+				StartIndex: loc[0],
+				EndIndex:   loc[1],
+				Source:     EntitySourceDictionary,
+			})
+		}
+	}
+	for _, match := range geneMentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		mentions = append(mentions, models.EntityMention{
+			Text:       text[match[2]:match[3]],
+			Type:       EntityGene,
+			Normalized: text[match[2]:match[3]],
+			StartIndex: match[2],
+			EndIndex:   match[3],
+			Source:     EntitySourceDictionary,
+		})
+	}
+	return mentions
+}
+
+// ExtractEntitiesWithLLM asks the model for entity mentions and locates each one in text
+func ExtractEntitiesWithLLM(text, modelName string) ([]models.EntityMention, error) {
+	instruction := `
+You are a biomedical named-entity recognition system. Find every mention of a disease, drug or chemical,
+gene or protein, species, and clinical outcome in the text.
+
+Return only a JSON array, with no other text, in the following format:
+
+[
+  {
+    "Text": "<mention exactly as written in the text>",
+    "Type": "<one of Disease, Chemical, Gene, Species, Outcome>",
+    "Normalized": "<preferred name of the entity>"
+  }
+]
+`
+
+	content, err := utils.GetChatModel(modelName).Complete(utils.ChatRequest{
+		System:      instruction,
+		User:        text,
+		Temperature: 0.1,
+		MaxTokens:   1024,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cleanedOutput, err := ExtractJSONArray(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []struct {
+		Text       string `json:"Text"`
+		Type       string `json:"Type"`
+		Normalized string `json:"Normalized"`
+	}
+	if err := json.Unmarshal([]byte(cleanedOutput), &found); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	// Only keep mentions of a known type that really occur in the text
+	var mentions []models.EntityMention
+	for _, entity := range found {
+		if !isEntityType(entity.Type) || entity.Text == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], entity.Text)
+			if i == -1 {
+				break
+			}
+			start := offset + i
+			mentions = append(mentions, models.EntityMention{
+				Text:       entity.Text,
+				Type:       entity.Type,
+				Normalized: entity.Normalized,
+				StartIndex: start,
+				EndIndex:   start + len(entity.Text),
+				Source:     EntitySourceLLM,
+			})
+			offset = start + len(entity.Text)
+		}
+	}
+	return mentions, nil
+}
+
+func isEntityType(entityType string) bool {
+	switch entityType {
+	case EntityDisease, EntityChemical, EntityGene, EntitySpecies, EntityOutcome:
+		return true
+	}
+	return false
+}
+
+// AnnotateEntities fills each chunk's entity mentions and entity types from the dictionary, adding the
+// LLM's mentions when modelName is not empty. LLM failures leave the dictionary mentions in place.
+func AnnotateEntities(chunks []models.TextChunk, dictionary *EntityDictionary, modelName string) {
+	for i := range chunks {
+		mentions := dictionary.Extract(chunks[i].Content)
+		if modelName != "" {
+			if llmMentions, err := ExtractEntitiesWithLLM(chunks[i].Content, modelName); err == nil {
+				mentions = mergeMentions(mentions, llmMentions)
+			}
+		}
+
+		sort.Slice(mentions, func(a, b int) bool { return mentions[a].StartIndex < mentions[b].StartIndex })
+		var entityTypes []string
+		for _, mention := range mentions {
+			entityTypes = appendUnique(entityTypes, mention.Type)
+		}
+		chunks[i].Metadata.Entities = mentions
+		chunks[i].Metadata.EntityTypes = entityTypes
+	}
+}
+
+// mergeMentions adds the extra mentions that do not overlap an existing one
+func mergeMentions(mentions, extra []models.EntityMention) []models.EntityMention {
+	for _, candidate := range extra {
+		overlaps := false
+		for _, existing := range mentions {
+			if candidate.StartIndex < existing.EndIndex && existing.StartIndex < candidate.EndIndex {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			mentions = append(mentions, candidate)
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,120 @@
+package processors
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	models "my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+	"my-modus-app/src/utils/utilstest"
+)
+
+func TestEntityDictionaryExtractOffsets(t *testing.T) {
+	dictionary := NewEntityDictionary([]string{"Diabetes Mellitus, Type 2/drug therapy*", "Humans"}, []string{"Metformin"})
+	text := "In humans with type 2 diabetes mellitus, metformin improved overall survival; " +
+		"BRCA1 mutation carriers and pharmacokinetics studies were excluded."
+
+	want := []models.EntityMention{
+		{Text: "humans", Type: EntitySpecies, Normalized: "Homo sapiens"},
+		{Text: "type 2 diabetes mellitus", Type: EntityDisease, Normalized: "Diabetes Mellitus, Type 2"},
+		{Text: "metformin", Type: EntityChemical, Normalized: "Metformin"},
+		{Text: "overall survival", Type: EntityOutcome, Normalized: "overall survival"},
+		{Text: "BRCA1", Type: EntityGene, Normalized: "BRCA1"},
+	}
+	mentions := dictionary.Extract(text)
+	if len(mentions) != len(want) {
+		t.Fatalf("got %d mentions %+v, want %d", len(mentions), mentions, len(want))
+	}
+	for i, mention := range mentions {
+		w := want[i]
+		if mention.Text != w.Text || mention.Type != w.Type || mention.Normalized != w.Normalized {
+			t.Errorf("mention %d = %q %s %q, want %q %s %q", i, mention.Text, mention.Type, mention.Normalized, w.Text, w.Type, w.Normalized)
+		}
+		if got := text[mention.StartIndex:mention.EndIndex]; got != mention.Text {
+			t.Errorf("offsets of %q select %q", mention.Text, got)
+		}
+		if mention.Source != EntitySourceDictionary {
+			t.Errorf("%q has source %q", mention.Text, mention.Source)
+		}
+	}
+}
+
+func TestMergeMentionsSkipsOverlaps(t *testing.T) {
+	existing := []models.EntityMention{{Text: "type 2 diabetes", StartIndex: 10, EndIndex: 25}}
+
+	tests := []struct {
+		name  string
+		extra models.EntityMention
+		added bool
+	}{
+		{"inside", models.EntityMention{Text: "diabetes", StartIndex: 17, EndIndex: 25}, false},
+		{"covering", models.EntityMention{Text: "type 2 diabetes mellitus", StartIndex: 10, EndIndex: 34}, false},
+		{"overlapping the start", models.EntityMention{Text: "with type", StartIndex: 5, EndIndex: 14}, false},
+		{"ending where it starts", models.EntityMention{Text: "adults", StartIndex: 4, EndIndex: 10}, true},
+		{"starting where it ends", models.EntityMention{Text: " mellitus", StartIndex: 25, EndIndex: 34}, true},
+		{"apart", models.EntityMention{Text: "metformin", StartIndex: 40, EndIndex: 49}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := mergeMentions(append([]models.EntityMention(nil), existing...), []models.EntityMention{test.extra})
+			if added := len(merged) == 2; added != test.added {
+				t.Errorf("added %v, want %v", added, test.added)
+			}
+			if merged[0] != existing[0] {
+				t.Errorf("existing mention changed to %+v", merged[0])
+			}
+		})
+	}
+}
+
+func TestExtractEntitiesWithLLMLocatesMentions(t *testing.T) {
+	model := utilstest.NewScriptedChatModel("entities-scripted", "```json\n"+`[
+		{"Text": "metformin", "Type": "Chemical", "Normalized": "Metformin"},
+		{"Text": "lactic acidosis", "Type": "Disease", "Normalized": "Acidosis, Lactic"},
+		{"Text": "insulin", "Type": "Chemical", "Normalized": "Insulin"},
+		{"Text": "kidneys", "Type": "Organ", "Normalized": "Kidney"}
+	]`+"\n```")
+	utils.RegisterChatModel(model)
+
+	text := "Patients on metformin rarely develop lactic acidosis, so metformin stays first line for the kidneys."
+	mentions, err := ExtractEntitiesWithLLM(text, model.ModelName())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every occurrence is located; mentions absent from the text or of unknown types are dropped
+	want := []models.EntityMention{
+		{Text: "metformin", Type: EntityChemical, Normalized: "Metformin", StartIndex: 12, EndIndex: 21, Source: EntitySourceLLM},
+		{Text: "metformin", Type: EntityChemical, Normalized: "Metformin", StartIndex: 57, EndIndex: 66, Source: EntitySourceLLM},
+		{Text: "lactic acidosis", Type: EntityDisease, Normalized: "Acidosis, Lactic", StartIndex: 37, EndIndex: 52, Source: EntitySourceLLM},
+	}
+	if !reflect.DeepEqual(mentions, want) {
+		t.Errorf("got %+v, want %+v", mentions, want)
+	}
+	if requests := model.Requests(); len(requests) != 1 || requests[0].User != text {
+		t.Errorf("expected one request with the chunk text, got %+v", requests)
+	}
+}
+
+func TestAnnotateEntitiesPrefersDictionaryMentions(t *testing.T) {
+	model := utilstest.NewScriptedChatModel("entities-annotate",
+		`[{"Text": "type 2 diabetes", "Type": "Disease", "Normalized": "Diabetes Type 2"},
+		  {"Text": "Weight gain", "Type": "Outcome", "Normalized": "Weight Gain"}]`)
+	utils.RegisterChatModel(model)
+
+	dictionary := NewEntityDictionary([]string{"Diabetes Mellitus, Type 2"}, []string{"Metformin"})
+	chunks := []models.TextChunk{{Content: "Weight gain was rare with metformin in type 2 diabetes mellitus."}}
+	AnnotateEntities(chunks, dictionary, model.ModelName())
+
+	var texts []string
+	for _, mention := range chunks[0].Metadata.Entities {
+		texts = append(texts, mention.Text+"/"+mention.Source)
+	}
+	if got := strings.Join(texts, ", "); got != "Weight gain/llm, metformin/dictionary, type 2 diabetes mellitus/dictionary" {
+		t.Errorf("mentions %s", got)
+	}
+	if got := chunks[0].Metadata.EntityTypes; !reflect.DeepEqual(got, []string{EntityOutcome, EntityChemical, EntityDisease}) {
+		t.Errorf("entity types %v", got)
+	}
+}
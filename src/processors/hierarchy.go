@@ -0,0 +1,154 @@
+package processors
+
+import (
+	"fmt"
+	"time"
+
+	models "my-modus-app/src/schemas"
+
+	"github.com/google/uuid"
+)
+
+// ChunkTypeParent marks a section-level chunk whose sentence-window children are what gets matched
+const ChunkTypeParent = "parent"
+
+// HierarchyConfig configures the hierarchical strategy's parent and child chunks
+type HierarchyConfig struct {
+	// ParentStrategy produces the section-level parents; empty uses auto detection
+	ParentStrategy string `json:"parent_strategy"`
+	// ParentMaxSize caps the size of a parent chunk in bytes; longer sections are split
+	ParentMaxSize int `json:"parent_max_size"`
+	// ChildSentences is the number of sentences in each child window
+	ChildSentences int `json:"child_sentences"`
+	// ChildOverlap is the number of sentences neighbouring child windows share
+	ChildOverlap int `json:"child_overlap"`
+}
+
+// DefaultHierarchyConfig returns parents of up to 4000 bytes with three-sentence children
+func DefaultHierarchyConfig() HierarchyConfig {
+	return HierarchyConfig{
+		ParentStrategy: StrategyAuto,
+		ParentMaxSize:  4000,
+		ChildSentences: 3,
+		ChildOverlap:   1,
+	}
+}
+
+// HierarchicalChunking returns section-level parent chunks followed by their sentence-window
+// children. Each child's ParentID names its parent and each parent lists its ChildIDs.
+func HierarchicalChunking(text string, config StrategyConfig) ([]models.TextChunk, error) {
+	hierarchy := config.Hierarchy
+	if hierarchy.ParentStrategy == StrategyHierarchical {
+		return nil, fmt.Errorf("parent strategy cannot itself be %q", StrategyHierarchical)
+	}
+	if hierarchy.ChildOverlap >= hierarchy.ChildSentences {
+		return nil, fmt.Errorf("child overlap must be smaller than the child window")
+	}
+
+	// Parents are plain section chunks, sized for context rather than matching and never overlapping
+	parentConfig := config
+	parentConfig.Strategy = hierarchy.ParentStrategy
+	parentConfig.Chunking.MaxChunkSize = hierarchy.ParentMaxSize
+	parentConfig.Chunking.MinChunkSize = min(config.Chunking.MinChunkSize, hierarchy.ParentMaxSize)
+	parentConfig.Chunking.ChunkOverlap = 0
+	strategy, err := NewStrategy(parentConfig)
+	if err != nil {
+		return nil, err
+	}
+	parents, err := strategy.Chunk(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parent chunks: %w", err)
+	}
+
+	var children []models.TextChunk
+	for i := range parents {
+		parents[i].Metadata.ChunkType = ChunkTypeParent
+		parentChildren := sentenceWindowChildren(parents[i], hierarchy.ChildSentences, hierarchy.ChildOverlap)
+		for _, child := range parentChildren {
+			parents[i].Metadata.ChildIDs = append(parents[i].Metadata.ChildIDs, child.ID)
+		}
+		children = append(children, parentChildren...)
+	}
+
+	return append(parents, children...), nil
+}
+
+// sentenceWindowChildren slides a window of size sentences over the parent, overlap sentences at a time
+func sentenceWindowChildren(parent models.TextChunk, size, overlap int) []models.TextChunk {
+	spans := SplitSentenceSpans(parent.Content)
+	step := size - overlap
+
+	var children []models.TextChunk
+	for first := 0; first < len(spans); first += step {
+		last := min(first+size, len(spans)) - 1
+		start, end := spans[first].Start, spans[last].End
+		children = append(children, models.TextChunk{
+			ID:      uuid.NewString(),
+			Content: parent.Content[start:end],
+			Metadata: models.ChunkMetadata{
+				StartIndex: parent.Metadata.StartIndex + start,
+				EndIndex:   parent.Metadata.StartIndex + end,
+				Section:    parent.Metadata.Section,
+				ChunkType:  ChunkTypeText,
+				ParentID:   parent.ID,
+				Timestamp:  time.Now(),
+			},
+		})
+		if last == len(spans)-1 {
+			break
+		}
+	}
+	return children
+}
+
+// ExpandToParents replaces each matched child with its parent for small-to-big retrieval. Matches
+// are expected best first; a parent keeps the score of its best child and appears only once.
+// Matches without a parent found in chunks are returned unchanged.
+func ExpandToParents(matches, chunks []models.TextChunk) []models.TextChunk {
+	byID := make(map[string]models.TextChunk, len(chunks))
+	for _, chunk := range chunks {
+		byID[chunk.ID] = chunk
+	}
+
+	seen := make(map[string]bool)
+	expanded := make([]models.TextChunk, 0, len(matches))
+	for _, match := range matches {
+		result := match
+		if parent, ok := byID[match.Metadata.ParentID]; ok && match.Metadata.ParentID != "" {
+			parent.Score = match.Score
+			result = parent
+		}
+		if seen[result.ID] {
+			continue
+		}
+		seen[result.ID] = true
+		expanded = append(expanded, result)
+	}
+	return expanded
+}
+
+// pruneChildlessParents drops parents whose children were all filtered out and forgets removed children
+func pruneChildlessParents(chunks []models.TextChunk) []models.TextChunk {
+	present := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		present[chunk.ID] = true
+	}
+
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if chunk.Metadata.ChunkType == ChunkTypeParent {
+			var childIDs []string
+			for _, id := range chunk.Metadata.ChildIDs {
+				if present[id] {
+					childIDs = append(childIDs, id)
+				}
+			}
+			if len(childIDs) == 0 {
+				continue
+			}
+			chunk.Metadata.ChildIDs = childIDs
+		}
+		kept = append(kept, chunk)
+	}
+	return kept
+}
@@ -0,0 +1,165 @@
+package processors
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlBlockOpen   = regexp.MustCompile(`(?i)<(h[1-6]|p|ul|ol|table|pre|blockquote|figcaption|caption)\b[^>]*>`)
+	htmlIgnored     = regexp.MustCompile(`(?is)<(script|style|noscript|nav|footer|head)\b.*?</(script|style|noscript|nav|footer|head)>|<!--.*?-->`)
+	htmlListItem    = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	htmlTableRow    = regexp.MustCompile(`(?i)<tr\b[^>]*>`)
+	htmlTableCell   = regexp.MustCompile(`(?is)<(th|td)\b[^>]*>(.*?)</(?:th|td)>`)
+	htmlLineBreak   = regexp.MustCompile(`(?i)<br\s*/?>`)
+	tableCellEscape = strings.NewReplacer("|", "\\|")
+)
+
+// ParseHTML splits an HTML document into nested sections along its <h1>-<h6> headings, keeping
+// lists and tables as single blocks rendered in Markdown form
+func ParseHTML(document string) []StructuredSection {
+	// Blank out ignored elements so offsets into the original document stay valid
+	document = htmlIgnored.ReplaceAllStringFunc(document, func(s string) string { return strings.Repeat(" ", len(s)) })
+	lower := strings.ToLower(document)
+
+	var sections []StructuredSection
+	current := StructuredSection{}
+	headings := &headingStack{}
+
+	addLoose := func(text string, offset int) {
+		if loose := stripTags(text); loose != "" {
+			current.Blocks = append(current.Blocks, Block{Kind: BlockParagraph, Text: loose, Offset: offset})
+		}
+	}
+
+	position := 0
+	for position < len(document) {
+		loc := htmlBlockOpen.FindStringSubmatchIndex(document[position:])
+		if loc == nil {
+			break
+		}
+		start, openEnd := position+loc[0], position+loc[1]
+		name := strings.ToLower(document[position+loc[2] : position+loc[3]])
+		innerEnd, end := findClosingTag(lower, name, openEnd)
+
+		// Paragraphs are often left unclosed; the next block element ends them
+		if name == "p" {
+			if next := htmlBlockOpen.FindStringIndex(document[openEnd:innerEnd]); next != nil {
+				innerEnd, end = openEnd+next[0], openEnd+next[0]
+			}
+		}
+		inner := document[openEnd:innerEnd]
+
+		// Text outside the recognised elements is kept as prose
+		addLoose(document[position:start], position)
+
+		switch {
+		case name[0] == 'h' && len(name) == 2:
+			title := stripTags(inner)
+			if title == "" {
+				break
+			}
+			if len(current.Blocks) > 0 {
+				sections = append(sections, current)
+			}
+			level := int(name[1] - '0')
+			headings.push(level, title)
+			current = StructuredSection{Path: headings.path(), Level: level}
+		case name == "ul" || name == "ol":
+			current.Blocks = append(current.Blocks, Block{Kind: BlockList, Text: renderHTMLList(inner, name == "ol"), Offset: start})
+		case name == "table":
+			current.Blocks = append(current.Blocks, Block{Kind: BlockTable, Text: renderHTMLTable(inner), Offset: start})
+		case name == "pre":
+			code := html.UnescapeString(tagPattern.ReplaceAllString(inner, ""))
+			current.Blocks = append(current.Blocks, Block{Kind: BlockCode, Text: strings.Trim(code, "\n"), Offset: start})
+		default:
+			paragraph := stripTags(htmlLineBreak.ReplaceAllString(inner, "\n"))
+			if paragraph != "" {
+				current.Blocks = append(current.Blocks, Block{Kind: BlockParagraph, Text: paragraph, Offset: start})
+			}
+		}
+		position = end
+	}
+	addLoose(document[position:], position)
+
+	if len(current.Blocks) > 0 {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// findClosingTag returns where the element's content ends and where its closing tag ends,
+// accounting for nested elements of the same name. lower is the lowercased document.
+func findClosingTag(lower, name string, from int) (int, int) {
+	open, closing := "<"+name, "</"+name
+	depth := 1
+	for i := from; i < len(lower); {
+		nextOpen := indexTag(lower, open, i)
+		nextClose := indexTag(lower, closing, i)
+		if nextClose == -1 {
+			break
+		}
+		if nextOpen != -1 && nextOpen < nextClose {
+			depth++
+			i = nextOpen + len(open)
+			continue
+		}
+		depth--
+		closeEnd := strings.IndexByte(lower[nextClose:], '>')
+		if closeEnd == -1 {
+			return nextClose, len(lower)
+		}
+		if depth == 0 {
+			return nextClose, nextClose + closeEnd + 1
+		}
+		i = nextClose + closeEnd + 1
+	}
+	// Unclosed element: it runs to the end of the document
+	return len(lower), len(lower)
+}
+
+// indexTag finds the next "<name" or "</name" at or after from whose tag name ends there
+func indexTag(s, prefix string, from int) int {
+	for from < len(s) {
+		i := strings.Index(s[from:], prefix)
+		if i == -1 {
+			return -1
+		}
+		if isTagNameEnd(s, from+i+len(prefix)) {
+			return from + i
+		}
+		from += i + len(prefix)
+	}
+	return -1
+}
+
+// isTagNameEnd reports whether the tag name ends at i, so "<p" does not match "<pre"
+func isTagNameEnd(s string, i int) bool {
+	return i >= len(s) || s[i] == '>' || s[i] == ' ' || s[i] == '/' || s[i] == '\t' || s[i] == '\n'
+}
+
+// renderHTMLList renders list items as Markdown bullets or numbers
+func renderHTMLList(inner string, ordered bool) string {
+	items := htmlListItem.Split(inner, -1)[1:]
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		text := stripTags(item)
+		if text == "" {
+			continue
+		}
+		if ordered {
+			lines = append(lines, fmt.Sprintf("%d. %s", len(lines)+1, text))
+		} else {
+			lines = append(lines, "- "+text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderHTMLTable renders table rows as a Markdown pipe table, with a separator after a header row
+func renderHTMLTable(inner string) string {
+	header, rows := parseHTMLTableRows(inner)
+	return renderMarkdownTable(header, rows)
+}
@@ -0,0 +1,58 @@
+package processors
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	jatsAbstractPattern = regexp.MustCompile(`(?is)<abstract[^>]*>(.*?)</abstract>`)
+	jatsSecTitlePattern = regexp.MustCompile(`(?is)<sec\b[^>]*>\s*<title[^>]*>(.*?)</title>`)
+	jatsTitlePattern    = regexp.MustCompile(`(?is)<article-title[^>]*>(.*?)</article-title>`)
+	tagPattern          = regexp.MustCompile(`<[^>]+>`)
+)
+
+// ExtractJATSSections turns a JATS XML article into sections, one per titled <sec>
+func ExtractJATSSections(text string) []Section {
+	var sections []Section
+
+	// The abstract sits in the front matter, outside the body sections
+	if match := jatsAbstractPattern.FindStringSubmatch(text); match != nil {
+		if content := stripTags(match[1]); content != "" {
+			sections = append(sections, Section{Title: "Abstract", Content: content, Type: "JATS"})
+		}
+	}
+
+	matches := jatsSecTitlePattern.FindAllStringSubmatchIndex(text, -1)
+	for i, match := range matches {
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		title := stripTags(text[match[2]:match[3]])
+		content := stripTags(text[match[1]:end])
+		if title == "" || content == "" {
+			continue
+		}
+		sections = append(sections, Section{Title: title, Content: content, Type: "JATS"})
+	}
+
+	// Without any <sec> elements fall back to the whole body as one section
+	if len(matches) == 0 {
+		body := jatsAbstractPattern.ReplaceAllString(text, " ")
+		body = jatsTitlePattern.ReplaceAllString(body, " ")
+		if content := stripTags(body); content != "" {
+			sections = append(sections, Section{Title: "Body", Content: content, Type: "JATS"})
+		}
+	}
+
+	return sections
+}
+
+// stripTags removes markup, decodes entities and collapses whitespace
+func stripTags(text string) string {
+	text = tagPattern.ReplaceAllString(text, " ")
+	text = html.UnescapeString(text)
+	return strings.Join(strings.Fields(text), " ")
+}
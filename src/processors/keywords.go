@@ -0,0 +1,216 @@
+package processors
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	models "my-modus-app/src/schemas"
+)
+
+// defaultKeywordLimit is how many statistical keywords are kept per chunk
+const defaultKeywordLimit = 8
+
+var (
+	// phraseDelimiter splits text into candidate phrases at punctuation
+	phraseDelimiter = regexp.MustCompile(`[.,;:!?()\[\]{}"“”/\\|]+|\s[-–—]\s`)
+	keywordWord     = regexp.MustCompile(`^[\p{L}][\p{L}\p{N}'’-]*$`)
+)
+
+// stopwords break candidate phrases apart in RAKE
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`a about above after again against all also although am among an and any are as at
+		be because been before being below between both but by can could did do does doing done down during each either
+		et al few for from further had has have having he her here hers him his how however i if in into is it its itself
+		may might more most much must my no nor not of off on once only or other our ours out over own per same she should
+		since so some such than that the their them then there these they this those through thus to too under until up
+		upon us used using very via was we were what when where whether which while who whom why will with within without
+		would yet you your compared among across based including respectively showed found study studies patients results
+		conclusion conclusions background methods objective aim aims purpose`) {
+		stopwords[word] = true
+	}
+}
+
+// KeywordExtractor scores RAKE key phrases by their inverse document frequency over a corpus of chunks
+type KeywordExtractor struct {
+	documentFrequency map[string]int
+	documents         int
+	limit             int
+}
+
+// NewKeywordExtractor builds the corpus statistics from the given chunks
+func NewKeywordExtractor(corpus []models.TextChunk, limit int) *KeywordExtractor {
+	if limit <= 0 {
+		limit = defaultKeywordLimit
+	}
+	ke := &KeywordExtractor{documentFrequency: make(map[string]int), limit: limit}
+	for _, chunk := range corpus {
+		ke.AddDocument(chunk.Content)
+	}
+	return ke
+}
+
+// AddDocument adds one more document to the corpus statistics
+func (ke *KeywordExtractor) AddDocument(text string) {
+	ke.documents++
+	seen := make(map[string]bool)
+	for _, phrase := range candidatePhrases(text) {
+		key := strings.Join(phrase, " ")
+		if !seen[key] {
+			seen[key] = true
+			ke.documentFrequency[key]++
+		}
+	}
+}
+
+// Extract returns the top key phrases of text, best first
+func (ke *KeywordExtractor) Extract(text string) []string {
+	phrases := candidatePhrases(text)
+	if len(phrases) == 0 {
+		return nil
+	}
+
+	// RAKE word scores: degree / frequency
+	frequency := make(map[string]float64)
+	degree := make(map[string]float64)
+	for _, phrase := range phrases {
+		for _, word := range phrase {
+			frequency[word]++
+			degree[word] += float64(len(phrase))
+		}
+	}
+
+	scores := make(map[string]float64)
+	for _, phrase := range phrases {
+		key := strings.Join(phrase, " ")
+		if _, done := scores[key]; done {
+			continue
+		}
+		rake := 0.0
+		for _, word := range phrase {
+			rake += degree[word] / frequency[word]
+		}
+		scores[key] = rake * ke.idf(key)
+	}
+
+	keywords := make([]string, 0, len(scores))
+	for key := range scores {
+		keywords = append(keywords, key)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if scores[keywords[i]] != scores[keywords[j]] {
+			return scores[keywords[i]] > scores[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+	if len(keywords) > ke.limit {
+		keywords = keywords[:ke.limit]
+	}
+	return keywords
+}
+
+// idf is the smoothed inverse document frequency of a phrase
+func (ke *KeywordExtractor) idf(phrase string) float64 {
+	return math.Log(float64(ke.documents+1)/float64(ke.documentFrequency[phrase]+1)) + 1
+}
+
+// candidatePhrases splits text into lowercase phrases of content words
+func candidatePhrases(text string) [][]string {
+	var phrases [][]string
+	for _, fragment := range phraseDelimiter.Split(strings.ToLower(text), -1) {
+		var current []string
+		for _, word := range strings.Fields(fragment) {
+			word = strings.Trim(word, "'’-")
+			if stopwords[word] || !keywordWord.MatchString(word) || len([]rune(word)) < 3 {
+				if len(current) > 0 {
+					phrases = append(phrases, current)
+					current = nil
+				}
+				continue
+			}
+			current = append(current, word)
+		}
+		if len(current) > 0 {
+			phrases = append(phrases, current)
+		}
+	}
+
+	// Very long runs are rarely useful keywords
+	filtered := phrases[:0]
+	for _, phrase := range phrases {
+		if len(phrase) <= 4 {
+			filtered = append(filtered, phrase)
+		}
+	}
+	return filtered
+}
+
+// MatchMeSHTerms returns the MeSH headings whose words all occur in text
+func MatchMeSHTerms(text string, meshTerms []string) []string {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		words[word] = true
+	}
+
+	var matched []string
+	for _, term := range meshTerms {
+		heading := NormalizeMeSHHeading(term)
+		if heading == "" {
+			continue
+		}
+		headingWords := strings.FieldsFunc(strings.ToLower(heading), isWordSeparator)
+		all := len(headingWords) > 0
+		for _, word := range headingWords {
+			if !words[word] {
+				all = false
+				break
+			}
+		}
+		if all {
+			matched = append(matched, heading)
+		}
+	}
+	return matched
+}
+
+// NormalizeMeSHHeading strips MEDLINE qualifiers and major-topic stars, e.g. "Insulin/*therapeutic use" -> "Insulin"
+func NormalizeMeSHHeading(term string) string {
+	if i := strings.Index(term, "/"); i != -1 {
+		term = term[:i]
+	}
+	return strings.TrimSpace(strings.Trim(term, "* "))
+}
+
+// isWordSeparator splits on anything that is not a letter, digit or hyphen
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+}
+
+// AnnotateKeywords fills each chunk's Keywords with its statistical key phrases plus any matching
+// MeSH headings. The extractor holds the corpus statistics; nil builds them from the chunks alone.
+func AnnotateKeywords(chunks []models.TextChunk, extractor *KeywordExtractor, meshTerms []string) {
+	if extractor == nil {
+		extractor = NewKeywordExtractor(chunks, defaultKeywordLimit)
+	}
+	for i := range chunks {
+		keywords := extractor.Extract(chunks[i].Content)
+		for _, heading := range MatchMeSHTerms(chunks[i].Content, meshTerms) {
+			keywords = appendUnique(keywords, heading)
+		}
+		chunks[i].Metadata.Keywords = keywords
+	}
+}
+
+// appendUnique appends value unless it is already present, ignoring case
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
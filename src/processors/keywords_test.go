@@ -0,0 +1,29 @@
+package processors
+
+import (
+	"testing"
+
+	models "my-modus-app/src/schemas"
+)
+
+func TestAnnotateKeywordsWeighsPhrasesAgainstTheSharedCorpus(t *testing.T) {
+	// Every article mentions the trial; only the first mentions the statin
+	first := []models.TextChunk{{Content: "Randomized controlled trial. Atorvastatin therapy."}}
+	corpus := append([]models.TextChunk{}, first...)
+	for _, therapy := range []string{"Exercise", "Diet", "Aspirin", "Metformin", "Insulin"} {
+		corpus = append(corpus, models.TextChunk{Content: "Randomized controlled trial. " + therapy + " therapy."})
+	}
+
+	// Alone, each chunk is its whole corpus and the longer phrase ranks first
+	AnnotateKeywords(first, nil, nil)
+	if got := first[0].Metadata.Keywords; len(got) == 0 || got[0] != "randomized controlled trial" {
+		t.Fatalf("per-article keywords: got %v", got)
+	}
+
+	// Against every article, the phrase they share ranks below the distinctive one
+	extractor := NewKeywordExtractor(corpus, 0)
+	AnnotateKeywords(first, extractor, nil)
+	if got := first[0].Metadata.Keywords; len(got) == 0 || got[0] != "atorvastatin therapy" {
+		t.Errorf("shared-corpus keywords: got %v", got)
+	}
+}
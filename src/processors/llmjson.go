@@ -0,0 +1,319 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var codeFencePattern = regexp.MustCompile("```[A-Za-z]*")
+
+// ExtractJSONArray pulls the first JSON array out of an LLM response. It ignores code fences and
+// surrounding prose, drops trailing commas and, when the output was truncated, closes the array
+// after the last complete element. A "[" in the prose that does not start a valid array is skipped.
+func ExtractJSONArray(raw string) (string, error) {
+	cleaned := codeFencePattern.ReplaceAllString(raw, "")
+
+	err := fmt.Errorf("no JSON array found in model output")
+	for offset := 0; ; {
+		start := strings.Index(cleaned[offset:], "[")
+		if start == -1 {
+			return "", err
+		}
+		offset += start
+
+		var array string
+		if array, err = repairJSONArray(cleaned[offset:]); err == nil {
+			var elements []json.RawMessage
+			if err = json.Unmarshal([]byte(array), &elements); err == nil {
+				return array, nil
+			}
+			err = fmt.Errorf("invalid JSON array in model output: %w", err)
+		}
+		offset++
+	}
+}
+
+// repairJSONArray reads the array that cleaned starts with, dropping trailing commas and closing it
+// after the last complete element when it was truncated
+func repairJSONArray(cleaned string) (string, error) {
+	var out strings.Builder
+	var stack []byte
+	inString, escaped := false, false
+	lastComplete := -1 // Length of out after the last complete top-level element
+
+	for i := 0; i < len(cleaned); i++ {
+		ch := cleaned[i]
+
+		if inString {
+			out.WriteByte(ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+				if len(stack) == 1 {
+					lastComplete = out.Len()
+				}
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '[', '{':
+			stack = append(stack, ch)
+		case ']', '}':
+			if len(stack) == 0 {
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			out.WriteByte(ch)
+			if len(stack) == 0 {
+				return out.String(), nil
+			}
+			if len(stack) == 1 {
+				lastComplete = out.Len()
+			}
+			continue
+		case ',':
+			// Skip trailing commas before a closing bracket
+			if next := nextNonSpace(cleaned, i+1); next == ']' || next == '}' {
+				continue
+			}
+		}
+		out.WriteByte(ch)
+	}
+
+	// The output was truncated: keep only the complete elements
+	if lastComplete == -1 {
+		return "", fmt.Errorf("model output was truncated before the first complete element")
+	}
+	repaired := strings.TrimRightFunc(out.String()[:lastComplete], unicode.IsSpace)
+	return strings.TrimSuffix(repaired, ",") + "]", nil
+}
+
+// nextNonSpace returns the next non-whitespace byte at or after i, or 0 at the end of s
+func nextNonSpace(s string, i int) byte {
+	for ; i < len(s); i++ {
+		if !unicode.IsSpace(rune(s[i])) {
+			return s[i]
+		}
+	}
+	return 0
+}
+
+// ValidateSections checks every section the model returned has a title and content, and that the
+// content really comes from source rather than being invented or paraphrased
+func ValidateSections(sections []Section, source string) ([]Section, error) {
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("model returned no sections")
+	}
+
+	index := newSourceIndex(source)
+	valid := make([]Section, 0, len(sections))
+	for i, section := range sections {
+		section.Title = strings.TrimSpace(section.Title)
+		section.Content = strings.TrimSpace(section.Content)
+		section.Type = strings.TrimSpace(section.Type)
+
+		if section.Title == "" {
+			return nil, fmt.Errorf("section %d has no title", i+1)
+		}
+		if section.Content == "" {
+			return nil, fmt.Errorf("section %d (%s) has no content", i+1, section.Title)
+		}
+		if section.Type == "" {
+			section.Type = "Miscellaneous"
+		}
+		if coverage := index.coverage(section.Content); coverage < minSourceCoverage {
+			return nil, fmt.Errorf("section %d (%s) does not match the source text (%.0f%% of words found in order)", i+1, section.Title, coverage*100)
+		}
+		valid = append(valid, section)
+	}
+	return valid, nil
+}
+
+// anchorWords is how many of a section's first words must occur together to locate it in the source
+const anchorWords = 3
+
+// LocateSections maps sections echoed from a cleaned copy of source back onto source itself. Each
+// section is found by its first words after the previous one, and runs until the next section
+// starts, so the sections cover source without gaps and their Offset indexes it. Text before the
+// first section becomes a "Preamble" section.
+func LocateSections(source string, sections []Section) ([]Section, error) {
+	words := matchWordSpans(source)
+	positions := make(map[string][]int)
+	for i, word := range words {
+		positions[word.word] = append(positions[word.word], i)
+	}
+
+	starts := make([]int, len(sections))
+	cursor := 0
+	for i, section := range sections {
+		sectionWords := matchWords(section.Content)
+		found := -1
+		// A word the cleaner changed, such as a joined hyphenation, moves the anchor to the next words
+		for first := 0; first < len(sectionWords) && first < anchorWords && found < 0; first++ {
+			anchor := sectionWords[first:min(first+anchorWords, len(sectionWords))]
+			for _, position := range positions[anchor[0]] {
+				if position >= cursor && wordsAt(words, position, anchor) {
+					found = position
+					break
+				}
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("section %d (%s) could not be located in the source text", i+1, section.Title)
+		}
+		starts[i] = words[found].start
+		cursor = found + 1
+	}
+
+	located := make([]Section, 0, len(sections)+1)
+	if len(starts) > 0 {
+		preamble := strings.TrimRightFunc(source[:starts[0]], unicode.IsSpace)
+		if content := strings.TrimLeftFunc(preamble, unicode.IsSpace); content != "" {
+			located = append(located, Section{Title: "Preamble", Type: "Miscellaneous", Content: content, Offset: len(preamble) - len(content)})
+		}
+	}
+	for i, section := range sections {
+		end := len(source)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		section.Content = strings.TrimRightFunc(source[starts[i]:end], unicode.IsSpace)
+		section.Offset = starts[i]
+		if section.Content == "" {
+			continue
+		}
+		located = append(located, section)
+	}
+	return located, nil
+}
+
+// wordSpan is a lowercase word of a text and the byte offset it starts at
+type wordSpan struct {
+	word  string
+	start int
+}
+
+// matchWordSpans splits text into words like matchWords, keeping where each starts
+func matchWordSpans(text string) []wordSpan {
+	var spans []wordSpan
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, wordSpan{strings.ToLower(text[start:i]), start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{strings.ToLower(text[start:]), start})
+	}
+	return spans
+}
+
+// maxAnchorGap is how many source words, such as markup or entities the cleaner removed, may
+// come between two words of an anchor
+const maxAnchorGap = 2
+
+// wordsAt reports whether the anchor's words follow each other from position on, allowing a few
+// source words between them
+func wordsAt(words []wordSpan, position int, anchor []string) bool {
+	for i, word := range anchor {
+		for skipped := 0; position < len(words) && words[position].word != word; skipped++ {
+			if i == 0 || skipped == maxAnchorGap {
+				return false
+			}
+			position++
+		}
+		if position == len(words) {
+			return false
+		}
+		position++
+	}
+	return true
+}
+
+// minSourceCoverage is the share of a section's words that must be found, in order, in the source text
+const minSourceCoverage = 0.9
+
+// maxSpanStretch bounds how far the matched words may spread in the source, relative to the
+// section's length, so words picked from all over the source do not count as a copy
+const maxSpanStretch = 1.2
+
+// maxSpanStarts bounds the occurrences of a section's first word tried as the start of its span
+const maxSpanStarts = 50
+
+// sourceIndex records where each word occurs in the source, built once for all sections
+type sourceIndex struct {
+	normalized string
+	positions  map[string][]int
+}
+
+func newSourceIndex(source string) *sourceIndex {
+	index := &sourceIndex{normalized: normalizeForMatch(source), positions: make(map[string][]int)}
+	for i, word := range matchWords(source) {
+		index.positions[word] = append(index.positions[word], i)
+	}
+	return index
+}
+
+// coverage returns the share of content's words found as an ordered span of the source. Content
+// that reuses the source's words in another order, or scattered across it, scores low.
+func (index *sourceIndex) coverage(content string) float64 {
+	if strings.Contains(index.normalized, normalizeForMatch(content)) {
+		return 1
+	}
+	words := matchWords(content)
+	if len(words) == 0 {
+		return 0
+	}
+
+	// Try the span starting at each occurrence of one of the first words, keeping the best
+	best := 0
+	for first := 0; first < len(words) && first <= len(words)-int(float64(len(words))*minSourceCoverage); first++ {
+		starts := index.positions[words[first]]
+		for _, start := range starts[:min(len(starts), maxSpanStarts)] {
+			best = max(best, index.orderedMatches(words[first:], start))
+		}
+	}
+	return float64(best) / float64(len(words))
+}
+
+// orderedMatches counts the words found in order after start, within the allowed span
+func (index *sourceIndex) orderedMatches(words []string, start int) int {
+	limit := start + int(float64(len(words))*maxSpanStretch) + 1
+	matched, position := 1, start
+	for _, word := range words[1:] {
+		positions := index.positions[word]
+		next := sort.SearchInts(positions, position+1)
+		if next < len(positions) && positions[next] < limit {
+			position = positions[next]
+			matched++
+		}
+	}
+	return matched
+}
+
+// matchWords splits text into lowercase words, ignoring punctuation
+func matchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalizeForMatch lowercases text and collapses whitespace so formatting differences are ignored
+func normalizeForMatch(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,84 @@
+package processors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractJSONArray(t *testing.T) {
+	tests := []struct {
+		name, raw, want string
+	}{
+		{"fenced", "```json\n[{\"a\": 1}]\n```", `[{"a": 1}]`},
+		{"bracket in prose", "Sections [as requested] follow: [{\"a\": 1}]", `[{"a": 1}]`},
+		{"trailing comma", `[{"a": 1},]`, `[{"a": 1}]`},
+		{"truncated", `[{"a": 1}, {"a": 2}, {"a"`, `[{"a": 1}, {"a": 2}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ExtractJSONArray(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	if _, err := ExtractJSONArray("no array [here] at all"); err == nil {
+		t.Error("expected an error when no candidate decodes")
+	}
+}
+
+func TestValidateSectionsRequiresOrderedSourceText(t *testing.T) {
+	source := "Background. Obesity raises the risk of diabetes in adults. Methods. We followed 200 adults for two years and measured glucose every month."
+
+	copied := []Section{{Title: "Methods", Content: "We followed 200 adults for two years, and measured glucose every month."}}
+	if _, err := ValidateSections(copied, source); err != nil {
+		t.Errorf("copied section rejected: %v", err)
+	}
+
+	reordered := []Section{{Title: "Methods", Content: "Every month we measured glucose and followed adults for two years 200."}}
+	if _, err := ValidateSections(reordered, source); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("reordered section accepted: %v", err)
+	}
+}
+
+func TestLocateSectionsMapsCleanedSectionsOntoTheSource(t *testing.T) {
+	source := "Title page\n\n<p>Obesity &amp; diabetes are common in adults.</p>\n\n" +
+		"<p>We followed 200 adults for two years.</p> Glucose was measured monthly."
+	cleaned, err := CleanText(source, ProfilePrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := []Section{
+		{Title: "Background", Content: cleaned[strings.Index(cleaned, "Obesity"):strings.Index(cleaned, "We followed")]},
+		{Title: "Methods", Content: cleaned[strings.Index(cleaned, "We followed"):]},
+	}
+
+	located, err := LocateSections(source, sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ title, start string }{
+		{"Preamble", "Title page"},
+		{"Background", "Obesity &amp; diabetes"},
+		{"Methods", "We followed 200 adults for two years.</p> Glucose"},
+	}
+	if len(located) != len(want) {
+		t.Fatalf("got %d sections, want %d: %+v", len(located), len(want), located)
+	}
+	for i, section := range located {
+		if section.Title != want[i].title || !strings.HasPrefix(section.Content, want[i].start) {
+			t.Errorf("section %d is %s %q, want %s starting %q", i, section.Title, section.Content, want[i].title, want[i].start)
+		}
+		if source[section.Offset:section.Offset+len(section.Content)] != section.Content {
+			t.Errorf("section %s offset %d does not index the source", section.Title, section.Offset)
+		}
+	}
+
+	if _, err := LocateSections(source, []Section{{Title: "Invented", Content: "Insulin doses were doubled."}}); err == nil {
+		t.Error("expected an error locating a section missing from the source")
+	}
+}
@@ -0,0 +1,188 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	models "my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+)
+
+const (
+	// llmWindowSize is the largest amount of source text, in bytes, sent to the model in one request
+	llmWindowSize = 6000
+	// llmWindowOverlap is how many sentences each window repeats from the end of the previous one,
+	// so a section continuing across the cut is not mistaken for a new one
+	llmWindowOverlap = 3
+)
+
+// sectionAnchor is what the model returns for each section: no content, only where it starts
+type sectionAnchor struct {
+	Title         string `json:"Title"`
+	Type          string `json:"Type"`
+	StartSentence int    `json:"StartSentence"`
+}
+
+const offsetInstruction = `
+You are a scientific document parsing expert. You will receive a document split into numbered sentences.
+Identify where each logical section (e.g. Introduction, Methods, Results, Discussion, Conclusion) starts.
+
+Return only a JSON array, with no other text, in the following format:
+
+[
+  {
+    "Title": "<Title of the section>",
+    "Type": "<Type of the section>",
+    "StartSentence": <number of the first sentence of the section>
+  }
+]
+
+Rules:
+- Do not repeat or rewrite the text of the sentences.
+- List sections in ascending order of StartSentence.
+- Only use sentence numbers that appear in the input.
+- The first sentences may continue a section that began before them; only mark where a new section begins.
+`
+
+// LLMOffsetChunking asks the model for section titles, types and starting sentence numbers only,
+// then slices the original text at those boundaries. Long documents are sent in windows.
+func LLMOffsetChunking(text string, config ChunkingConfig, modelName string) ([]models.TextChunk, error) {
+	model := utils.GetChatModel(modelName)
+
+	spans := SplitSentenceSpans(text)
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("no sentences found in text")
+	}
+
+	// Collect anchors from every window; a failed window simply continues the previous section.
+	// Where windows overlap, the earlier window saw more context, so its anchors win and the later
+	// window's are dropped; this also keeps each window's first sentence from becoming a boundary.
+	var anchors []sectionAnchor
+	windows := sentenceWindows(spans, llmWindowSize, llmWindowOverlap)
+	for w, window := range windows {
+		var windowAnchors []sectionAnchor
+		var err error
+		for attempt := 1; attempt <= maxLLMAttempts; attempt++ {
+			windowAnchors, err = requestSectionAnchors(model, text, spans, window[0], window[1])
+			if err == nil {
+				break
+			}
+			log.Printf("LLM offset chunking of sentences %d-%d, attempt %d/%d failed: %v", window[0], window[1]-1, attempt, maxLLMAttempts, err)
+		}
+		for _, anchor := range windowAnchors {
+			if w > 0 && anchor.StartSentence < windows[w-1][1] {
+				continue
+			}
+			anchors = append(anchors, anchor)
+		}
+	}
+
+	if len(anchors) == 0 {
+		log.Printf("Falling back to rule-based chunking: model returned no section boundaries")
+		return NewChunker(config).ProcessText(text, config.MaxChunkSize, config.MinChunkSize, config.ChunkOverlap, config.PreserveParagraphs, config.PreserveSentences)
+	}
+
+	return chunkSections(sectionsFromAnchors(text, spans, anchors), config)
+}
+
+// sentenceWindows groups consecutive sentences into [first, last) windows of at most maxBytes, each
+// starting up to overlap sentences before the end of the previous one
+func sentenceWindows(spans []SentenceSpan, maxBytes, overlap int) [][2]int {
+	var windows [][2]int
+	first := 0
+	for i, span := range spans {
+		if i > first && span.End-spans[first].Start > maxBytes {
+			windows = append(windows, [2]int{first, i})
+			first = max(first+1, i-overlap)
+		}
+	}
+	return append(windows, [2]int{first, len(spans)})
+}
+
+// requestSectionAnchors asks the model for the section starts among sentences [first, last)
+func requestSectionAnchors(model utils.ChatModel, text string, spans []SentenceSpan, first, last int) ([]sectionAnchor, error) {
+	// Sentences are cleaned for the prompt only; boundaries still index the original spans
+	cleaner, err := NewTextCleanerForProfile(ProfilePrompt)
+	if err != nil {
+		return nil, err
+	}
+	var prompt strings.Builder
+	prompt.WriteString("Here are the numbered sentences of the document:\n\n")
+	for i := first; i < last; i++ {
+		sentence, _ := cleaner.Clean(text[spans[i].Start:spans[i].End])
+		fmt.Fprintf(&prompt, "[%d] %s\n", i, sentence)
+	}
+
+	content, err := model.Complete(utils.ChatRequest{
+		System:      offsetInstruction,
+		User:        prompt.String(),
+		Temperature: 0.1,
+		MaxTokens:   1024,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cleanedOutput, err := ExtractJSONArray(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var anchors []sectionAnchor
+	if err := json.Unmarshal([]byte(cleanedOutput), &anchors); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	// Keep only anchors that point inside this window
+	valid := make([]sectionAnchor, 0, len(anchors))
+	for _, anchor := range anchors {
+		anchor.Title = strings.TrimSpace(anchor.Title)
+		if anchor.Title == "" || anchor.StartSentence < first || anchor.StartSentence >= last {
+			continue
+		}
+		if strings.TrimSpace(anchor.Type) == "" {
+			anchor.Type = "Miscellaneous"
+		}
+		valid = append(valid, anchor)
+	}
+	if len(valid) == 0 && len(anchors) > 0 {
+		return nil, fmt.Errorf("model returned %d anchors, none inside sentences %d-%d", len(anchors), first, last-1)
+	}
+	return valid, nil
+}
+
+// sectionsFromAnchors slices the original text at the anchored sentences
+func sectionsFromAnchors(text string, spans []SentenceSpan, anchors []sectionAnchor) []Section {
+	sort.SliceStable(anchors, func(i, j int) bool { return anchors[i].StartSentence < anchors[j].StartSentence })
+
+	// Drop duplicate starts and make sure the text before the first anchor is not lost
+	deduped := make([]sectionAnchor, 0, len(anchors)+1)
+	if anchors[0].StartSentence > 0 {
+		deduped = append(deduped, sectionAnchor{Title: "Preamble", Type: "Miscellaneous", StartSentence: 0})
+	}
+	for _, anchor := range anchors {
+		if len(deduped) > 0 && deduped[len(deduped)-1].StartSentence == anchor.StartSentence {
+			continue
+		}
+		deduped = append(deduped, anchor)
+	}
+
+	sections := make([]Section, 0, len(deduped))
+	for i, anchor := range deduped {
+		lastSentence := len(spans) - 1
+		if i+1 < len(deduped) {
+			lastSentence = deduped[i+1].StartSentence - 1
+		}
+		start, end := spans[anchor.StartSentence].Start, spans[lastSentence].End
+		sections = append(sections, Section{
+			Title:   anchor.Title,
+			Content: text[start:end],
+			Type:    anchor.Type,
+			Offset:  start,
+		})
+	}
+	return sections
+}
@@ -0,0 +1,112 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"my-modus-app/src/utils"
+	"my-modus-app/src/utils/utilstest"
+)
+
+// windowStartModel anchors a section at the first sentence of every window it is shown, as a
+// model without the earlier context tends to
+func windowStartModel() *utilstest.ScriptedChatModel {
+	model := utilstest.NewScriptedChatModel("offsets-window-start")
+	calls := 0
+	model.Respond = func(request utils.ChatRequest) (string, error) {
+		calls++
+		first := regexp.MustCompile(`\[(\d+)\]`).FindStringSubmatch(request.User)[1]
+		return fmt.Sprintf(`[{"Title": "Section %d", "Type": "Body", "StartSentence": %s}]`, calls, first), nil
+	}
+	return model
+}
+
+func TestSentenceWindowsOverlap(t *testing.T) {
+	spans := SplitSentenceSpans(strings.Repeat("Each sentence here is about forty bytes. ", 20))
+	windows := sentenceWindows(spans, 200, 2)
+	if len(windows) < 2 {
+		t.Fatalf("expected several windows, got %v", windows)
+	}
+	for i := 1; i < len(windows); i++ {
+		if windows[i][0] != windows[i-1][1]-2 {
+			t.Errorf("window %d starts at %d, previous ends at %d", i, windows[i][0], windows[i-1][1])
+		}
+	}
+	if windows[len(windows)-1][1] != len(spans) {
+		t.Errorf("last window ends at %d of %d sentences", windows[len(windows)-1][1], len(spans))
+	}
+}
+
+func TestLLMOffsetChunkingIgnoresWindowStarts(t *testing.T) {
+	model := windowStartModel()
+	utils.RegisterChatModel(model)
+
+	text := strings.Repeat("The trial enrolled adults with type 2 diabetes at four sites. ", 250)
+	chunks, err := LLMOffsetChunking(text, DefaultStrategyConfig().Chunking, model.ModelName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(model.Requests()); calls < 2 {
+		t.Fatalf("expected the text to need several windows, got %d", calls)
+	}
+	for _, chunk := range chunks {
+		if chunk.Metadata.Section != "Section 1" {
+			t.Fatalf("window start became a section boundary: %q", chunk.Metadata.Section)
+		}
+	}
+}
+
+func TestLLMOffsetsStrategyCutsAtScriptedSections(t *testing.T) {
+	model := utilstest.NewScriptedChatModel("offsets-scripted",
+		`[{"Title": "Background", "Type": "Introduction", "StartSentence": 0},
+		  {"Title": "Findings", "Type": "Results", "StartSentence": 2}]`)
+	utils.RegisterChatModel(model)
+
+	text := "Diabetes is common in adults. Few trials compared the two drugs directly. " +
+		"Metformin lowered HbA1c more than placebo. Weight did not change in either group."
+	config := StrategyConfig{Strategy: StrategyLLMOffsets, ModelName: model.ModelName()}
+	chunks, err := ChunkWithStrategy(text, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sections := map[string]string{}
+	for _, chunk := range chunks {
+		sections[chunk.Metadata.Section] += chunk.Content
+	}
+	if !strings.Contains(sections["Background"], "Diabetes") || !strings.Contains(sections["Findings"], "Metformin") {
+		t.Errorf("sections not cut at the scripted sentences: %v", sections)
+	}
+	if requests := model.Requests(); len(requests) != 1 || !strings.Contains(requests[0].User, "[2] Metformin") {
+		t.Errorf("expected one request numbering the sentences, got %d", len(requests))
+	}
+}
+
+func TestLLMStrategyChunksTheOriginalText(t *testing.T) {
+	source := "<p>Obesity &amp; diabetes are common in adults.</p>\n\n<p>We followed 200 adults for two years.</p>"
+	model := utilstest.NewScriptedChatModel("sections-scripted",
+		`[{"Title": "Background", "Type": "Introduction", "Content": "Obesity & diabetes are common in adults."},
+		  {"Title": "Methods", "Type": "Methods", "Content": "We followed 200 adults for two years."}]`)
+	utils.RegisterChatModel(model)
+
+	chunks, err := ChunkWithStrategy(source, StrategyConfig{Strategy: StrategyLLM, ModelName: model.ModelName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests := model.Requests(); len(requests) != 1 || strings.Contains(requests[0].User, "<p>") {
+		t.Fatalf("expected one request with the cleaned text, got %+v", requests)
+	}
+
+	sections := map[string]string{}
+	for _, chunk := range chunks {
+		sections[chunk.Metadata.Section] += chunk.Content
+		if !strings.Contains(source, chunk.Content) || chunk.Metadata.StartIndex != strings.Index(source, chunk.Content) {
+			t.Errorf("chunk %q at %d is not cut from the original text", chunk.Content, chunk.Metadata.StartIndex)
+		}
+	}
+	if !strings.Contains(sections["Background"], "Obesity &amp; diabetes") || !strings.Contains(sections["Methods"], "200 adults") {
+		t.Errorf("sections %v, want the original text of Background and Methods", sections)
+	}
+}
@@ -0,0 +1,109 @@
+package processors
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownHeadingLine = regexp.MustCompile(`^(#{1,6})[ \t]+(.+?)[ \t#]*$`)
+	markdownListItem    = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	markdownTableRow    = regexp.MustCompile(`^\s*\|`)
+	markdownFence       = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+// ParseMarkdown splits a Markdown document into nested sections along its ATX headings, grouping
+// lines into paragraph, list, table and code blocks
+func ParseMarkdown(text string) []StructuredSection {
+	var sections []StructuredSection
+	current := StructuredSection{}
+	headings := &headingStack{}
+
+	var block *Block
+	endBlock := func() {
+		if block != nil {
+			block.Text = strings.TrimRight(block.Text, "\n")
+			current.Blocks = append(current.Blocks, *block)
+			block = nil
+		}
+	}
+	addLine := func(kind, line string, offset int) {
+		if block != nil && block.Kind != kind {
+			endBlock()
+		}
+		if block == nil {
+			block = &Block{Kind: kind, Offset: offset}
+		}
+		block.Text += line + "\n"
+	}
+
+	lines := strings.Split(text, "\n")
+	offset := 0
+	inFence := false
+	for i, line := range lines {
+		lineOffset := offset
+		offset += len(line) + 1
+
+		// Fenced code is copied verbatim up to the closing fence
+		if markdownFence.MatchString(line) {
+			if !inFence {
+				endBlock()
+			}
+			addLine(BlockCode, line, lineOffset)
+			if inFence {
+				endBlock()
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			addLine(BlockCode, line, lineOffset)
+			continue
+		}
+
+		if match := markdownHeadingLine.FindStringSubmatch(line); match != nil {
+			endBlock()
+			if len(current.Blocks) > 0 {
+				sections = append(sections, current)
+			}
+			headings.push(len(match[1]), strings.TrimSpace(match[2]))
+			current = StructuredSection{Path: headings.path(), Level: len(match[1])}
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			// A blank line inside a list only ends it when the list does not continue afterwards
+			if block != nil && block.Kind == BlockList && listContinues(lines[i+1:]) {
+				block.Text += "\n"
+				continue
+			}
+			endBlock()
+		case markdownTableRow.MatchString(line):
+			addLine(BlockTable, line, lineOffset)
+		case markdownListItem.MatchString(line):
+			addLine(BlockList, line, lineOffset)
+		case block != nil && block.Kind == BlockList && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			// Indented lines continue the list item above
+			block.Text += line + "\n"
+		default:
+			addLine(BlockParagraph, line, lineOffset)
+		}
+	}
+	endBlock()
+	if len(current.Blocks) > 0 {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// listContinues reports whether the next non-blank line still belongs to a list
+func listContinues(rest []string) bool {
+	for _, line := range rest {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return markdownListItem.MatchString(line) || strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+	}
+	return false
+}
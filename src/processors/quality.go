@@ -0,0 +1,160 @@
+package processors
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	models "my-modus-app/src/schemas"
+)
+
+// DefaultMinConfidence is the quality score below which chunks are dropped before embedding
+const DefaultMinConfidence = 0.3
+
+// Weights of the quality components; boilerplate is applied as a penalty on top
+const (
+	boundaryWeight = 0.25
+	sizeWeight     = 0.25
+	sectionWeight  = 0.2
+	languageWeight = 0.3
+)
+
+// boilerplatePattern matches copyright, licence, funding and disclosure statements
+var boilerplatePattern = regexp.MustCompile(`(?i)(copyright|©|\(c\) \d{4}|all rights reserved|published by|this article is protected|` +
+	`creative commons|licen[cs]ed under|this (work|study|research) was (supported|funded)|funded by|funding:|grant (no|number)|` +
+	`conflicts? of interest|competing interests?|the authors declare|trial registration|clinicaltrials\.gov|prospero)`)
+
+// genericSectionTitles are the placeholder titles used when no real section was detected
+var genericSectionTitles = []string{"section-", "window-", "document", "preamble", "body", "miscellaneous", AbstractUnassigned}
+
+// ChunkQuality breaks a chunk's confidence score into its components, each between 0 and 1
+type ChunkQuality struct {
+	Boundary    float64 `json:"boundary"`
+	Size        float64 `json:"size"`
+	Section     float64 `json:"section"`
+	Language    float64 `json:"language"`
+	Boilerplate float64 `json:"boilerplate"` // Share of the chunk that is boilerplate
+	Score       float64 `json:"score"`
+}
+
+// ScoreChunk rates a chunk on boundaries, size, section certainty, language and boilerplate
+func ScoreChunk(chunk models.TextChunk, config ChunkingConfig) ChunkQuality {
+	content := strings.TrimSpace(StripOverlap(chunk))
+	quality := ChunkQuality{
+		Boundary:    boundaryScore(content),
+		Size:        sizeScore(len(content), config),
+		Section:     sectionScore(chunk.Metadata.Section),
+		Language:    languageScore(content),
+		Boilerplate: boilerplateShare(content),
+	}
+	weighted := boundaryWeight*quality.Boundary + sizeWeight*quality.Size + sectionWeight*quality.Section + languageWeight*quality.Language
+	quality.Score = weighted * (1 - quality.Boilerplate)
+	return quality
+}
+
+// boundaryScore rewards chunks that start at the beginning of a sentence and end on one
+func boundaryScore(content string) float64 {
+	if content == "" {
+		return 0
+	}
+	score := 0.0
+	if first := []rune(content)[0]; unicode.IsUpper(first) || unicode.IsDigit(first) || !unicode.IsLetter(first) {
+		score += 0.4
+	}
+	if strings.ContainsAny(content[len(content)-1:], ".!?") || strings.HasSuffix(content, ".)") || strings.HasSuffix(content, ".\"") {
+		score += 0.6
+	}
+	return score
+}
+
+// sizeScore is 1 inside [MinChunkSize, MaxChunkSize] and falls off linearly outside it
+func sizeScore(size int, config ChunkingConfig) float64 {
+	switch {
+	case config.MinChunkSize <= 0 || config.MaxChunkSize <= 0:
+		return 1
+	case size < config.MinChunkSize:
+		return float64(size) / float64(config.MinChunkSize)
+	case size > config.MaxChunkSize:
+		return max(0, 1-float64(size-config.MaxChunkSize)/float64(config.MaxChunkSize))
+	}
+	return 1
+}
+
+// sectionScore is 1 for a recognised section and lower for placeholder titles
+func sectionScore(section string) float64 {
+	if section == "" {
+		return 0.3
+	}
+	lower := strings.ToLower(section)
+	for _, generic := range genericSectionTitles {
+		if strings.HasPrefix(lower, strings.ToLower(generic)) {
+			return 0.5
+		}
+	}
+	return 1
+}
+
+// languageScore estimates how much the text reads as English prose from letters and stopwords
+func languageScore(content string) float64 {
+	words := strings.Fields(strings.ToLower(content))
+	if len(words) == 0 {
+		return 0
+	}
+
+	letters, total := 0, 0
+	for _, r := range content {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+
+	common := 0
+	for _, word := range words {
+		if stopwords[strings.Trim(word, ".,;:!?()[]\"'")] {
+			common++
+		}
+	}
+
+	// Running English has roughly a third stopwords; tables and non-English text far fewer
+	stopwordScore := min(1, float64(common)/float64(len(words))/0.25)
+	letterScore := float64(letters) / float64(total)
+	return 0.6*stopwordScore + 0.4*letterScore
+}
+
+// boilerplateShare is the fraction of the chunk's bytes in boilerplate sentences
+func boilerplateShare(content string) float64 {
+	if content == "" {
+		return 0
+	}
+	boilerplate := 0
+	for _, span := range SplitSentenceSpans(content) {
+		if boilerplatePattern.MatchString(content[span.Start:span.End]) {
+			boilerplate += span.End - span.Start
+		}
+	}
+	return min(1, float64(boilerplate)/float64(len(content)))
+}
+
+// ScoreAndFilterChunks stores each chunk's quality score in Metadata.Confidence and drops the
+// chunks scoring below minConfidence so junk is never embedded. Tables and captions are always
+// kept: they read nothing like prose but carry the numbers reviews need. Parents are kept while
+// any of their children are.
+func ScoreAndFilterChunks(chunks []models.TextChunk, config ChunkingConfig, minConfidence float64) []models.TextChunk {
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if IsTableChunk(chunk) || chunk.Metadata.ChunkType == ChunkTypeParent {
+			chunk.Metadata.Confidence = 1
+			kept = append(kept, chunk)
+			continue
+		}
+		chunk.Metadata.Confidence = ScoreChunk(chunk, config).Score
+		if chunk.Metadata.Confidence >= minConfidence {
+			kept = append(kept, chunk)
+		}
+	}
+	return pruneChildlessParents(kept)
+}
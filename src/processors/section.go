@@ -3,8 +3,10 @@ package processors
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Section struct remains the same as it's well-defined
@@ -12,79 +14,135 @@ type Section struct {
 	Title   string
 	Content string
 	Type    string
+	Offset  int `json:"-"` // Byte offset of Content in the source text, when known
 }
 
-// SectionExtractor enhanced with additional detection capabilities
+// headerNumbering is the optional number after a header such as "Chapter 2" or "Part IV"
+const headerNumbering = `(?:\s+(?:\d+|[IVXLCDM]+))?`
+
+// SectionExtractor detects a document's format and splits it on that format's section headers
 type SectionExtractor struct {
-	headers        map[string][]string
-	supportedTypes []string
-	// Adding new fields to enhance section detection
-	sectionIndicators map[string][]string // Additional indicators for each format
-	formatPatterns    []string            // Common formatting patterns
+	formats        []compiledFormat
+	formatPatterns []*regexp.Regexp // Common formatting patterns that might indicate section starts
+}
+
+// compiledFormat is a SectionFormat with its regular expressions built once
+type compiledFormat struct {
+	SectionFormat
+	order    int              // Position in the config, the last tie-breaker
+	mentions []*regexp.Regexp // One per header followed by one per indicator
+	section  *regexp.Regexp   // Matches a section header line
 }
 
-// NewSectionExtractor enhanced with more comprehensive section headers and indicators
+// NewSectionExtractor uses the built-in formats from section_formats.json
 func NewSectionExtractor() *SectionExtractor {
-	headers := map[string][]string{
-		"PubMed":  {"Background", "Methods", "Results", "Conclusions", "Objective", "Study Design", "Materials", "Discussion"},
-		"Book":    {"Introduction", "Chapter", "Summary", "Appendix", "Preface", "Part", "Section", "Notes", "Bibliography"},
-		"Legal":   {"Clause", "Sub-clause", "Definitions", "Conclusion", "Article", "Section", "Subsection", "Amendment"},
-		"Generic": {"Abstract", "Introduction", "Discussion", "References", "Overview", "Summary", "Conclusion"},
+	return defaultSectionExtractor
+}
+
+// NewSectionExtractorWithConfig builds an extractor from a declarative format configuration
+func NewSectionExtractorWithConfig(config SectionFormatConfig) (*SectionExtractor, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Additional indicators that might suggest section starts
-	sectionIndicators := map[string][]string{
-		"PubMed":  {"Purpose:", "Methodology:", "Findings:", "Keywords:", "Author contributions:"},
-		"Book":    {"Chapter", "Volume", "Part", "Section", "Notes:", "Further reading:"},
-		"Legal":   {"§", "Article", "Provision", "Term", "Whereas:", "Hereinafter:"},
-		"Generic": {"Overview:", "Summary:", "Key points:", "Discussion points:", "Conclusion:"},
+	se := &SectionExtractor{}
+	for _, pattern := range config.FormatPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format pattern %q: %w", pattern, err)
+		}
+		se.formatPatterns = append(se.formatPatterns, re)
 	}
 
-	// Common formatting patterns that might indicate section starts
-	formatPatterns := []string{
-		`^\s*\d+\.\s+[A-Z]`,   // Numbered sections (e.g., "1. Introduction")
-		`^\s*[IVXLCDM]+\.\s+`, // Roman numerals
-		`^\s*[A-Z]\.\s+`,      // Single letters (e.g., "A. Introduction")
-		`^\s*§\s*\d+`,         // Section symbol
-		`^\s*[-*•]\s+[A-Z]`,   // Bullet points with capitalized text
+	for i, format := range config.Formats {
+		compiled := compiledFormat{SectionFormat: format, order: i}
+		var alternatives []string
+		for _, rule := range format.rules() {
+			compiled.mentions = append(compiled.mentions, regexp.MustCompile("(?i)"+mentionPattern(rule.Text)))
+			alternatives = append(alternatives, regexp.QuoteMeta(strings.TrimSuffix(rule.Text, ":")))
+		}
+		alternatives = append(alternatives, config.FormatPatterns...)
+
+		// A header starts a line, may be numbered ("Chapter 2") and is followed by a colon or the end of the line
+		sectionPattern := "(?i)(?m)^\\s*((?:" + strings.Join(alternatives, "|") + ")" + headerNumbering + ")(:|\\s*$)"
+		re, err := regexp.Compile(sectionPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid headers for format '%s': %w", format.Name, err)
+		}
+		compiled.section = re
+		se.formats = append(se.formats, compiled)
 	}
+	return se, nil
+}
 
-	return &SectionExtractor{
-		headers:           headers,
-		supportedTypes:    []string{"PubMed", "Book", "Legal", "Generic"},
-		sectionIndicators: sectionIndicators,
-		formatPatterns:    formatPatterns,
+// mentionPattern matches text as a whole word, so "Part" does not match inside "Department"
+func mentionPattern(text string) string {
+	pattern := regexp.QuoteMeta(text)
+	if first, _ := utf8.DecodeRuneInString(text); unicode.IsLetter(first) || unicode.IsDigit(first) {
+		pattern = `(?:^|[^\pL\pN])` + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(text); unicode.IsLetter(last) || unicode.IsDigit(last) {
+		pattern += `(?:$|[^\pL\pN])`
 	}
+	return pattern
 }
 
-// DetectFormat enhanced to consider more indicators and patterns
-func (se *SectionExtractor) DetectFormat(text string) string {
-	// First check explicit headers as in original implementation
-	for format, headers := range se.headers {
-		headerCount := 0
-		for _, header := range headers {
-			if strings.Contains(strings.ToLower(text), strings.ToLower(header)) {
-				headerCount++
+// FormatScore is the weighted header score of one format for a text
+type FormatScore struct {
+	Format string  `json:"format"`
+	Score  float64 `json:"score"`
+}
+
+// ScoreFormats scores every format against the text, best first. Ties are broken by the format's
+// priority and then by its position in the configuration, so the result is deterministic.
+func (se *SectionExtractor) ScoreFormats(text string) []FormatScore {
+	type candidate struct {
+		FormatScore
+		priority int
+		order    int
+	}
+	candidates := make([]candidate, 0, len(se.formats))
+	for _, format := range se.formats {
+		score := 0.0
+		for i, rule := range format.rules() {
+			if format.mentions[i].MatchString(text) {
+				score += rule.weight()
 			}
 		}
-		// If multiple headers of same format are found, it's more likely to be that format
-		if headerCount >= 2 {
-			return format
+		candidates = append(candidates, candidate{FormatScore{format.Name, score}, format.Priority, format.order})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.priority != b.priority {
+			return a.priority > b.priority
 		}
+		return a.order < b.order
+	})
+
+	scores := make([]FormatScore, len(candidates))
+	for i, c := range candidates {
+		scores[i] = c.FormatScore
 	}
+	return scores
+}
 
-	// Check additional indicators if no clear format was found
-	for format, indicators := range se.sectionIndicators {
-		for _, indicator := range indicators {
-			if strings.Contains(text, indicator) {
-				return format
-			}
+// DetectFormat returns the best-scoring format that reaches its minimum score, "Generic" when only
+// formatting patterns such as numbered headings are found, and "Unknown" otherwise
+func (se *SectionExtractor) DetectFormat(text string) string {
+	for _, score := range se.ScoreFormats(text) {
+		format, _ := se.format(score.Format)
+		if score.Score > 0 && score.Score >= format.minScore() {
+			return score.Format
 		}
 	}
 
 	// Check formatting patterns
 	for _, pattern := range se.formatPatterns {
-		if regexp.MustCompile(pattern).MatchString(text) {
+		if pattern.MatchString(text) {
 			return "Generic" // Default to Generic for pattern-based matches
 		}
 	}
@@ -92,70 +150,49 @@ func (se *SectionExtractor) DetectFormat(text string) string {
 	return "Unknown"
 }
 
-// ChunkBasedOnFormat enhanced with more sophisticated section detection
-func (se *SectionExtractor) ChunkBasedOnFormat(text, format string) ([]Section, error) {
-	headers, ok := se.headers[format]
-	if !ok {
-		return nil, fmt.Errorf("unsupported format: %s", format)
+// format looks up a configured format by name
+func (se *SectionExtractor) format(name string) (compiledFormat, bool) {
+	for _, format := range se.formats {
+		if format.Name == name {
+			return format, true
+		}
 	}
+	return compiledFormat{}, false
+}
 
-	// Combine format-specific headers with their indicators
-	allPatterns := headers
-	if indicators, exists := se.sectionIndicators[format]; exists {
-		allPatterns = append(allPatterns, indicators...)
+// ChunkBasedOnFormat splits the text on the format's headers, typing each section by the
+// format's section type mapping
+func (se *SectionExtractor) ChunkBasedOnFormat(text, formatName string) ([]Section, error) {
+	format, ok := se.format(formatName)
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s", formatName)
 	}
 
-	// Create an enhanced regex pattern that includes formatting patterns
-	sectionPattern := "(?i)(?m)^\\s*("
-	sectionPattern += strings.Join(allPatterns, "|") + "|"
-	sectionPattern += strings.Join(se.formatPatterns, "|") + ")"
-	sectionPattern += "(:|\\s*$)"
-
-	re := regexp.MustCompile(sectionPattern)
-	matches := re.FindAllStringIndex(text, -1)
+	matches := format.section.FindAllStringIndex(text, -1)
 
 	if len(matches) == 0 {
-		return nil, fmt.Errorf("no sections found for format: %s", format)
+		return nil, fmt.Errorf("no sections found for format: %s", formatName)
 	}
 
 	var sections []Section
-	start := 0
-
 	for i, match := range matches {
-		headerStart, headerEnd := match[0], match[1]
-
-		// Enhanced section boundary detection
-		if i > 0 {
-			content := text[start:headerStart]
-			header := text[matches[i-1][0]:matches[i-1][1]]
-
-			// Clean up header and content
-			header = strings.TrimSpace(strings.TrimRight(header, ":"))
-			content = strings.TrimSpace(content)
-
-			// Additional validation of content boundaries
-			if isValidSection(header, content) {
-				sections = append(sections, Section{
-					Title:   header,
-					Content: content,
-					Type:    format,
-				})
-			}
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
 		}
-		start = headerEnd
-	}
 
-	// Handle the last section with enhanced boundary detection
-	lastHeader := strings.TrimSpace(text[matches[len(matches)-1][0]:matches[len(matches)-1][1]])
-	lastContent := strings.TrimSpace(text[start:])
-	lastHeader = strings.TrimRight(lastHeader, ":")
-
-	if isValidSection(lastHeader, lastContent) {
-		sections = append(sections, Section{
-			Title:   lastHeader,
-			Content: lastContent,
-			Type:    format,
-		})
+		// Clean up header and content
+		header := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(text[match[0]:match[1]]), ":"))
+		content := strings.TrimSpace(text[match[1]:end])
+
+		// Additional validation of content boundaries
+		if isValidSection(header, content) {
+			sections = append(sections, Section{
+				Title:   header,
+				Content: content,
+				Type:    format.SectionType(header),
+			})
+		}
 	}
 
 	return sections, nil
@@ -183,6 +220,11 @@ func isValidSection(header, content string) bool {
 
 // ExtractSections extracts sections from the text based on the detected format
 func (se *SectionExtractor) ExtractSections(text string) ([]Section, error) {
+	// PubMed structured abstracts carry inline labels rather than line headers
+	if sections, ok := se.SplitStructuredAbstract(text); ok {
+		return sections, nil
+	}
+
 	// Detect the format
 	format := se.DetectFormat(text)
 
@@ -0,0 +1,146 @@
+package processors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultFormatsJSON holds the built-in formats: PubMed, conference abstracts, clinical guidelines,
+// grant proposals, theses, books, legal documents and generic documents
+//
+//go:embed section_formats.json
+var defaultFormatsJSON []byte
+
+// defaultSectionExtractor is built once from defaultFormatsJSON
+var defaultSectionExtractor = mustLoadDefaultExtractor()
+
+// headerNumberingSuffix strips the numbering matched by headerNumbering
+var headerNumberingSuffix = regexp.MustCompile("(?i)" + headerNumbering + "$")
+
+// Defaults for format fields left at zero in the configuration
+const (
+	defaultHeaderWeight = 1.0
+	defaultFormatMin    = 2.0
+)
+
+// HeaderRule is a header or indicator that suggests a format, and the section type it starts
+type HeaderRule struct {
+	Text        string  `json:"text"`
+	Weight      float64 `json:"weight"`       // Added to the format's score when the text appears; zero means 1
+	SectionType string  `json:"section_type"` // Section.Type for sections under this header; empty uses the format name
+}
+
+// SectionFormat declares how to recognise one kind of document and split it into sections
+type SectionFormat struct {
+	Name string `json:"name"`
+	// Priority breaks ties between formats with equal scores; higher wins
+	Priority int `json:"priority"`
+	// MinScore is the weighted score needed to detect the format; zero means 2
+	MinScore float64 `json:"min_score"`
+	// Headers appear on a line of their own or followed by a colon
+	Headers []HeaderRule `json:"headers"`
+	// Indicators are further phrases that suggest the format and may also start sections
+	Indicators []HeaderRule `json:"indicators"`
+}
+
+// SectionFormatConfig is the declarative configuration of a SectionExtractor
+type SectionFormatConfig struct {
+	Formats []SectionFormat `json:"formats"`
+	// FormatPatterns are regular expressions for generic headings such as "1. Introduction"
+	FormatPatterns []string `json:"format_patterns"`
+}
+
+// LoadSectionFormats parses a SectionFormatConfig from JSON
+func LoadSectionFormats(data []byte) (SectionFormatConfig, error) {
+	var config SectionFormatConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return SectionFormatConfig{}, fmt.Errorf("failed to parse section formats: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return SectionFormatConfig{}, err
+	}
+	return config, nil
+}
+
+// DefaultSectionFormats returns a copy of the built-in configuration, e.g. to extend it
+func DefaultSectionFormats() SectionFormatConfig {
+	config, err := LoadSectionFormats(defaultFormatsJSON)
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// Validate checks that formats are named uniquely and have at least one header
+func (config SectionFormatConfig) Validate() error {
+	if len(config.Formats) == 0 {
+		return fmt.Errorf("no section formats configured")
+	}
+	seen := make(map[string]bool)
+	for _, format := range config.Formats {
+		switch {
+		case strings.TrimSpace(format.Name) == "":
+			return fmt.Errorf("section format without a name")
+		case format.Name == "Unknown":
+			return fmt.Errorf("'Unknown' is reserved and cannot name a section format")
+		case seen[format.Name]:
+			return fmt.Errorf("section format '%s' is defined twice", format.Name)
+		case len(format.Headers) == 0:
+			return fmt.Errorf("section format '%s' has no headers", format.Name)
+		}
+		seen[format.Name] = true
+
+		for _, rule := range format.rules() {
+			if strings.TrimSpace(rule.Text) == "" {
+				return fmt.Errorf("section format '%s' has an empty header", format.Name)
+			}
+			if rule.Weight < 0 {
+				return fmt.Errorf("header '%s' of format '%s' has a negative weight", rule.Text, format.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// rules lists the headers followed by the indicators
+func (format SectionFormat) rules() []HeaderRule {
+	return append(append([]HeaderRule(nil), format.Headers...), format.Indicators...)
+}
+
+func (format SectionFormat) minScore() float64 {
+	if format.MinScore == 0 {
+		return defaultFormatMin
+	}
+	return format.MinScore
+}
+
+func (rule HeaderRule) weight() float64 {
+	if rule.Weight == 0 {
+		return defaultHeaderWeight
+	}
+	return rule.Weight
+}
+
+// SectionType maps a section header to the type configured for it, ignoring case, numbering and
+// a trailing colon
+func (format SectionFormat) SectionType(header string) string {
+	header = strings.TrimSuffix(strings.TrimSpace(header), ":")
+	header = headerNumberingSuffix.ReplaceAllString(header, "")
+	for _, rule := range format.rules() {
+		if strings.EqualFold(strings.TrimSuffix(rule.Text, ":"), header) && rule.SectionType != "" {
+			return rule.SectionType
+		}
+	}
+	return format.Name
+}
+
+func mustLoadDefaultExtractor() *SectionExtractor {
+	se, err := NewSectionExtractorWithConfig(DefaultSectionFormats())
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in section formats: %v", err))
+	}
+	return se
+}
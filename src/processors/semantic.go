@@ -5,6 +5,8 @@ import (
 	models "my-modus-app/src/schemas"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -70,14 +72,50 @@ func (sc *SemanticChunker) createChunk(content string, startIdx int, section Sec
 		ID:      uuid.NewString(),
 		Content: strings.TrimSpace(content),
 		Metadata: models.ChunkMetadata{
-			StartIndex: startIdx,
-			EndIndex:   startIdx + len(content),
+			StartIndex: section.Offset + startIdx,
+			EndIndex:   section.Offset + startIdx + len(content),
 			Section:    section.Title,
 			Timestamp:  time.Now(),
 		},
 	}
 }
 
+// SentenceSpan locates one sentence in the original text by byte offsets
+type SentenceSpan struct {
+	Start int
+	End   int
+}
+
+// SplitSentenceSpans finds sentence boundaries in text, keeping the terminal punctuation with each
+// sentence. A boundary followed by a lowercase letter (e.g. "et al. found") is not treated as a split.
+func SplitSentenceSpans(text string) []SentenceSpan {
+	var spans []SentenceSpan
+	start := 0
+	for _, match := range sentenceBoundary.FindAllStringIndex(text, -1) {
+		if next, _ := utf8.DecodeRuneInString(text[match[1]:]); unicode.IsLower(next) {
+			continue
+		}
+		end := match[0] + len(strings.TrimRightFunc(text[match[0]:match[1]], unicode.IsSpace))
+		if span, ok := trimSpan(text, start, end); ok {
+			spans = append(spans, span)
+		}
+		start = match[1]
+	}
+	if span, ok := trimSpan(text, start, len(text)); ok {
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// trimSpan shrinks a span to exclude surrounding whitespace
+func trimSpan(text string, start, end int) (SentenceSpan, bool) {
+	segment := text[start:end]
+	trimmedLeft := strings.TrimLeftFunc(segment, unicode.IsSpace)
+	start += len(segment) - len(trimmedLeft)
+	end = start + len(strings.TrimRightFunc(trimmedLeft, unicode.IsSpace))
+	return SentenceSpan{Start: start, End: end}, end > start
+}
+
 // Utility function to split text into sentences
 func splitIntoSentences(text string) ([]string, error) {
 	// Simplistic sentence splitter; consider using a library for production use
@@ -0,0 +1,427 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	models "my-modus-app/src/schemas"
+	"my-modus-app/src/utils"
+)
+
+// Names of the built-in chunking strategies
+const (
+	StrategyAuto         = "auto"
+	StrategySection      = "section"
+	StrategySemantic     = "semantic"
+	StrategyLLM          = "llm"
+	StrategyLLMOffsets   = "llm-offsets"
+	StrategyFixedWindow  = "fixed-window"
+	StrategyJATS         = "jats"
+	StrategyMarkdown     = "markdown"
+	StrategyHTML         = "html"
+	StrategyHierarchical = "hierarchical"
+)
+
+// StrategyConfig selects a chunking strategy and configures it
+type StrategyConfig struct {
+	Strategy  string         `json:"strategy"`
+	ModelName string         `json:"model_name"`
+	Chunking  ChunkingConfig `json:"chunking"`
+	// EntityModel enables LLM entity extraction on top of the dictionary when set to a model name
+	EntityModel string `json:"entity_model"`
+	// MinConfidence drops chunks scoring below it before embedding; nil uses DefaultMinConfidence
+	// and zero keeps every chunk
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+	// CleaningProfile prepares chunk text for embedding; empty uses ProfileEmbedding
+	CleaningProfile string `json:"cleaning_profile"`
+	// Hierarchy configures the parent and child chunks of the hierarchical strategy
+	Hierarchy HierarchyConfig `json:"hierarchy"`
+	// EmbeddingModel names the embedding model in modus.json; empty uses utils.DefaultEmbeddingModel
+	EmbeddingModel string `json:"embedding_model"`
+	// Embedding limits the batches chunks are embedded in
+	Embedding utils.EmbeddingBatchConfig `json:"embedding"`
+}
+
+// StrategyFactory builds a ChunkingStrategy from its configuration
+type StrategyFactory func(config StrategyConfig) ChunkingStrategy
+
+// strategies is the registry of named chunking strategies
+var strategies = map[string]StrategyFactory{
+	StrategyAuto:         func(config StrategyConfig) ChunkingStrategy { return &AutoStrategy{config: config} },
+	StrategySection:      func(config StrategyConfig) ChunkingStrategy { return &SectionStrategy{config: config} },
+	StrategySemantic:     func(config StrategyConfig) ChunkingStrategy { return &SemanticStrategy{config: config} },
+	StrategyLLM:          func(config StrategyConfig) ChunkingStrategy { return &LLMStrategy{config: config} },
+	StrategyLLMOffsets:   func(config StrategyConfig) ChunkingStrategy { return &LLMOffsetsStrategy{config: config} },
+	StrategyFixedWindow:  func(config StrategyConfig) ChunkingStrategy { return &FixedWindowStrategy{config: config} },
+	StrategyJATS:         func(config StrategyConfig) ChunkingStrategy { return &JATSStrategy{config: config} },
+	StrategyMarkdown:     func(config StrategyConfig) ChunkingStrategy { return &MarkdownStrategy{config: config} },
+	StrategyHTML:         func(config StrategyConfig) ChunkingStrategy { return &HTMLStrategy{config: config} },
+	StrategyHierarchical: func(config StrategyConfig) ChunkingStrategy { return &HierarchicalStrategy{config: config} },
+}
+
+// DefaultStrategyConfig returns the settings ChoiceChunker has always used
+func DefaultStrategyConfig() StrategyConfig {
+	return StrategyConfig{
+		Strategy:  StrategyAuto,
+		ModelName: "section-generator", // Model name as seen in the modus.json
+		Chunking: ChunkingConfig{
+			MaxChunkSize:       1000,
+			MinChunkSize:       500,
+			ChunkOverlap:       1,
+			OverlapUnit:        OverlapSentences,
+			PreserveParagraphs: true,
+			PreserveSentences:  true,
+		},
+	}
+}
+
+// WithDefaults fills any zero-valued fields from DefaultStrategyConfig
+func (config StrategyConfig) WithDefaults() StrategyConfig {
+	defaults := DefaultStrategyConfig()
+	if config.Strategy == "" {
+		config.Strategy = defaults.Strategy
+	}
+	if config.ModelName == "" {
+		config.ModelName = defaults.ModelName
+	}
+	config.Chunking = config.Chunking.withDefaults(defaults.Chunking)
+	if config.MinConfidence == nil {
+		minConfidence := DefaultMinConfidence
+		config.MinConfidence = &minConfidence
+	}
+	if config.CleaningProfile == "" {
+		config.CleaningProfile = ProfileEmbedding
+	}
+	config.Hierarchy = config.Hierarchy.withDefaults(DefaultHierarchyConfig())
+	if config.EmbeddingModel == "" {
+		config.EmbeddingModel = utils.DefaultEmbeddingModel
+	}
+	config.Embedding = config.Embedding.WithDefaults()
+	return config
+}
+
+// withDefaults fills the zero fields of a chunking config one by one. ChunkOverlap and the Preserve
+// flags have meaningful zero values, so they are only defaulted when the config is entirely empty.
+func (chunking ChunkingConfig) withDefaults(defaults ChunkingConfig) ChunkingConfig {
+	if chunking == (ChunkingConfig{}) {
+		return defaults
+	}
+	if chunking.MaxChunkSize == 0 {
+		chunking.MaxChunkSize = max(defaults.MaxChunkSize, chunking.MinChunkSize)
+	}
+	if chunking.MinChunkSize == 0 {
+		chunking.MinChunkSize = min(defaults.MinChunkSize, chunking.MaxChunkSize)
+	}
+	if chunking.OverlapUnit == "" {
+		chunking.OverlapUnit = defaults.OverlapUnit
+	}
+	return chunking
+}
+
+// withDefaults fills the zero fields of a hierarchy config one by one. ChildOverlap has a
+// meaningful zero value, so it is only defaulted when the config is entirely empty.
+func (hierarchy HierarchyConfig) withDefaults(defaults HierarchyConfig) HierarchyConfig {
+	if hierarchy == (HierarchyConfig{}) {
+		return defaults
+	}
+	if hierarchy.ParentStrategy == "" {
+		hierarchy.ParentStrategy = defaults.ParentStrategy
+	}
+	if hierarchy.ParentMaxSize == 0 {
+		hierarchy.ParentMaxSize = defaults.ParentMaxSize
+	}
+	if hierarchy.ChildSentences == 0 {
+		hierarchy.ChildSentences = max(defaults.ChildSentences, hierarchy.ChildOverlap+1)
+	}
+	return hierarchy
+}
+
+// Validate checks the chunking parameters
+func (config StrategyConfig) Validate() error {
+	if config.Chunking.MaxChunkSize <= 0 || config.Chunking.MinChunkSize <= 0 {
+		return fmt.Errorf("chunk size values must be greater than 0")
+	}
+	if config.Chunking.ChunkOverlap < 0 {
+		return fmt.Errorf("chunk overlap must be non-negative")
+	}
+	return nil
+}
+
+// RegisterStrategy adds or replaces a named chunking strategy
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategies[name] = factory
+}
+
+// AvailableStrategies lists the registered strategy names in sorted order
+func AvailableStrategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy looks up the configured strategy in the registry
+func NewStrategy(config StrategyConfig) (ChunkingStrategy, error) {
+	config = config.WithDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	factory, ok := strategies[config.Strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown chunking strategy %q, expected one of %s", config.Strategy, strings.Join(AvailableStrategies(), ", "))
+	}
+	return factory(config), nil
+}
+
+// ChunkWithStrategy chunks text with the strategy selected in config, adds a dedicated chunk for
+// each table and figure caption, and annotates the citations. Tables and captions are removed from
+// the text the strategy sees, so their content is not chunked twice.
+func ChunkWithStrategy(text string, config StrategyConfig) ([]models.TextChunk, error) {
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		return nil, err
+	}
+	tables := findTables(text)
+
+	var chunks []models.TextChunk
+	// A text that is only tables has no prose left to chunk
+	if prose := removeTables(text, tables); len(tables) == 0 || strings.TrimSpace(prose) != "" {
+		chunks, err = strategy.Chunk(prose)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range chunks {
+		if chunks[i].Metadata.ChunkType == "" {
+			chunks[i].Metadata.ChunkType = ChunkTypeText
+		}
+	}
+	chunks = append(chunks, tableChunks(tables)...)
+
+	AnnotateCitations(chunks, text)
+	return chunks, nil
+}
+
+// chunkSections runs each section through the semantic chunker and applies the configured overlap
+func chunkSections(sections []Section, config ChunkingConfig) ([]models.TextChunk, error) {
+	chunker := NewChunker(config)
+
+	var chunks []models.TextChunk
+	for _, section := range sections {
+		if strings.TrimSpace(section.Content) == "" {
+			continue
+		}
+		sectionChunks, err := chunker.semanticChunker.ChunkSection(section)
+		if err != nil {
+			return nil, fmt.Errorf("failed to chunk section '%s': %w", section.Title, err)
+		}
+		chunks = append(chunks, chunker.applyOverlap(sectionChunks)...)
+	}
+	return chunks, nil
+}
+
+// SectionStrategy splits on detected section headers before semantic chunking
+type SectionStrategy struct {
+	config StrategyConfig
+}
+
+func (s *SectionStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	c := s.config.Chunking
+	return NewChunker(c).ProcessText(text, c.MaxChunkSize, c.MinChunkSize, c.ChunkOverlap, c.PreserveParagraphs, c.PreserveSentences)
+}
+
+// SemanticStrategy chunks the whole text by sentences without looking for sections
+type SemanticStrategy struct {
+	config StrategyConfig
+}
+
+func (s *SemanticStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	return chunkSections([]Section{{Title: "Document", Content: text, Type: "Miscellaneous"}}, s.config.Chunking)
+}
+
+// LLMStrategy asks the configured model to identify the sections
+type LLMStrategy struct {
+	config StrategyConfig
+}
+
+func (s *LLMStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	c := s.config.Chunking
+	return FallbackToLLMChunking(text, c.MaxChunkSize, c.MinChunkSize, c.ChunkOverlap, c.PreserveParagraphs, c.PreserveSentences, s.config.ModelName)
+}
+
+// LLMOffsetsStrategy asks the model only for section boundaries and slices the original text
+type LLMOffsetsStrategy struct {
+	config StrategyConfig
+}
+
+func (s *LLMOffsetsStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	return LLMOffsetChunking(text, s.config.Chunking, s.config.ModelName)
+}
+
+// FixedWindowStrategy cuts the text into windows of at most MaxChunkSize bytes on whitespace
+type FixedWindowStrategy struct {
+	config StrategyConfig
+}
+
+func (s *FixedWindowStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	chunker := NewChunker(s.config.Chunking)
+	maxSize := s.config.Chunking.MaxChunkSize
+
+	var chunks []models.TextChunk
+	start := 0
+	for start < len(text) {
+		// Skip leading whitespace so windows start on a token
+		for start < len(text) {
+			r, size := utf8.DecodeRuneInString(text[start:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			start += size
+		}
+		if start >= len(text) {
+			break
+		}
+
+		end := windowEnd(text, start, maxSize)
+		section := Section{Title: fmt.Sprintf("Window-%d", len(chunks)+1), Type: "Miscellaneous"}
+		chunks = append(chunks, chunker.semanticChunker.createChunk(text[start:end], start, section))
+		start = end
+	}
+
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no chunks were created from empty text")
+	}
+	return chunker.applyOverlap(chunks), nil
+}
+
+// windowEnd finds the end of a window starting at start, preferring the last whitespace before maxSize
+func windowEnd(text string, start, maxSize int) int {
+	if len(text)-start <= maxSize {
+		return len(text)
+	}
+	end := start + maxSize
+	// Step back to a rune boundary
+	for end > start && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	if cut := strings.LastIndexFunc(text[start:end], unicode.IsSpace); cut > 0 {
+		return start + cut
+	}
+	if end == start {
+		_, size := utf8.DecodeRuneInString(text[start:])
+		return start + size
+	}
+	return end
+}
+
+// JATSStrategy chunks JATS XML articles along their <sec> elements
+type JATSStrategy struct {
+	config StrategyConfig
+}
+
+func (s *JATSStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	return chunkSections(ExtractJATSSections(text), s.config.Chunking)
+}
+
+// MarkdownStrategy chunks Markdown documents along their heading hierarchy
+type MarkdownStrategy struct {
+	config StrategyConfig
+}
+
+func (s *MarkdownStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	return chunkStructuredSections(ParseMarkdown(text), s.config.Chunking)
+}
+
+// HTMLStrategy chunks HTML documents along their <h1>-<h6> hierarchy
+type HTMLStrategy struct {
+	config StrategyConfig
+}
+
+func (s *HTMLStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	return chunkStructuredSections(ParseHTML(text), s.config.Chunking)
+}
+
+// HierarchicalStrategy produces section-level parents and sentence-window children linked by ID
+type HierarchicalStrategy struct {
+	config StrategyConfig
+}
+
+func (s *HierarchicalStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	return HierarchicalChunking(text, s.config)
+}
+
+// AutoStrategy picks a strategy from the detected document format
+type AutoStrategy struct {
+	config StrategyConfig
+}
+
+func (s *AutoStrategy) Chunk(text string) ([]models.TextChunk, error) {
+	config := s.config
+	config.Strategy = DetectStrategy(text)
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		return nil, err
+	}
+	return strategy.Chunk(text)
+}
+
+var (
+	jatsPattern            = regexp.MustCompile(`(?i)<(sec|front|article-meta|ref-list)[\s>]`)
+	htmlPattern            = regexp.MustCompile(`(?i)<(html|body|h[1-6]|p|div|ul|ol|table)[\s>]`)
+	markdownHeadingPattern = regexp.MustCompile(`(?m)^#{1,6}\s+\S`)
+)
+
+// DetectStrategy chooses the strategy best suited to the format of text
+func DetectStrategy(text string) string {
+	if jatsPattern.MatchString(text) {
+		return StrategyJATS
+	}
+	if htmlPattern.MatchString(text) {
+		return StrategyHTML
+	}
+	if markdownHeadingPattern.MatchString(text) {
+		return StrategyMarkdown
+	}
+
+	se := NewSectionExtractor()
+	if _, ok := se.SplitStructuredAbstract(text); ok {
+		return StrategySection
+	}
+	if se.DetectFormat(text) != "Unknown" {
+		return StrategySection
+	}
+	return StrategySemantic
+}
+
+// mimeTypeStrategies maps the MIME types of uploaded documents to the strategy that parses them
+var mimeTypeStrategies = map[string]string{
+	models.MimeTypePlainText: StrategyAuto,
+	models.MimeTypeMarkdown:  StrategyMarkdown,
+	models.MimeTypeHTML:      StrategyHTML,
+	models.MimeTypeJATS:      StrategyJATS,
+	"application/xml":        StrategyJATS,
+	"text/xml":               StrategyJATS,
+}
+
+// StrategyForMimeType returns the strategy for a document's MIME type, ignoring parameters such
+// as charset. An empty MIME type is treated as plain text.
+func StrategyForMimeType(mimeType string) (string, error) {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType == "" {
+		mimeType = models.MimeTypePlainText
+	}
+
+	strategy, ok := mimeTypeStrategies[mimeType]
+	if !ok {
+		return "", fmt.Errorf("unsupported document type '%s'", mimeType)
+	}
+	return strategy, nil
+}
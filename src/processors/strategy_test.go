@@ -0,0 +1,165 @@
+package processors
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	models "my-modus-app/src/schemas"
+)
+
+func TestWithDefaultsFillsChunkingFieldsIndividually(t *testing.T) {
+	defaults := DefaultStrategyConfig().Chunking
+
+	tests := []struct {
+		name string
+		in   ChunkingConfig
+		want ChunkingConfig
+	}{
+		{"empty", ChunkingConfig{}, defaults},
+		{"only max", ChunkingConfig{MaxChunkSize: 2000},
+			ChunkingConfig{MaxChunkSize: 2000, MinChunkSize: defaults.MinChunkSize, OverlapUnit: defaults.OverlapUnit}},
+		{"small max", ChunkingConfig{MaxChunkSize: 300},
+			ChunkingConfig{MaxChunkSize: 300, MinChunkSize: 300, OverlapUnit: defaults.OverlapUnit}},
+		{"only overlap", ChunkingConfig{ChunkOverlap: 3},
+			ChunkingConfig{MaxChunkSize: defaults.MaxChunkSize, MinChunkSize: defaults.MinChunkSize, ChunkOverlap: 3, OverlapUnit: defaults.OverlapUnit}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := StrategyConfig{Chunking: test.in}.WithDefaults()
+			if config.Chunking != test.want {
+				t.Errorf("got %+v, want %+v", config.Chunking, test.want)
+			}
+			if err := config.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestChunkWithStrategyChunksTablesOnce(t *testing.T) {
+	text := "Results of the trial were consistent across all sites and all patient groups studied.\n\n" +
+		"Table 1: Baseline characteristics\n" +
+		"| Group | Age |\n|---|---|\n| Treated | 54 |\n| Control | 56 |\n\n" +
+		"The treated group improved more than the control group over the follow-up period.\n"
+
+	chunks, err := ChunkWithStrategy(text, StrategyConfig{})
+	if err != nil {
+		t.Fatalf("ChunkWithStrategy: %v", err)
+	}
+	tables := 0
+	for _, chunk := range chunks {
+		if IsTableChunk(chunk) {
+			tables++
+			continue
+		}
+		if strings.Contains(chunk.Content, "Treated") || strings.Contains(chunk.Content, "Baseline characteristics") {
+			t.Errorf("text chunk repeats the table: %q", chunk.Content)
+		}
+	}
+	if tables == 0 {
+		t.Fatal("no table chunk")
+	}
+
+	again, err := ChunkWithStrategy(text, StrategyConfig{})
+	if err != nil {
+		t.Fatalf("ChunkWithStrategy: %v", err)
+	}
+	for i := range chunks {
+		if IsTableChunk(chunks[i]) && chunks[i].ID != again[i].ID {
+			t.Errorf("table chunk id changed between runs: %s, %s", chunks[i].ID, again[i].ID)
+		}
+	}
+}
+
+func TestWithDefaultsKeepsZeroMinConfidence(t *testing.T) {
+	if got := *(StrategyConfig{}).WithDefaults().MinConfidence; got != DefaultMinConfidence {
+		t.Errorf("unset MinConfidence: got %v, want %v", got, DefaultMinConfidence)
+	}
+
+	zero := 0.0
+	config := StrategyConfig{MinConfidence: &zero}.WithDefaults()
+	if *config.MinConfidence != 0 {
+		t.Fatalf("zero MinConfidence became %v", *config.MinConfidence)
+	}
+	chunks := []models.TextChunk{{ID: "junk", Content: "Copyright 2020."}}
+	if kept := ScoreAndFilterChunks(chunks, config.Chunking, *config.MinConfidence); len(kept) != 1 {
+		t.Errorf("zero MinConfidence dropped chunks: kept %d of 1", len(kept))
+	}
+}
+
+func TestWithDefaultsFillsHierarchyFieldsIndividually(t *testing.T) {
+	defaults := DefaultHierarchyConfig()
+
+	tests := []struct {
+		name string
+		in   HierarchyConfig
+		want HierarchyConfig
+	}{
+		{"empty", HierarchyConfig{}, defaults},
+		{"only parent size", HierarchyConfig{ParentMaxSize: 2000},
+			HierarchyConfig{ParentStrategy: defaults.ParentStrategy, ParentMaxSize: 2000, ChildSentences: defaults.ChildSentences}},
+		{"only child sentences", HierarchyConfig{ChildSentences: 5},
+			HierarchyConfig{ParentStrategy: defaults.ParentStrategy, ParentMaxSize: defaults.ParentMaxSize, ChildSentences: 5}},
+		{"large overlap", HierarchyConfig{ChildOverlap: 4},
+			HierarchyConfig{ParentStrategy: defaults.ParentStrategy, ParentMaxSize: defaults.ParentMaxSize, ChildSentences: 5, ChildOverlap: 4}},
+		{"parent strategy", HierarchyConfig{ParentStrategy: StrategySection},
+			HierarchyConfig{ParentStrategy: StrategySection, ParentMaxSize: defaults.ParentMaxSize, ChildSentences: defaults.ChildSentences}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := (StrategyConfig{Hierarchy: test.in}).WithDefaults().Hierarchy; got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHierarchicalStrategyLinksParentsAndChildren(t *testing.T) {
+	text := "# Introduction\n\n" +
+		"Metformin is a first line therapy. It lowers hepatic glucose output. It rarely causes hypoglycemia. " +
+		"It is taken with meals. Doses are raised slowly.\n\n" +
+		"# Methods\n\n" +
+		"Patients were randomised. Outcomes were measured at twelve weeks. Analyses were blinded.\n"
+
+	// Only the parent size is set; the child window keeps its default
+	config := StrategyConfig{Strategy: StrategyHierarchical, Hierarchy: HierarchyConfig{ParentMaxSize: 1000}}
+	chunks, err := ChunkWithStrategy(text, config)
+	if err != nil {
+		t.Fatalf("ChunkWithStrategy: %v", err)
+	}
+
+	parents := make(map[string]models.TextChunk)
+	children := 0
+	for _, chunk := range chunks {
+		if chunk.Metadata.ChunkType == ChunkTypeParent {
+			parents[chunk.ID] = chunk
+		}
+	}
+	if len(parents) == 0 {
+		t.Fatal("no parent chunks")
+	}
+	for _, chunk := range chunks {
+		if chunk.Metadata.ChunkType == ChunkTypeParent {
+			continue
+		}
+		children++
+		parent, ok := parents[chunk.Metadata.ParentID]
+		if !ok {
+			t.Errorf("child %q has no parent", chunk.Content)
+			continue
+		}
+		if !slices.Contains(parent.Metadata.ChildIDs, chunk.ID) {
+			t.Errorf("parent does not list child %q", chunk.Content)
+		}
+		if got := text[chunk.Metadata.StartIndex:chunk.Metadata.EndIndex]; got != chunk.Content {
+			t.Errorf("child offsets select %q, want %q", got, chunk.Content)
+		}
+		if sentences := len(SplitSentenceSpans(chunk.Content)); sentences > DefaultHierarchyConfig().ChildSentences {
+			t.Errorf("child of %d sentences exceeds the default window: %q", sentences, chunk.Content)
+		}
+	}
+	if children <= len(parents) {
+		t.Errorf("got %d children for %d parents, want the five-sentence section split into windows", children, len(parents))
+	}
+}
@@ -0,0 +1,160 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	models "my-modus-app/src/schemas"
+)
+
+// Kinds of block found in structured (Markdown or HTML) documents
+const (
+	BlockParagraph = "paragraph"
+	BlockList      = "list"
+	BlockTable     = "table"
+	BlockCode      = "code"
+)
+
+// tokenPattern matches the whitespace-separated tokens hardSplit cuts between
+var tokenPattern = regexp.MustCompile(`\S+`)
+
+// headingPathSeparator joins nested heading titles in ChunkMetadata.Section
+const headingPathSeparator = " > "
+
+// Block is a unit of a structured document; lists, tables and code are never split
+type Block struct {
+	Kind   string
+	Text   string
+	Offset int // Byte offset of the block in the source document
+}
+
+// StructuredSection is the content under one heading, identified by its heading path
+type StructuredSection struct {
+	Path   []string
+	Level  int
+	Blocks []Block
+}
+
+// Title is the heading path, e.g. "Methods > Participants"
+func (s StructuredSection) Title() string {
+	if len(s.Path) == 0 {
+		return "Preamble"
+	}
+	return strings.Join(s.Path, headingPathSeparator)
+}
+
+// headingStack tracks the open headings while a document is parsed
+type headingStack struct {
+	levels []int
+	titles []string
+}
+
+// push closes every heading at the same or a deeper level and opens the new one
+func (hs *headingStack) push(level int, title string) {
+	for len(hs.levels) > 0 && hs.levels[len(hs.levels)-1] >= level {
+		hs.levels = hs.levels[:len(hs.levels)-1]
+		hs.titles = hs.titles[:len(hs.titles)-1]
+	}
+	hs.levels = append(hs.levels, level)
+	hs.titles = append(hs.titles, title)
+}
+
+func (hs *headingStack) path() []string {
+	return append([]string(nil), hs.titles...)
+}
+
+// hardSplit cuts a section at whitespace into chunks of at most MaxChunkSize bytes, for prose the
+// sentence splitter cannot cut; a single longer token becomes a chunk of its own
+func (sc *SemanticChunker) hardSplit(section Section) []models.TextChunk {
+	var chunks []models.TextChunk
+	start, end := -1, 0
+	for _, token := range tokenPattern.FindAllStringIndex(section.Content, -1) {
+		if start >= 0 && token[1]-start > sc.config.MaxChunkSize {
+			chunks = append(chunks, sc.createChunk(section.Content[start:end], start, section))
+			start = -1
+		}
+		if start < 0 {
+			start = token[0]
+		}
+		end = token[1]
+	}
+	if start >= 0 {
+		chunks = append(chunks, sc.createChunk(section.Content[start:end], start, section))
+	}
+	return chunks
+}
+
+// chunkStructuredSections packs each section's paragraphs into chunks of at most MaxChunkSize.
+// Lists, tables and code blocks each get a chunk of their own, whole even when larger, typed by
+// their kind so no overlap is added to them. Long paragraphs are split by sentence, or at
+// whitespace when a single sentence is too long.
+func chunkStructuredSections(sections []StructuredSection, config ChunkingConfig) ([]models.TextChunk, error) {
+	chunker := NewChunker(config)
+
+	var chunks []models.TextChunk
+	for _, section := range sections {
+		title := section.Title()
+		var sectionChunks []models.TextChunk
+		var current []Block
+
+		flush := func() {
+			if len(current) == 0 {
+				return
+			}
+			texts := make([]string, len(current))
+			for i, block := range current {
+				texts[i] = block.Text
+			}
+			content := strings.Join(texts, "\n\n")
+			sectionChunks = append(sectionChunks, chunker.semanticChunker.createChunk(content, current[0].Offset, Section{Title: title}))
+			current = nil
+		}
+
+		size := 0
+		for _, block := range section.Blocks {
+			if strings.TrimSpace(block.Text) == "" {
+				continue
+			}
+
+			// Structural blocks are never merged with prose
+			if chunkType, ok := blockChunkTypes[block.Kind]; ok {
+				flush()
+				size = 0
+				chunk := chunker.semanticChunker.createChunk(block.Text, block.Offset, Section{Title: title})
+				chunk.Metadata.ChunkType = chunkType
+				sectionChunks = append(sectionChunks, chunk)
+				continue
+			}
+
+			// Oversized prose is split by sentence like any other section
+			if len(block.Text) > config.MaxChunkSize {
+				flush()
+				size = 0
+				paragraph := Section{Title: title, Content: block.Text, Offset: block.Offset}
+				paragraphChunks, err := chunker.semanticChunker.ChunkSection(paragraph)
+				if err != nil {
+					// A sentence longer than MaxChunkSize leaves the sentence splitter nothing to cut at
+					paragraphChunks = chunker.semanticChunker.hardSplit(paragraph)
+				}
+				sectionChunks = append(sectionChunks, paragraphChunks...)
+				continue
+			}
+
+			if len(current) > 0 && size+len(block.Text) > config.MaxChunkSize {
+				flush()
+				size = 0
+			}
+			current = append(current, block)
+			size += len(block.Text) + 2
+		}
+		flush()
+
+		chunks = append(chunks, chunker.applyOverlap(sectionChunks)...)
+	}
+
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no chunks were created from the document")
+	}
+	return chunks, nil
+}
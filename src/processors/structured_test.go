@@ -0,0 +1,55 @@
+package processors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkStructuredSectionsHardSplitsLongSentences(t *testing.T) {
+	config := DefaultStrategyConfig().Chunking
+	config.MaxChunkSize, config.MinChunkSize = 200, 50
+
+	// One sentence with no ". " to split at, several times MaxChunkSize
+	paragraph := strings.TrimSpace(strings.Repeat("word ", 150))
+	chunks, err := chunkStructuredSections(ParseMarkdown("# Title\n\n"+paragraph+"\n"), config)
+	if err != nil {
+		t.Fatalf("chunkStructuredSections: %v", err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("got %d chunks, want the sentence split into several", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if content := StripOverlap(chunk); len(content) > config.MaxChunkSize {
+			t.Errorf("chunk of %d bytes exceeds MaxChunkSize", len(content))
+		}
+	}
+}
+
+func TestChunkStructuredSectionsKeepsOverlapOffStructuralChunks(t *testing.T) {
+	config := DefaultStrategyConfig().Chunking
+	config.MaxChunkSize, config.MinChunkSize, config.ChunkOverlap = 60, 10, 2
+
+	document := "# Title\n\n" +
+		"First paragraph of prose text here.\n\n" +
+		"- first item\n- second item\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+		"Second paragraph of prose after the table.\n"
+	chunks, err := chunkStructuredSections(ParseMarkdown(document), config)
+	if err != nil {
+		t.Fatalf("chunkStructuredSections: %v", err)
+	}
+
+	types := map[string]bool{}
+	for _, chunk := range chunks {
+		types[chunk.Metadata.ChunkType] = true
+		if !isProseChunk(chunk) && StripOverlap(chunk) != chunk.Content {
+			t.Errorf("%s chunk has overlap: %q", chunk.Metadata.ChunkType, chunk.Content)
+		}
+		if isProseChunk(chunk) && strings.Contains(chunk.Content, "|") {
+			t.Errorf("prose chunk carries table text: %q", chunk.Content)
+		}
+	}
+	if !types[ChunkTypeList] || !types[ChunkTypeTable] {
+		t.Errorf("got chunk types %v, want list and table chunks", types)
+	}
+}
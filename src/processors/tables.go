@@ -0,0 +1,442 @@
+package processors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	models "my-modus-app/src/schemas"
+)
+
+// Kinds of chunk stored in ChunkMetadata.ChunkType
+const (
+	ChunkTypeText         = "text"
+	ChunkTypeTable        = "table"
+	ChunkTypeTableCaption = "table_caption" // A table caption whose table body could not be parsed
+	ChunkTypeFigure       = "figure"        // A figure caption; the figure itself is not text
+	ChunkTypeList         = "list"          // A list block of a Markdown or HTML document
+	ChunkTypeCode         = "code"          // A code block of a Markdown or HTML document
+)
+
+// blockChunkTypes is the chunk type of each block kind that is kept whole in a chunk of its own
+var blockChunkTypes = map[string]string{
+	BlockList:  ChunkTypeList,
+	BlockTable: ChunkTypeTable,
+	BlockCode:  ChunkTypeCode,
+}
+
+// Formats a table was found in, recorded in TableData.Format
+const (
+	TableFormatMarkdown = "markdown"
+	TableFormatHTML     = "html"
+	TableFormatJATS     = "jats"
+	TableFormatTSV      = "tsv"
+)
+
+var (
+	jatsTableWrapPattern = regexp.MustCompile(`(?i)<table-wrap\b[^>]*>`)
+	jatsFigPattern       = regexp.MustCompile(`(?i)<fig\b[^>]*>`)
+	htmlFigurePattern    = regexp.MustCompile(`(?i)<figure\b[^>]*>`)
+	htmlTablePattern     = regexp.MustCompile(`(?i)<table\b[^>]*>`)
+	labelPattern         = regexp.MustCompile(`(?is)<label\b[^>]*>(.*?)</label>`)
+	captionPattern       = regexp.MustCompile(`(?is)<(caption|figcaption)\b[^>]*>(.*?)</(?:caption|figcaption)>`)
+	markdownSeparatorRow = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+	// captionLine matches plain-text captions such as "Table 2. Baseline characteristics" or "Fig. 3: ..."
+	captionLine = regexp.MustCompile(`(?m)^[ \t]*((?:Fig(?:ure)?\.?|Table)[ \t]+[0-9]+[A-Za-z]?)[.:][ \t]+(\S.*)$`)
+)
+
+// TableData is the structure of a table or caption chunk, stored as JSON in ChunkMetadata.Properties
+type TableData struct {
+	Label   string     `json:"label,omitempty"`
+	Caption string     `json:"caption,omitempty"`
+	Format  string     `json:"format,omitempty"`
+	Header  []string   `json:"header,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+}
+
+// tableMatch is a table or caption located in the source text
+type tableMatch struct {
+	chunkType string
+	data      TableData
+	start     int
+	end       int
+}
+
+// ExtractTableChunks finds tables (Markdown, HTML, JATS or tab-delimited) and figure and table
+// captions in text and returns one dedicated chunk for each, with the structure in Properties
+func ExtractTableChunks(text string) []models.TextChunk {
+	return tableChunks(findTables(text))
+}
+
+// findTables locates the tables and captions of text in order of position
+func findTables(text string) []tableMatch {
+	var matches []tableMatch
+	if jatsPattern.MatchString(text) || htmlPattern.MatchString(text) {
+		matches = findMarkupTables(text)
+	} else {
+		matches = findTextTables(text)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+// removeTables blanks out the tables and captions of text, keeping line breaks so the offsets of
+// the remaining text are unchanged
+func removeTables(text string, matches []tableMatch) string {
+	removed := []byte(text)
+	for _, match := range matches {
+		for i := match.start; i < match.end && i < len(removed); i++ {
+			if removed[i] != '\n' {
+				removed[i] = ' '
+			}
+		}
+	}
+	return string(removed)
+}
+
+// tableChunks returns one dedicated chunk for each table or caption. IDs hash the chunk's type,
+// position and content, so extracting the same text again gives the same IDs.
+func tableChunks(matches []tableMatch) []models.TextChunk {
+	chunks := make([]models.TextChunk, 0, len(matches))
+	for _, match := range matches {
+		properties, err := json.Marshal(match.data)
+		if err != nil {
+			continue
+		}
+		section := match.data.Label
+		if section == "" {
+			section = "Table"
+		}
+		content := renderTableData(match.data)
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", match.chunkType, match.start, content)))
+		chunks = append(chunks, models.TextChunk{
+			ID:      hex.EncodeToString(sum[:16]),
+			Content: content,
+			Metadata: models.ChunkMetadata{
+				StartIndex: match.start,
+				EndIndex:   match.end,
+				Section:    section,
+				ChunkType:  match.chunkType,
+				Properties: string(properties),
+				Timestamp:  time.Now(),
+			},
+		})
+	}
+	return chunks
+}
+
+// findMarkupTables finds JATS <table-wrap> and <fig> elements, HTML <figure> elements and any
+// remaining <table> elements outside them
+func findMarkupTables(text string) []tableMatch {
+	lower := strings.ToLower(text)
+	var matches []tableMatch
+	var covered [][2]int
+
+	inside := func(offset int) bool {
+		for _, span := range covered {
+			if offset >= span[0] && offset < span[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, loc := range jatsTableWrapPattern.FindAllStringIndex(text, -1) {
+		innerEnd, end := findClosingTag(lower, "table-wrap", loc[1])
+		inner := text[loc[1]:innerEnd]
+		data := TableData{Label: firstSubmatch(labelPattern, inner, 1), Caption: firstSubmatch(captionPattern, inner, 2), Format: TableFormatJATS}
+		chunkType := ChunkTypeTableCaption
+		if tableLoc := htmlTablePattern.FindStringIndex(inner); tableLoc != nil {
+			tableEnd, _ := findClosingTag(strings.ToLower(inner), "table", tableLoc[1])
+			data.Header, data.Rows = parseHTMLTableRows(inner[tableLoc[1]:tableEnd])
+			if len(data.Rows) > 0 || len(data.Header) > 0 {
+				chunkType = ChunkTypeTable
+			}
+		}
+		matches = append(matches, tableMatch{chunkType: chunkType, data: data, start: loc[0], end: end})
+		covered = append(covered, [2]int{loc[0], end})
+	}
+
+	for _, pattern := range []*regexp.Regexp{jatsFigPattern, htmlFigurePattern} {
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			name := "fig"
+			if pattern == htmlFigurePattern {
+				name = "figure"
+			}
+			innerEnd, end := findClosingTag(lower, name, loc[1])
+			inner := text[loc[1]:innerEnd]
+			// A figure wrapping a table is left to the table pass below
+			if htmlTablePattern.MatchString(inner) {
+				continue
+			}
+			data := TableData{Label: firstSubmatch(labelPattern, inner, 1), Caption: firstSubmatch(captionPattern, inner, 2)}
+			if data.Caption != "" {
+				matches = append(matches, tableMatch{chunkType: ChunkTypeFigure, data: data, start: loc[0], end: end})
+			}
+			covered = append(covered, [2]int{loc[0], end})
+		}
+	}
+
+	for _, loc := range htmlTablePattern.FindAllStringIndex(text, -1) {
+		if inside(loc[0]) {
+			continue
+		}
+		innerEnd, end := findClosingTag(lower, "table", loc[1])
+		inner := text[loc[1]:innerEnd]
+		data := TableData{Caption: firstSubmatch(captionPattern, inner, 2), Format: TableFormatHTML}
+		data.Header, data.Rows = parseHTMLTableRows(inner)
+		if len(data.Rows) == 0 && len(data.Header) == 0 {
+			continue
+		}
+		matches = append(matches, tableMatch{chunkType: ChunkTypeTable, data: data, start: loc[0], end: end})
+		covered = append(covered, [2]int{loc[0], end})
+	}
+
+	return matches
+}
+
+// findTextTables finds Markdown pipe tables, tab-delimited tables and caption lines. A table
+// caption directly above a table is attached to it; other captions become chunks of their own.
+func findTextTables(text string) []tableMatch {
+	lines := strings.Split(text, "\n")
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line) + 1
+	}
+
+	var matches []tableMatch
+	var pending *tableMatch // Table caption waiting for the table below it
+	flushPending := func() {
+		if pending != nil {
+			matches = append(matches, *pending)
+			pending = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		if caption := captionLine.FindStringSubmatch(line); caption != nil {
+			flushPending()
+			label := strings.Join(strings.Fields(caption[1]), " ")
+			match := tableMatch{data: TableData{Label: label, Caption: strings.TrimSpace(caption[2])}, start: offsets[i], end: offsets[i] + len(line)}
+			if strings.HasPrefix(strings.ToLower(label), "fig") {
+				match.chunkType = ChunkTypeFigure
+				matches = append(matches, match)
+			} else {
+				match.chunkType = ChunkTypeTableCaption
+				pending = &match
+			}
+			i++
+			continue
+		}
+
+		// Collect a run of table rows in a single format
+		format, end := "", i
+		switch {
+		case markdownTableRow.MatchString(line):
+			format = TableFormatMarkdown
+			for end < len(lines) && markdownTableRow.MatchString(lines[end]) {
+				end++
+			}
+		case filledCells(line, "\t") >= 2:
+			// A header with at least two filled columns, so tab-indented prose or code is left alone
+			format = TableFormatTSV
+			columns := len(strings.Split(line, "\t"))
+			for end < len(lines) && strings.Contains(lines[end], "\t") && len(strings.Split(lines[end], "\t")) == columns {
+				end++
+			}
+		}
+		if end-i < 2 {
+			// Blank lines may separate a caption from its table; anything else ends the wait
+			if strings.TrimSpace(line) != "" {
+				flushPending()
+			}
+			i++
+			continue
+		}
+
+		data := TableData{Format: format}
+		if format == TableFormatMarkdown {
+			data.Header, data.Rows = parseMarkdownTableRows(lines[i:end])
+		} else {
+			data.Header, data.Rows = parseDelimitedRows(lines[i:end], "\t")
+		}
+		match := tableMatch{chunkType: ChunkTypeTable, data: data, start: offsets[i], end: offsets[end] - 1}
+		if pending != nil {
+			match.data.Label, match.data.Caption, match.start = pending.data.Label, pending.data.Caption, pending.start
+			pending = nil
+		}
+		matches = append(matches, match)
+		i = end
+	}
+	flushPending()
+
+	return matches
+}
+
+// parseMarkdownTableRows splits pipe table lines into cells; a separator row marks the header
+func parseMarkdownTableRows(lines []string) ([]string, [][]string) {
+	var header []string
+	var rows [][]string
+	for i, line := range lines {
+		if markdownSeparatorRow.MatchString(line) {
+			if i == 1 && len(rows) == 1 {
+				header, rows = rows[0], nil
+			}
+			continue
+		}
+		rows = append(rows, splitPipeRow(line))
+	}
+	return header, rows
+}
+
+// splitPipeRow splits "| a | b \| c |" into ["a", "b | c"]
+func splitPipeRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// filledCells counts the non-blank cells of a delimited line
+func filledCells(line, delimiter string) int {
+	filled := 0
+	for _, cell := range strings.Split(line, delimiter) {
+		if strings.TrimSpace(cell) != "" {
+			filled++
+		}
+	}
+	return filled
+}
+
+// parseDelimitedRows splits delimited lines into cells, taking the first line as the header
+func parseDelimitedRows(lines []string, delimiter string) ([]string, [][]string) {
+	rows := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		cells := strings.Split(line, delimiter)
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		rows = append(rows, cells)
+	}
+	return rows[0], rows[1:]
+}
+
+// parseHTMLTableRows reads the cells of each <tr>; a first row of <th> cells is the header
+func parseHTMLTableRows(inner string) ([]string, [][]string) {
+	var header []string
+	var rows [][]string
+	for _, row := range htmlTableRow.Split(inner, -1)[1:] {
+		cells := htmlTableCell.FindAllStringSubmatch(row, -1)
+		if len(cells) == 0 {
+			continue
+		}
+		values := make([]string, len(cells))
+		isHeader := true
+		for j, cell := range cells {
+			values[j] = stripTags(cell[2])
+			isHeader = isHeader && strings.EqualFold(cell[1], "th")
+		}
+		if isHeader && header == nil && len(rows) == 0 {
+			header = values
+			continue
+		}
+		rows = append(rows, values)
+	}
+	return header, rows
+}
+
+// renderTableData is the chunk text of a table or caption: its label and caption followed by the
+// table as Markdown, so the chunk embeds and reads like the rest of the document
+func renderTableData(data TableData) string {
+	var parts []string
+	title := data.Label
+	if data.Caption != "" {
+		if title != "" {
+			title += ". "
+		}
+		title += data.Caption
+	}
+	if title != "" {
+		parts = append(parts, title)
+	}
+	if table := renderMarkdownTable(data.Header, data.Rows); table != "" {
+		parts = append(parts, table)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// renderMarkdownTable renders rows as a Markdown pipe table, with a separator after the header
+func renderMarkdownTable(header []string, rows [][]string) string {
+	var lines []string
+	writeRow := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = tableCellEscape.Replace(cell)
+		}
+		lines = append(lines, "| "+strings.Join(escaped, " | ")+" |")
+	}
+	if len(header) > 0 {
+		writeRow(header)
+		lines = append(lines, "|"+strings.Repeat(" --- |", len(header)))
+	}
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// firstSubmatch returns the group of the first match of pattern in text with its tags stripped
+func firstSubmatch(pattern *regexp.Regexp, text string, group int) string {
+	match := pattern.FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	return stripTags(match[group])
+}
+
+// IsTableChunk reports whether a chunk holds a table or a figure or table caption
+func IsTableChunk(chunk models.TextChunk) bool {
+	switch chunk.Metadata.ChunkType {
+	case ChunkTypeTable, ChunkTypeTableCaption, ChunkTypeFigure:
+		return true
+	}
+	return false
+}
+
+// FilterChunksByType keeps only the chunks of the given types, e.g. ChunkTypeTable for data extraction
+func FilterChunksByType(chunks []models.TextChunk, chunkTypes ...string) []models.TextChunk {
+	var filtered []models.TextChunk
+	for _, chunk := range chunks {
+		for _, chunkType := range chunkTypes {
+			if chunk.Metadata.ChunkType == chunkType {
+				filtered = append(filtered, chunk)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,68 @@
+package processors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindTextTablesTabDelimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		header []string
+		rows   int
+	}{
+		{
+			name:   "captioned table",
+			text:   "Table 1. Baseline\nArm\tN\tAge\nMetformin\t120\t54\nPlacebo\t118\t55\n",
+			header: []string{"Arm", "N", "Age"},
+			rows:   2,
+		},
+		{
+			name:   "empty data cells",
+			text:   "Outcome\tValue\nHbA1c\t\n",
+			header: []string{"Outcome", "Value"},
+			rows:   1,
+		},
+		{
+			name: "tab-indented code",
+			text: "Run the loop:\n\tfor i := range items {\n\t\tprocess(i)\n\t}\n",
+		},
+		{
+			name: "tab-indented prose",
+			text: "\tThe first paragraph is indented with a tab.\n\tSo is the second one, as in many exports.\n",
+		},
+		{
+			name: "header without data rows",
+			text: "Arm\tN\nSome prose follows the lone tabbed line.\n",
+		},
+		{
+			name: "one filled header column",
+			text: "Notes\t\nfirst\tsecond\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tables []tableMatch
+			for _, match := range findTextTables(test.text) {
+				if match.chunkType == ChunkTypeTable {
+					tables = append(tables, match)
+				}
+			}
+			if test.header == nil {
+				if len(tables) != 0 {
+					t.Fatalf("found table %+v in text that has none", tables[0].data)
+				}
+				return
+			}
+			if len(tables) != 1 {
+				t.Fatalf("found %d tables, want 1", len(tables))
+			}
+			data := tables[0].data
+			if data.Format != TableFormatTSV || !reflect.DeepEqual(data.Header, test.header) || len(data.Rows) != test.rows {
+				t.Errorf("got %s table %v with %d rows, want tsv %v with %d", data.Format, data.Header, len(data.Rows), test.header, test.rows)
+			}
+		})
+	}
+}
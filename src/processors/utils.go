@@ -1,73 +1,156 @@
 package processors
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"my-modus-app/src/schemas"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-type TextCleaner struct {
-	patterns []string
-}
-
-func NewTextCleaner() *TextCleaner {
-	return &TextCleaner{
-		patterns: []string{
-			`\s+`,                      // Multiple whitespaces
-			`\[[\d,\s]+\]`,             // Citation brackets
-			`\((?:[^()]|\([^)]*\))*\)`, // nested parentheses
-		},
-	}
-}
+// Overlap units supported by ChunkingConfig.OverlapUnit
+const (
+	OverlapTokens    = "tokens"
+	OverlapSentences = "sentences"
+)
 
-func (tc *TextCleaner) Clean(text string) (string, error) {
-	cleaned := text
-	for _, pattern := range tc.patterns {
-		re := regexp.MustCompile(pattern)
-		cleaned = re.ReplaceAllString(cleaned, " ")
-	}
-	return strings.TrimSpace(cleaned), nil
-}
+// sentenceBoundary matches the end of a sentence including trailing quotes/brackets and whitespace
+var sentenceBoundary = regexp.MustCompile(`[.!?]+["')\]]*\s+`)
 
+// applyOverlap prepends the tail of each previous chunk to the next one. The overlap is
+// measured in whole tokens or sentences so it never cuts through a word or a UTF-8 rune, and it
+// always leaves out the start of the previous chunk, so a short chunk is never duplicated whole.
+// The length of the duplicated prefix is recorded in Metadata.OverlapLength.
 func (c *Chunker) applyOverlap(chunks []schemas.TextChunk) []schemas.TextChunk {
 	if len(chunks) == 0 || c.config.ChunkOverlap <= 0 {
 		return chunks
 	}
-	var result []schemas.TextChunk
+	result := make([]schemas.TextChunk, 0, len(chunks))
 	for i, chunk := range chunks {
-		// Add the current chunk to the result
 		if i == 0 {
 			result = append(result, chunk)
-		} else {
-			// Add the overlap from the previous chunk
-			prevChunk := result[len(result)-1]
-			overlapEnd := min(len(prevChunk.Content), c.config.ChunkOverlap)
-			overlapContent := prevChunk.Content[len(prevChunk.Content)-overlapEnd:]
+			continue
+		}
 
-			// Create a new chunk with overlap and current content
-			newChunk := schemas.TextChunk{
-				ID:      chunk.ID,
-				Content: overlapContent + chunk.Content,
-				Metadata: schemas.ChunkMetadata{
-					StartIndex: prevChunk.Metadata.EndIndex - overlapEnd,
-					EndIndex:   chunk.Metadata.EndIndex,
-					Section:    chunk.Metadata.Section,
-					Timestamp:  chunk.Metadata.Timestamp,
-				},
-				Embedding: chunk.Embedding,
-				Score:     chunk.Score,
-				Relations: chunk.Relations,
-			}
-			result = append(result, newChunk)
+		// Tables, lists and other structured chunks would be broken by prose around them
+		if !isProseChunk(chunk) || !isProseChunk(chunks[i-1]) {
+			result = append(result, chunk)
+			continue
+		}
+
+		// Take the overlap from the original previous chunk, not the already overlapped one
+		prevContent := chunks[i-1].Content
+		var overlapContent string
+		switch c.config.OverlapUnit {
+		case OverlapSentences:
+			overlapContent = tailSentences(prevContent, c.config.ChunkOverlap)
+		default:
+			overlapContent = tailTokens(prevContent, c.config.ChunkOverlap)
+		}
+		if overlapContent == "" {
+			result = append(result, chunk)
+			continue
 		}
+
+		// Copy the chunk so every metadata field is preserved, then extend it
+		prefix := overlapContent + " "
+		newChunk := chunk
+		newChunk.Content = prefix + chunk.Content
+		newChunk.Metadata.OverlapLength = len(prefix)
+		newChunk.Metadata.StartIndex = max(chunk.Metadata.StartIndex-len(overlapContent), 0)
+		result = append(result, newChunk)
 	}
 	return result
 }
 
-// Utility function to calculate the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// isProseChunk reports whether a chunk is running text that overlap may be added to or taken from
+func isProseChunk(chunk schemas.TextChunk) bool {
+	return chunk.Metadata.ChunkType == "" || chunk.Metadata.ChunkType == ChunkTypeText
+}
+
+// StripOverlap returns the chunk content without the prefix duplicated from the previous chunk
+func StripOverlap(chunk schemas.TextChunk) string {
+	overlap := chunk.Metadata.OverlapLength
+	if overlap <= 0 || overlap > len(chunk.Content) {
+		return chunk.Content
+	}
+	return chunk.Content[overlap:]
+}
+
+// tailTokens returns the last n whitespace-separated tokens of text, sliced on rune boundaries.
+// The first token is never included, so the overlap stays shorter than the text it comes from.
+func tailTokens(text string, n int) string {
+	text = strings.TrimSpace(text)
+	var starts []int // Offsets of the tokens after the first, last token first
+	inToken := false
+	for end := len(text); end > 0 && len(starts) < n; {
+		r, size := utf8.DecodeLastRuneInString(text[:end])
+		if unicode.IsSpace(r) {
+			if inToken {
+				starts = append(starts, end)
+			}
+			inToken = false
+		} else {
+			inToken = true
+		}
+		end -= size
+	}
+	if len(starts) == 0 {
+		return ""
+	}
+	return text[starts[len(starts)-1]:]
+}
+
+// tailSentences returns the last n sentences of text, leaving out at least the first sentence
+// so the overlap stays shorter than the text it comes from
+func tailSentences(text string, n int) string {
+	text = strings.TrimSpace(text)
+	boundaries := sentenceBoundary.FindAllStringIndex(text, -1)
+	n = min(n, len(boundaries))
+	if n <= 0 {
+		return ""
+	}
+	return text[boundaries[len(boundaries)-n][1]:]
+}
+
+// ChunkScope is the AssignContentIDs scope of a user's chunks in one research, or outside any
+// research when researchID is empty. The same source ingested for two researches, perhaps with another strategy or embedding model, thus
+// gets separate chunks that re-ingesting one research never overwrites or removes in the other.
+func ChunkScope(userID, researchID string) string {
+	return userID + "\x00" + researchID
+}
+
+// AssignContentIDs replaces the random chunk IDs with a hash of the scope (such as ChunkScope),
+// the source article or document, and the chunk's type, position and content, so chunking the
+// same source again gives the same IDs. Parent and child references are rewritten to match.
+func AssignContentIDs(chunks []schemas.TextChunk, scope string) {
+	ids := make(map[string]string, len(chunks))
+	for i := range chunks {
+		chunk := &chunks[i]
+		source := ""
+		if chunk.Metadata.MedlineData != nil {
+			source = "pmid:" + chunk.Metadata.MedlineData.PMID
+		} else if chunk.Metadata.DocumentData != nil {
+			source = "document:" + chunk.Metadata.DocumentData.ID
+		}
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%s", scope, source, chunk.Metadata.ChunkType, chunk.Metadata.StartIndex, chunk.Content)))
+		id := hex.EncodeToString(sum[:16])
+		ids[chunk.ID] = id
+		chunk.ID = id
+	}
+
+	for i := range chunks {
+		metadata := &chunks[i].Metadata
+		if id, ok := ids[metadata.ParentID]; ok {
+			metadata.ParentID = id
+		}
+		for j, child := range metadata.ChildIDs {
+			if id, ok := ids[child]; ok {
+				metadata.ChildIDs[j] = id
+			}
+		}
 	}
-	return b
 }
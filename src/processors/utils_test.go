@@ -0,0 +1,73 @@
+package processors
+
+import (
+	"testing"
+
+	"my-modus-app/src/schemas"
+)
+
+func TestOverlapTails(t *testing.T) {
+	tests := []struct {
+		name string
+		tail func(string, int) string
+		text string
+		n    int
+		want string
+	}{
+		{"tokens", tailTokens, "Metformin lowers fasting glucose.", 2, "fasting glucose."},
+		{"tokens with multibyte runes", tailTokens, "Dose was 5 µg  per kg ", 3, "µg  per kg"},
+		{"tokens capped below the text", tailTokens, "Short chunk here", 5, "chunk here"},
+		{"single token", tailTokens, "Results", 3, ""},
+		{"sentences", tailSentences, "Diabetes is common. Metformin helps. Weight is stable.", 2, "Metformin helps. Weight is stable."},
+		{"sentences capped below the text", tailSentences, "First finding. Second finding.", 4, "Second finding."},
+		{"single sentence", tailSentences, "Only one sentence here.", 2, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.tail(test.text, test.n); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyOverlapByTokensAndSentences(t *testing.T) {
+	chunks := []schemas.TextChunk{
+		{Content: "Diabetes is common in adults. Metformin lowers glucose."},
+		{Content: "Weight was stable."},
+		{Content: "Results"},
+		{Content: "Adverse events were rare."},
+	}
+
+	tests := []struct {
+		unit    string
+		overlap int
+		want    []string
+	}{
+		{OverlapTokens, 3, []string{
+			"Diabetes is common in adults. Metformin lowers glucose.",
+			"Metformin lowers glucose. Weight was stable.",
+			"was stable. Results",       // Capped below the three tokens of the previous chunk
+			"Adverse events were rare.", // A one-token chunk gives no overlap
+		}},
+		{OverlapSentences, 1, []string{
+			"Diabetes is common in adults. Metformin lowers glucose.",
+			"Metformin lowers glucose. Weight was stable.",
+			"Results", // A one-sentence chunk gives no overlap
+			"Adverse events were rare.",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.unit, func(t *testing.T) {
+			chunker := NewChunker(ChunkingConfig{MaxChunkSize: 500, MinChunkSize: 10, ChunkOverlap: test.overlap, OverlapUnit: test.unit})
+			for i, chunk := range chunker.applyOverlap(chunks) {
+				if chunk.Content != test.want[i] {
+					t.Errorf("chunk %d = %q, want %q", i, chunk.Content, test.want[i])
+				}
+				if StripOverlap(chunk) != chunks[i].Content {
+					t.Errorf("chunk %d without overlap = %q, want %q", i, StripOverlap(chunk), chunks[i].Content)
+				}
+			}
+		})
+	}
+}
@@ -5,15 +5,35 @@ import (
 )
 
 type ChunkMetadata struct {
-	StartIndex  int                    `json:"ChunkMetadata.start_index"`
-	EndIndex    int                    `json:"ChunkMetadata.end_index"`
-	Section     string                 `json:"ChunkMetadata.section"`
-	Citations   []string               `json:"ChunkMetadata.citations"`
-	Keywords    []string               `json:"ChunkMetadata.keywords"`
-	EntityTypes []string               `json:"ChunkMetadata.entity_types"`
-	Timestamp   time.Time              `json:"ChunkMetadata.timestamp"`
-	Confidence  float64                `json:"ChunkMetadata.confidence"`
-	MedlineData MedlineArticleMetadata `json:"ChunkMetadata.medline_data"`
+	StartIndex         int                     `json:"ChunkMetadata.start_index"`
+	EndIndex           int                     `json:"ChunkMetadata.end_index"`
+	OverlapLength      int                     `json:"ChunkMetadata.overlap_length"` // Bytes at the start of Content repeated from the previous chunk
+	Section            string                  `json:"ChunkMetadata.section"`
+	ChunkType          string                  `json:"ChunkMetadata.chunk_type"`       // text, parent, table, table_caption or figure
+	Properties         string                  `json:"ChunkMetadata.properties"`       // JSON structure of table and caption chunks
+	ParentID           string                  `json:"ChunkMetadata.parent_id"`        // Section-level chunk a child was cut from
+	ChildIDs           []string                `json:"ChunkMetadata.child_ids"`        // Sentence-window chunks of a parent
+	Citations          []string                `json:"ChunkMetadata.citations"`        // Raw in-text citation markers
+	CitedReferences    []string                `json:"ChunkMetadata.cited_references"` // Identifiers the markers resolve to
+	Keywords           []string                `json:"ChunkMetadata.keywords"`
+	EntityTypes        []string                `json:"ChunkMetadata.entity_types"`
+	Entities           []EntityMention         `json:"ChunkMetadata.entities"`
+	Timestamp          time.Time               `json:"ChunkMetadata.timestamp"`
+	Confidence         float64                 `json:"ChunkMetadata.confidence"`
+	EmbeddingModel     string                  `json:"ChunkMetadata.embedding_model"` // Model that produced TextChunk.Embedding
+	EmbeddingDimension int                     `json:"ChunkMetadata.embedding_dimension"`
+	MedlineData        *MedlineArticleMetadata `json:"ChunkMetadata.medline_data,omitempty"`  // Set for PubMed articles
+	DocumentData       *DocumentMetadata       `json:"ChunkMetadata.document_data,omitempty"` // Set for uploaded documents
+}
+
+// EntityMention is a typed biomedical entity found in a chunk, with offsets into the chunk content
+type EntityMention struct {
+	Text       string `json:"EntityMention.text"`
+	Type       string `json:"EntityMention.type"`
+	Normalized string `json:"EntityMention.normalized"`
+	StartIndex int    `json:"EntityMention.start_index"`
+	EndIndex   int    `json:"EntityMention.end_index"`
+	Source     string `json:"EntityMention.source"`
 }
 
 type TextChunk struct {
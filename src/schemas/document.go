@@ -0,0 +1,42 @@
+package schemas
+
+// MIME types accepted for uploaded documents
+const (
+	MimeTypePlainText = "text/plain"
+	MimeTypeMarkdown  = "text/markdown"
+	MimeTypeHTML      = "text/html"
+	MimeTypeJATS      = "application/jats+xml"
+)
+
+// Document is a document uploaded by a user rather than retrieved from PubMed
+type Document struct {
+	ID       string   `json:"ID"`
+	Title    string   `json:"Title"`
+	Authors  []Author `json:"Authors"`
+	Source   string   `json:"Source"`   // Where the document came from, e.g. a file name or URL
+	MimeType string   `json:"MimeType"` // One of the MimeType constants; empty means plain text
+	Content  string   `json:"Content"`
+}
+
+// DocumentMetadata is what every chunk of an uploaded document records about it
+type DocumentMetadata struct {
+	ID        string   `json:"DocumentMetadata.id"`
+	Title     string   `json:"DocumentMetadata.title"`
+	Authors   []Author `json:"DocumentMetadata.authors"`
+	Source    string   `json:"DocumentMetadata.source"`
+	MimeType  string   `json:"DocumentMetadata.mime_type"`
+	DateAdded string   `json:"DocumentMetadata.date_added"`
+	DType     []string `json:"dgraph.type,omitempty"`
+}
+
+func ConvertDocumentToMetadata(document Document, dateAdded string) DocumentMetadata {
+	return DocumentMetadata{
+		ID:        document.ID,
+		Title:     document.Title,
+		Authors:   document.Authors,
+		Source:    document.Source,
+		MimeType:  document.MimeType,
+		DateAdded: dateAdded,
+		DType:     []string{"DocumentMetadata"},
+	}
+}
@@ -6,6 +6,7 @@ type MedlineArticleMetadata struct {
 	Title            string      `json:"MedlineArticleMetadata.title"`
 	Authors          []Author    `json:"MedlineArticleMetadata.authors"`
 	MeshTerms        []string    `json:"MedlineArticleMetadata.mesh_terms"`
+	Substances       []string    `json:"MedlineArticleMetadata.substances"`
 	JournalInfo      JournalInfo `json:"MedlineArticleMetadata.journal_info"`
 	PublicationTypes []string    `json:"MedlineArticleMetadata.publication_types"`
 	Language         string      `json:"MedlineArticleMetadata.language"`
@@ -18,6 +19,7 @@ type Author struct {
 	FullName   string `json:"Author.full_name"`
 	LastName   string `json:"Author.last_name"`
 	Afiliation string `json:"Author.affiliation"`
+	Identifier string `json:"Author.identifier,omitempty"` // AUID such as "ORCID: 0000-0002-1825-0097"
 }
 
 type JournalInfo struct {
@@ -51,6 +53,7 @@ type MedlineArticle struct {
 	Abstract         string      `json:"Abstract"`
 	Authors          []Author    `json:"Authors"`
 	MeshTerms        []string    `json:"MeshTerms"`
+	Substances       []string    `json:"Substances"`
 	JournalInfo      JournalInfo `json:"JournalInfo"`
 	PublicationTypes []string    `json:"PublicationTypes"`
 	Language         string      `json:"Language"`
@@ -65,6 +68,7 @@ func ConvertToMetadata(article MedlineArticle) MedlineArticleMetadata {
 		Title:            article.Title,
 		Authors:          article.Authors,
 		MeshTerms:        article.MeshTerms,
+		Substances:       article.Substances,
 		JournalInfo:      article.JournalInfo,
 		PublicationTypes: article.PublicationTypes,
 		Language:         article.Language,
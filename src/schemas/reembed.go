@@ -0,0 +1,42 @@
+package schemas
+
+// Status of a re-embedding job
+const (
+	ReembedRunning = "running"
+	ReembedSwapped = "swapped"
+	ReembedFailed  = "failed"
+)
+
+// DefaultEmbeddingPredicate holds the chunk embeddings until a re-embedding job swaps in another
+const DefaultEmbeddingPredicate = "TextChunk.embedding"
+
+// ReembedJob tracks a re-embedding of every stored chunk with a new model. The cursor is the last
+// chunk uid processed, so a job can be continued from where it stopped.
+type ReembedJob struct {
+	UID             string   `json:"uid,omitempty"`
+	ID              string   `json:"ReembedJob.id"`
+	Model           string   `json:"ReembedJob.model"`
+	Predicate       string   `json:"ReembedJob.predicate"` // Versioned predicate the new vectors are written to
+	CleaningProfile string   `json:"ReembedJob.cleaning_profile"`
+	Cursor          string   `json:"ReembedJob.cursor"`
+	Processed       int      `json:"ReembedJob.processed"`
+	Total           int      `json:"ReembedJob.total"`
+	Dimension       int      `json:"ReembedJob.dimension"`
+	Status          string   `json:"ReembedJob.status"`
+	Error           string   `json:"ReembedJob.error"`
+	StartedAt       string   `json:"ReembedJob.started_at"`
+	UpdatedAt       string   `json:"ReembedJob.updated_at"`
+	DType           []string `json:"dgraph.type,omitempty"`
+}
+
+// EmbeddingVersion names the predicate that holds the chunk embeddings in use. Pointing it at a
+// new predicate swaps every chunk to the new model at once.
+type EmbeddingVersion struct {
+	UID       string   `json:"uid,omitempty"`
+	Name      string   `json:"EmbeddingVersion.name"`
+	Predicate string   `json:"EmbeddingVersion.predicate"`
+	Model     string   `json:"EmbeddingVersion.model"`
+	Dimension int      `json:"EmbeddingVersion.dimension"`
+	UpdatedAt string   `json:"EmbeddingVersion.updated_at"`
+	DType     []string `json:"dgraph.type,omitempty"`
+}
@@ -0,0 +1,28 @@
+package schemas
+
+// Retrievers a hybrid search result can be matched by
+const (
+	RetrieverLexical = "lexical"
+	RetrieverVector  = "vector"
+)
+
+// SearchFilters narrow a chunk search; empty fields do not filter
+type SearchFilters struct {
+	Sections         []string `json:"sections"`          // Any of these sections, ignoring case
+	PublicationTypes []string `json:"publication_types"` // Any of these MEDLINE publication types, ignoring case
+	YearFrom         int      `json:"year_from"`         // Published in or after this year
+	YearTo           int      `json:"year_to"`           // Published in or before this year
+	MeshTerms        []string `json:"mesh_terms"`        // Any of these MeSH headings, ignoring qualifiers and case
+}
+
+// RetrievedChunk is a hybrid search result with the retrievers that matched it. Ranks start at 1;
+// a zero rank means that retriever did not return the chunk.
+type RetrievedChunk struct {
+	Chunk        TextChunk `json:"chunk"`
+	Score        float64   `json:"score"` // Reciprocal rank fusion score, also set as Chunk.Score
+	MatchedBy    []string  `json:"matched_by"`
+	LexicalRank  int       `json:"lexical_rank"`
+	LexicalScore float64   `json:"lexical_score"` // BM25
+	VectorRank   int       `json:"vector_rank"`
+	VectorScore  float64   `json:"vector_score"` // Cosine similarity
+}
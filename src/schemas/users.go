@@ -13,20 +13,24 @@ type User struct {
 }
 
 type LoginUser struct {
-	ID string `json:"id"`
-	Name string `json:"name"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
 	Email string `json:"email"`
 }
 
 type Research struct {
-	User             User        `json:"Research.user"`
-	ResearchType     string      `json:"Research.research_type"`
-	Title            string      `json:"Research.title"`
-	Description      string      `json:"Research.description"`
-	PubmedIds        []string    `json:"Research.pubmed_ids"`
-	AssociatedChunks []TextChunk `json:"Research.associated_chunks"`
-	ResearchResult   string      `json:"Research.research_result"`
-	DType            []string    `json:"dgraph.type,omitempty"`
+	ID                 string             `json:"Research.id"`
+	User               User               `json:"Research.user"`
+	ResearchType       string             `json:"Research.research_type"`
+	Title              string             `json:"Research.title"`
+	Description        string             `json:"Research.description"`
+	PubmedIds          []string           `json:"Research.pubmed_ids"`
+	Documents          []DocumentMetadata `json:"Research.documents"`
+	AssociatedChunks   []TextChunk        `json:"Research.associated_chunks"`
+	ResearchResult     string             `json:"Research.research_result"`
+	EmbeddingModel     string             `json:"Research.embedding_model"`     // Every chunk of the research is embedded with this model
+	EmbeddingPredicate string             `json:"Research.embedding_predicate"` // Predicate holding the vectors of that model
+	DType              []string           `json:"dgraph.type,omitempty"`
 }
 
 type Chat struct {
@@ -4,19 +4,12 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/hypermodeinc/modus/sdk/go/pkg/models"
-	"github.com/hypermodeinc/modus/sdk/go/pkg/models/openai"
+	"my-modus-app/src/utils"
 )
 
 const modelName = "text-generator"
 
 func GenerateAdvancedMeSHKeywords(articleText string) (string, error) {
-	// Retrieve the OpenAI chat model
-	model, err := models.GetModel[openai.ChatModel](modelName)
-	if err != nil {
-		return "", fmt.Errorf("failed to get model: %w", err)
-	}
-
 	// System instruction: Define the behavior of the model for MeSH keyword generation
 	instruction := `
 You are a medical librarian with expert knowledge of MeSH (Medical Subject Headings) and PubMed search strategies.
@@ -39,25 +32,17 @@ Analyze the following text and generate an advanced PubMed search query using Me
 "%s"
 `, articleText)
 
-	// Prepare the model input
-	input, err := model.CreateInput(
-		openai.NewSystemMessage(instruction),
-		openai.NewUserMessage(prompt),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create model input: %w", err)
-	}
-
-	// Configure optional parameters for the OpenAI chat model
-	input.Temperature = 0.2 // Low temperature for deterministic and precise output
-	input.MaxTokens = 1024  // Allow for longer responses with complex queries
-
-	// Invoke the model
-	output, err := model.Invoke(input)
+	// Invoke the chat model
+	content, err := utils.GetChatModel(modelName).Complete(utils.ChatRequest{
+		System:      instruction,
+		User:        prompt,
+		Temperature: 0.2,  // Low temperature for deterministic and precise output
+		MaxTokens:   1024, // Allow for longer responses with complex queries
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to invoke model: %w", err)
+		return "", err
 	}
 
 	// Return the PubMed search query
-	return strings.TrimSpace(output.Choices[0].Message.Content), nil
+	return strings.TrimSpace(content), nil
 }
@@ -5,8 +5,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/hypermodeinc/modus/sdk/go/pkg/models"
-	"github.com/hypermodeinc/modus/sdk/go/pkg/models/openai"
+	"my-modus-app/src/utils"
 )
 
 // ReviewType represents different types of content structure
@@ -62,11 +61,6 @@ func GenerateContent(topic string, reviewType ReviewType, description string) ([
 
 // GenerateContentSections generates structured section points based on the review type
 func GenerateContentSections(topic string, reviewType ReviewType) ([]string, error) {
-	model, err := models.GetModel[openai.ChatModel](modelName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get model: %w", err)
-	}
-
 	instruction := getInstructionForType(reviewType)
 
 	prompt := fmt.Sprintf(`
@@ -94,32 +88,21 @@ You are a highly intelligent and structured assistant specializing in generating
 Now, generate the key discussion points for the topic in a similar structure.
 `, topic, reviewType)
 
-	input, err := model.CreateInput(
-		openai.NewSystemMessage(instruction),
-		openai.NewUserMessage(prompt),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create model input: %w", err)
-	}
-
-	input.Temperature = getTemperatureForType(reviewType)
-	input.MaxTokens = 1024
-
-	output, err := model.Invoke(input)
+	content, err := utils.GetChatModel(modelName).Complete(utils.ChatRequest{
+		System:      instruction,
+		User:        prompt,
+		Temperature: getTemperatureForType(reviewType),
+		MaxTokens:   1024,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to invoke model: %w", err)
+		return nil, err
 	}
 
-	return parseSections(output.Choices[0].Message.Content), nil
+	return parseSections(content), nil
 }
 
 // GenerateSectionContent generates content for a specific section
 func GenerateSectionContent(topic, segment string, reviewType ReviewType) (string, error) {
-	model, err := models.GetModel[openai.ChatModel](modelName)
-	if err != nil {
-		return "", fmt.Errorf("failed to get model: %w", err)
-	}
-
 	instruction := getInstructionForType(reviewType)
 
 	prompt := fmt.Sprintf(`
@@ -150,23 +133,17 @@ Title: Key Challenges in [Topic]
 Now, generate content for the section.
 `, reviewType, topic, segment)
 
-	input, err := model.CreateInput(
-		openai.NewSystemMessage(instruction),
-		openai.NewUserMessage(prompt),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create model input: %w", err)
-	}
-
-	input.Temperature = getTemperatureForType(reviewType)
-	input.MaxTokens = 1024
-
-	output, err := model.Invoke(input)
+	content, err := utils.GetChatModel(modelName).Complete(utils.ChatRequest{
+		System:      instruction,
+		User:        prompt,
+		Temperature: getTemperatureForType(reviewType),
+		MaxTokens:   1024,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to invoke model: %w", err)
+		return "", err
 	}
 
-	return strings.TrimSpace(output.Choices[0].Message.Content), nil
+	return strings.TrimSpace(content), nil
 }
 
 // Helper Functions
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// EmbedFunc embeds several texts in one request, returning one vector per text in order
+type EmbedFunc func(texts ...string) ([][]float32, error)
+
+// EmbeddingBatchConfig limits the size of each embedding request
+type EmbeddingBatchConfig struct {
+	// BatchSize is the most texts sent in one request
+	BatchSize int `json:"batch_size"`
+	// BatchTokens is the estimated token budget of one request
+	BatchTokens int `json:"batch_tokens"`
+	// MaxRetries is how many times a failed batch is retried before giving up; negative disables retries
+	MaxRetries int `json:"max_retries"`
+}
+
+// DefaultEmbeddingBatchConfig stays well inside the embedding API's per-request limits
+func DefaultEmbeddingBatchConfig() EmbeddingBatchConfig {
+	return EmbeddingBatchConfig{
+		BatchSize:   100,
+		BatchTokens: 16000,
+		MaxRetries:  2,
+	}
+}
+
+// WithDefaults fills any zero-valued fields from DefaultEmbeddingBatchConfig. A negative
+// MaxRetries is kept, so "no retries" survives being defaulted again.
+func (config EmbeddingBatchConfig) WithDefaults() EmbeddingBatchConfig {
+	defaults := DefaultEmbeddingBatchConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BatchTokens <= 0 {
+		config.BatchTokens = defaults.BatchTokens
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaults.MaxRetries
+	}
+	return config
+}
+
+// retries is how many times a failed batch is retried
+func (config EmbeddingBatchConfig) retries() int {
+	return max(config.MaxRetries, 0)
+}
+
+// retryDelay is the wait before the first retry; each later retry waits one more delay
+var retryDelay = time.Second
+
+// BatchEmbedder embeds many texts with as few requests as the batch limits allow
+type BatchEmbedder struct {
+	embed  EmbedFunc
+	config EmbeddingBatchConfig
+}
+
+// NewBatchEmbedder batches requests to embed; a nil embed uses GetEmbeddingsForTextsWithOpenAI
+func NewBatchEmbedder(embed EmbedFunc, config EmbeddingBatchConfig) *BatchEmbedder {
+	if embed == nil {
+		embed = GetEmbeddingsForTextsWithOpenAI
+	}
+	return &BatchEmbedder{embed: embed, config: config.WithDefaults()}
+}
+
+// EstimateTokens approximates the token count of text at four bytes per token
+func EstimateTokens(text string) int {
+	return len(text)/4 + 1
+}
+
+// Batches groups text indexes into batches within the size and token limits. A text larger than
+// the token budget gets a batch of its own.
+func (b *BatchEmbedder) Batches(texts []string) [][]int {
+	var batches [][]int
+	var current []int
+	tokens := 0
+	for i, text := range texts {
+		cost := EstimateTokens(text)
+		if len(current) > 0 && (len(current) >= b.config.BatchSize || tokens+cost > b.config.BatchTokens) {
+			batches = append(batches, current)
+			current, tokens = nil, 0
+		}
+		current = append(current, i)
+		tokens += cost
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// Embed returns one vector per text, in the order of texts. Each batch is retried on its own, so a
+// failure never repeats requests that already succeeded.
+func (b *BatchEmbedder) Embed(texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	pending := b.Batches(texts)
+
+	retries := b.config.retries()
+	for attempt := 0; attempt <= retries && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying %d failed embedding batches, attempt %d/%d", len(pending), attempt, retries)
+			time.Sleep(time.Duration(attempt) * retryDelay)
+		}
+
+		var failed [][]int
+		var lastErr error
+		for _, batch := range pending {
+			if err := b.embedBatch(texts, batch, embeddings); err != nil {
+				lastErr = err
+				failed = append(failed, batch)
+			}
+		}
+		pending = failed
+
+		if len(pending) > 0 && attempt == retries {
+			return nil, fmt.Errorf("%d embedding batches failed after %d attempts: %w", len(pending), attempt+1, lastErr)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// embedBatch embeds the texts at the batch's indexes and stores the vectors at the same indexes
+func (b *BatchEmbedder) embedBatch(texts []string, batch []int, embeddings [][]float32) error {
+	batchTexts := make([]string, len(batch))
+	for i, index := range batch {
+		batchTexts[i] = texts[index]
+	}
+
+	results, err := b.embed(batchTexts...)
+	if err != nil {
+		return err
+	}
+	if len(results) != len(batch) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(batch), len(results))
+	}
+	for i, index := range batch {
+		if len(results[i]) == 0 {
+			return fmt.Errorf("empty embedding for text %d", index)
+		}
+		embeddings[index] = results[i]
+	}
+	return nil
+}
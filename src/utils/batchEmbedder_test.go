@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// flakyEmbed fails the first failures requests that contain text, and counts the requests per first text
+func flakyEmbed(text string, failures int, calls map[string]int) EmbedFunc {
+	return func(texts ...string) ([][]float32, error) {
+		calls[texts[0]]++
+		vectors := make([][]float32, len(texts))
+		for i, t := range texts {
+			if t == text && failures > 0 {
+				failures--
+				return nil, errors.New("rate limited")
+			}
+			vectors[i] = []float32{float32(len(t)), 1}
+		}
+		return vectors, nil
+	}
+}
+
+func TestEmbeddingBatchConfigDefaultsRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		want    int
+	}{
+		{"zero value", 0, 2},
+		{"explicit", 5, 5},
+		{"disabled", -1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := EmbeddingBatchConfig{MaxRetries: test.retries}.WithDefaults().WithDefaults()
+			if got := config.retries(); got != test.want {
+				t.Errorf("retries() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBatchEmbedderRetriesOnlyFailedBatches(t *testing.T) {
+	retryDelay = 0
+	defer func() { retryDelay = time.Second }()
+
+	texts := []string{"a", "bb", "ccc", "dddd"}
+	tests := []struct {
+		name      string
+		failures  int
+		retries   int
+		wantErr   bool
+		wantCalls map[string]int
+	}{
+		{"recovers with default retries", 2, 0, false, map[string]int{"a": 1, "ccc": 3}},
+		{"gives up after the retries", 3, 0, true, map[string]int{"a": 1, "ccc": 3}},
+		{"no retries", 1, -1, true, map[string]int{"a": 1, "ccc": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := map[string]int{}
+			batcher := NewBatchEmbedder(flakyEmbed("ccc", test.failures, calls), EmbeddingBatchConfig{BatchSize: 2, MaxRetries: test.retries})
+
+			vectors, err := batcher.Embed(texts)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Embed() error = %v, want error %v", err, test.wantErr)
+			}
+			if fmt.Sprint(calls) != fmt.Sprint(test.wantCalls) {
+				t.Errorf("requests per batch %v, want %v", calls, test.wantCalls)
+			}
+			if err != nil {
+				return
+			}
+			for i, text := range texts {
+				if vectors[i][0] != float32(len(text)) {
+					t.Errorf("vector %d belongs to another text: %v", i, vectors[i])
+				}
+			}
+		})
+	}
+}
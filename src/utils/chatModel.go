@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hypermodeinc/modus/sdk/go/pkg/models"
+	"github.com/hypermodeinc/modus/sdk/go/pkg/models/openai"
+)
+
+// ChatRequest is a single system and user message exchange with a chat model
+type ChatRequest struct {
+	System      string
+	User        string
+	Temperature float64 // Zero keeps the model's default
+	MaxTokens   int     // Zero keeps the model's default
+}
+
+// ChatModel completes a chat request with one particular model
+type ChatModel interface {
+	// ModelName is the model's name in modus.json
+	ModelName() string
+	// Complete returns the content of the model's first choice
+	Complete(request ChatRequest) (string, error)
+}
+
+// OpenAIChatModel calls a model served through an OpenAI-compatible chat API
+type OpenAIChatModel struct {
+	modelName string
+}
+
+func NewOpenAIChatModel(modelName string) *OpenAIChatModel {
+	return &OpenAIChatModel{modelName: modelName}
+}
+
+func (m *OpenAIChatModel) ModelName() string { return m.modelName }
+
+func (m *OpenAIChatModel) Complete(request ChatRequest) (string, error) {
+	model, err := models.GetModel[openai.ChatModel](m.modelName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get model: %w", err)
+	}
+
+	input, err := model.CreateInput(
+		openai.NewSystemMessage(request.System),
+		openai.NewUserMessage(request.User),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create model input: %w", err)
+	}
+	if request.Temperature != 0 {
+		input.Temperature = request.Temperature
+	}
+	if request.MaxTokens != 0 {
+		input.MaxTokens = request.MaxTokens
+	}
+
+	output, err := model.Invoke(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to invoke model: %w", err)
+	}
+	if len(output.Choices) == 0 {
+		return "", fmt.Errorf("model returned no choices")
+	}
+
+	return output.Choices[0].Message.Content, nil
+}
+
+var (
+	chatModelsMu sync.Mutex
+	// chatModels holds models registered in place of the Modus SDK, such as fakes
+	chatModels = map[string]ChatModel{}
+)
+
+// RegisterChatModel makes GetChatModel return model for its name instead of calling the Modus SDK
+func RegisterChatModel(model ChatModel) {
+	chatModelsMu.Lock()
+	defer chatModelsMu.Unlock()
+	chatModels[model.ModelName()] = model
+}
+
+// GetChatModel returns the registered model with this name, or the Modus SDK model from modus.json
+func GetChatModel(modelName string) ChatModel {
+	chatModelsMu.Lock()
+	defer chatModelsMu.Unlock()
+	if model, ok := chatModels[modelName]; ok {
+		return model
+	}
+	return NewOpenAIChatModel(modelName)
+}
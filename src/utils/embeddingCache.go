@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+)
+
+// EmbeddingCache stores embeddings by content-addressed key, see EmbeddingCacheKey
+type EmbeddingCache interface {
+	// GetMany returns the cached embeddings of the keys it knows; missing keys are simply absent
+	GetMany(keys []string) (map[string][]float32, error)
+	// SetMany stores embeddings by key
+	SetMany(entries map[string][]float32) error
+}
+
+// CacheStats counts the texts served from the cache and the texts sent to the model
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// EmbeddingCacheKey hashes the model name with the whitespace-normalised text, so the same text
+// embedded by another model never collides
+func EmbeddingCacheKey(modelName, text string) string {
+	normalized := strings.Join(strings.Fields(text), " ")
+	sum := sha256.Sum256([]byte(modelName + "\x00" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryEmbeddingCache keeps embeddings for the lifetime of the process
+type MemoryEmbeddingCache struct {
+	mu      sync.Mutex
+	entries map[string][]float32
+}
+
+func NewMemoryEmbeddingCache() *MemoryEmbeddingCache {
+	return &MemoryEmbeddingCache{entries: make(map[string][]float32)}
+}
+
+func (c *MemoryEmbeddingCache) GetMany(keys []string) (map[string][]float32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found := make(map[string][]float32)
+	for _, key := range keys {
+		if embedding, ok := c.entries[key]; ok {
+			found[key] = embedding
+		}
+	}
+	return found, nil
+}
+
+func (c *MemoryEmbeddingCache) SetMany(entries map[string][]float32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, embedding := range entries {
+		c.entries[key] = embedding
+	}
+	return nil
+}
+
+// LayeredEmbeddingCache reads a fast cache before a persistent one and copies persistent hits forward
+type LayeredEmbeddingCache struct {
+	fast       EmbeddingCache
+	persistent EmbeddingCache
+}
+
+func NewLayeredEmbeddingCache(fast, persistent EmbeddingCache) *LayeredEmbeddingCache {
+	return &LayeredEmbeddingCache{fast: fast, persistent: persistent}
+}
+
+func (c *LayeredEmbeddingCache) GetMany(keys []string) (map[string][]float32, error) {
+	found, err := c.fast.GetMany(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	stored, err := c.persistent.GetMany(missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, embedding := range stored {
+		found[key] = embedding
+	}
+	if err := c.fast.SetMany(stored); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (c *LayeredEmbeddingCache) SetMany(entries map[string][]float32) error {
+	if err := c.fast.SetMany(entries); err != nil {
+		return err
+	}
+	return c.persistent.SetMany(entries)
+}
+
+// CachedEmbedder consults a cache before calling the model and only embeds the texts it misses.
+// It is itself an Embedder.
+type CachedEmbedder struct {
+	cache    EmbeddingCache
+	embedder Embedder
+
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+func NewCachedEmbedder(cache EmbeddingCache, embedder Embedder) *CachedEmbedder {
+	return &CachedEmbedder{cache: cache, embedder: embedder}
+}
+
+func (c *CachedEmbedder) ModelName() string { return c.embedder.ModelName() }
+func (c *CachedEmbedder) Dimension() int    { return c.embedder.Dimension() }
+
+// Embed returns one vector per text. Cache failures are logged and treated as misses, so a
+// broken cache slows ingestion down but never stops it.
+func (c *CachedEmbedder) Embed(texts ...string) ([][]float32, error) {
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = EmbeddingCacheKey(c.embedder.ModelName(), text)
+	}
+
+	cached, err := c.cache.GetMany(keys)
+	if err != nil {
+		log.Printf("Embedding cache lookup failed: %v", err)
+		cached = map[string][]float32{}
+	}
+
+	// Send each distinct missing text to the model once
+	var missTexts []string
+	missIndex := make(map[string]int)
+	for i, key := range keys {
+		if _, ok := cached[key]; ok {
+			continue
+		}
+		if _, ok := missIndex[key]; !ok {
+			missIndex[key] = len(missTexts)
+			missTexts = append(missTexts, texts[i])
+		}
+	}
+
+	var fresh [][]float32
+	if len(missTexts) > 0 {
+		fresh, err = c.embedder.Embed(missTexts...)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := make(map[string][]float32, len(missIndex))
+		for key, index := range missIndex {
+			entries[key] = fresh[index]
+		}
+		if err := c.cache.SetMany(entries); err != nil {
+			log.Printf("Embedding cache update failed: %v", err)
+		}
+	}
+
+	results := make([][]float32, len(texts))
+	hits := 0
+	for i, key := range keys {
+		if embedding, ok := cached[key]; ok {
+			results[i] = embedding
+			hits++
+		} else {
+			results[i] = fresh[missIndex[key]]
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Hits += int64(hits)
+	c.stats.Misses += int64(len(texts) - hits)
+	c.mu.Unlock()
+
+	return results, nil
+}
+
+// Stats returns the hits and misses counted so far
+func (c *CachedEmbedder) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// countingEmbedder returns the text length as a vector and records the texts it embeds
+type countingEmbedder struct {
+	name     string
+	embedded []string
+}
+
+func (e *countingEmbedder) ModelName() string { return e.name }
+func (e *countingEmbedder) Dimension() int    { return 2 }
+
+func (e *countingEmbedder) Embed(texts ...string) ([][]float32, error) {
+	e.embedded = append(e.embedded, texts...)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), float32(len(e.name))}
+	}
+	return vectors, nil
+}
+
+func TestEmbeddingCacheKeyNormalizesWhitespace(t *testing.T) {
+	base := EmbeddingCacheKey("model-a", "Metformin lowers glucose.")
+
+	tests := []struct {
+		name  string
+		model string
+		text  string
+		same  bool
+	}{
+		{"identical", "model-a", "Metformin lowers glucose.", true},
+		{"inner whitespace", "model-a", "Metformin \t lowers\nglucose.", true},
+		{"surrounding whitespace", "model-a", "  Metformin lowers glucose.\n", true},
+		{"other case", "model-a", "metformin lowers glucose.", false},
+		{"other text", "model-a", "Metformin lowers weight.", false},
+		{"other model", "model-b", "Metformin lowers glucose.", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if same := EmbeddingCacheKey(test.model, test.text) == base; same != test.same {
+				t.Errorf("same key %v, want %v", same, test.same)
+			}
+		})
+	}
+}
+
+func TestCachedEmbedderCountsHitsAndMisses(t *testing.T) {
+	cache := NewMemoryEmbeddingCache()
+	model := &countingEmbedder{name: "model-a"}
+	embedder := NewCachedEmbedder(cache, model)
+
+	// A repeated text in one call is embedded once but counted as two misses
+	if _, err := embedder.Embed("first text", "second text", "first  text"); err != nil {
+		t.Fatal(err)
+	}
+	if got := embedder.Stats(); got != (CacheStats{Hits: 0, Misses: 3}) {
+		t.Errorf("after the first call: %+v", got)
+	}
+	if len(model.embedded) != 2 {
+		t.Errorf("embedded %q, want each distinct text once", model.embedded)
+	}
+
+	vectors, err := embedder.Embed("second text", "third text", "first text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := embedder.Stats(); got != (CacheStats{Hits: 2, Misses: 4}) {
+		t.Errorf("after the second call: %+v", got)
+	}
+	if strings.Join(model.embedded, "|") != "first text|second text|third text" {
+		t.Errorf("embedded %q, want only the new text sent again", model.embedded)
+	}
+	if vectors[0][0] != float32(len("second text")) || vectors[2][0] != float32(len("first text")) {
+		t.Errorf("vectors %v out of order", vectors)
+	}
+
+	// Another model sharing the cache misses on the same text
+	other := &countingEmbedder{name: "model-bb"}
+	vectors, err = NewCachedEmbedder(cache, other).Embed("first text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.embedded) != 1 || vectors[0][1] != float32(len("model-bb")) {
+		t.Errorf("model-bb got %v from the cache of model-a", vectors)
+	}
+}
+
+func TestLayeredEmbeddingCacheCopiesPersistentHits(t *testing.T) {
+	fast, persistent := NewMemoryEmbeddingCache(), NewMemoryEmbeddingCache()
+	if err := persistent.SetMany(map[string][]float32{"stored": {1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewLayeredEmbeddingCache(fast, persistent)
+
+	found, err := cache.GetMany([]string{"stored", "absent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found["stored"] == nil {
+		t.Fatalf("found %v, want only the stored key", found)
+	}
+	if copied, _ := fast.GetMany([]string{"stored"}); copied["stored"] == nil {
+		t.Error("persistent hit not copied to the fast cache")
+	}
+
+	if err := cache.SetMany(map[string][]float32{"new": {3}}); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := persistent.GetMany([]string{"new"}); stored["new"] == nil {
+		t.Error("new entry not written through to the persistent cache")
+	}
+}
@@ -1,12 +1,51 @@
 package utils
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"my-modus-app/src/schemas"
+
 	"github.com/hypermodeinc/modus/sdk/go/pkg/models"
+	"github.com/hypermodeinc/modus/sdk/go/pkg/models/experimental"
 	"github.com/hypermodeinc/modus/sdk/go/pkg/models/openai"
 )
 
-func GetEmbeddingsForTextsWithOpenAI(texts ...string) ([][]float32, error) {
-	model, err := models.GetModel[openai.EmbeddingsModel]("embeddings")
+// Embedding models as named in modus.json
+const (
+	EmbeddingsModel       = "embeddings" // text-embedding-004 through the OpenAI-compatible API
+	MiniLMModel           = "minilm"     // all-MiniLM-L6-v2 hosted on Hypermode
+	DefaultEmbeddingModel = EmbeddingsModel
+)
+
+// Embedder turns texts into vectors with one particular model
+type Embedder interface {
+	// ModelName is the model's name in modus.json, recorded on every chunk it embeds
+	ModelName() string
+	// Dimension is the length of the vectors the model returns
+	Dimension() int
+	// Embed returns one vector per text, in order
+	Embed(texts ...string) ([][]float32, error)
+}
+
+// OpenAIEmbedder calls a model served through an OpenAI-compatible embeddings API
+type OpenAIEmbedder struct {
+	modelName string
+	dimension int
+}
+
+func NewOpenAIEmbedder(modelName string, dimension int) *OpenAIEmbedder {
+	return &OpenAIEmbedder{modelName: modelName, dimension: dimension}
+}
+
+func (e *OpenAIEmbedder) ModelName() string { return e.modelName }
+func (e *OpenAIEmbedder) Dimension() int    { return e.dimension }
+
+func (e *OpenAIEmbedder) Embed(texts ...string) ([][]float32, error) {
+	model, err := models.GetModel[openai.EmbeddingsModel](e.modelName)
 	if err != nil {
 		return nil, err
 	}
@@ -20,13 +59,186 @@ func GetEmbeddingsForTextsWithOpenAI(texts ...string) ([][]float32, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(output.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(output.Data))
+	}
 
+	// Place each embedding by its index when the API numbers them all, otherwise keep the response order
 	results := make([][]float32, len(output.Data))
-	for i, d := range output.Data {
-		results[i] = d.Embedding
+	for _, d := range output.Data {
+		if d.Index < 0 || d.Index >= len(results) || results[d.Index] != nil {
+			for i, d := range output.Data {
+				results[i] = d.Embedding
+			}
+			break
+		}
+		results[d.Index] = d.Embedding
 	}
 
-	return results, nil
+	return results, checkDimension(e, results)
+}
+
+// HypermodeEmbedder calls a sentence-transformers model hosted on Hypermode, such as minilm
+type HypermodeEmbedder struct {
+	modelName string
+	dimension int
+}
+
+func NewHypermodeEmbedder(modelName string, dimension int) *HypermodeEmbedder {
+	return &HypermodeEmbedder{modelName: modelName, dimension: dimension}
+}
+
+func (e *HypermodeEmbedder) ModelName() string { return e.modelName }
+func (e *HypermodeEmbedder) Dimension() int    { return e.dimension }
+
+func (e *HypermodeEmbedder) Embed(texts ...string) ([][]float32, error) {
+	model, err := models.GetModel[experimental.EmbeddingsModel](e.modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := model.CreateInput(texts...)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := model.Invoke(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Predictions) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(output.Predictions))
+	}
+
+	return output.Predictions, checkDimension(e, output.Predictions)
+}
+
+// checkDimension rejects vectors whose length differs from the embedder's declared dimension
+func checkDimension(embedder Embedder, vectors [][]float32) error {
+	for _, vector := range vectors {
+		if len(vector) != embedder.Dimension() {
+			return fmt.Errorf("model %s returned a %d-dimensional embedding, expected %d", embedder.ModelName(), len(vector), embedder.Dimension())
+		}
+	}
+	return nil
+}
+
+var (
+	embeddersMu sync.Mutex
+	// embedders is the registry of embedding models by name
+	embedders = map[string]Embedder{
+		EmbeddingsModel: NewOpenAIEmbedder(EmbeddingsModel, 768),
+		MiniLMModel:     NewHypermodeEmbedder(MiniLMModel, 384),
+	}
+	// embeddingCache is shared by all models; keys include the model name
+	embeddingCache EmbeddingCache = NewMemoryEmbeddingCache()
+	// cachedEmbedders wraps each registered model with embeddingCache, built on first use
+	cachedEmbedders = map[string]*CachedEmbedder{}
+)
+
+// RegisterEmbedder adds or replaces an embedding model
+func RegisterEmbedder(embedder Embedder) {
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	embedders[embedder.ModelName()] = embedder
+	delete(cachedEmbedders, embedder.ModelName())
+}
+
+// AvailableEmbedders lists the registered embedding model names in sorted order
+func AvailableEmbedders() []string {
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	names := make([]string, 0, len(embedders))
+	for name := range embedders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetEmbedder returns the named model behind the embedding cache; an empty name uses DefaultEmbeddingModel
+func GetEmbedder(modelName string) (Embedder, error) {
+	if modelName == "" {
+		modelName = DefaultEmbeddingModel
+	}
+
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	if cached, ok := cachedEmbedders[modelName]; ok {
+		return cached, nil
+	}
+	embedder, ok := embedders[modelName]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding model %q, expected one of %s", modelName, strings.Join(sortedKeys(embedders), ", "))
+	}
+	cached := NewCachedEmbedder(embeddingCache, embedder)
+	cachedEmbedders[modelName] = cached
+	return cached, nil
+}
+
+func sortedKeys(m map[string]Embedder) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SetEmbeddingCache replaces the cache consulted by every embedder, resetting its statistics
+func SetEmbeddingCache(cache EmbeddingCache) {
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	embeddingCache = cache
+	cachedEmbedders = map[string]*CachedEmbedder{}
+}
+
+// EmbeddingCacheStats returns the hit and miss counts of the embedding cache across all models
+func EmbeddingCacheStats() CacheStats {
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	var total CacheStats
+	for _, cached := range cachedEmbedders {
+		stats := cached.Stats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+	}
+	return total
+}
+
+// CheckEmbeddingModel verifies that a query embedding can be compared with a stored chunk: both
+// must come from the same model and have the same dimension
+func CheckEmbeddingModel(queryModel string, queryEmbedding []float32, chunk schemas.TextChunk) error {
+	if chunk.Metadata.EmbeddingModel != "" && chunk.Metadata.EmbeddingModel != queryModel {
+		return fmt.Errorf("chunk %s was embedded with %s but the query with %s", chunk.ID, chunk.Metadata.EmbeddingModel, queryModel)
+	}
+	if len(chunk.Embedding) != len(queryEmbedding) {
+		return fmt.Errorf("chunk %s has a %d-dimensional embedding but the query has %d", chunk.ID, len(chunk.Embedding), len(queryEmbedding))
+	}
+	return nil
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors of the same length, or 0
+// when either is the zero vector
+func CosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func GetEmbeddingsForTextsWithOpenAI(texts ...string) ([][]float32, error) {
+	embedder, err := GetEmbedder(EmbeddingsModel)
+	if err != nil {
+		return nil, err
+	}
+	return embedder.Embed(texts...)
 }
 
 func GetEmbeddingsForTextWithOpenAI(text string) ([]float32, error) {
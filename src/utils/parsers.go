@@ -40,6 +40,7 @@ func ParseMedline(content string) (*schemas.MedlineArticle, error) {
 	article := &schemas.MedlineArticle{
 		Authors:          make([]schemas.Author, 0),
 		MeshTerms:        make([]string, 0),
+		Substances:       make([]string, 0),
 		PublicationTypes: make([]string, 0),
 	}
 
@@ -108,8 +109,17 @@ func processField(article *schemas.MedlineArticle, field, value string, currentA
 			lastIdx := len(article.Authors) - 1
 			article.Authors[lastIdx].Afiliation = value
 		}
+	case "AUID":
+		if len(article.Authors) > 0 {
+			article.Authors[len(article.Authors)-1].Identifier = value
+		}
 	case "MH":
 		article.MeshTerms = append(article.MeshTerms, value)
+	case "RN":
+		// Registry numbers look like "9100L32L2N (Metformin)"; keep the substance name
+		if start, end := strings.Index(value, "("), strings.LastIndex(value, ")"); start != -1 && end > start {
+			article.Substances = append(article.Substances, strings.TrimSpace(value[start+1:end]))
+		}
 	case "PT":
 		article.PublicationTypes = append(article.PublicationTypes, value)
 	case "LA":
@@ -0,0 +1,138 @@
+// Package utilstest provides offline embedding and chat models for tests
+package utilstest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"my-modus-app/src/utils"
+)
+
+// HashEmbedder is a deterministic, offline Embedder. Each lowercased word is hashed into a signed
+// bucket of the vector, which is then normalised, so texts sharing words have similar embeddings.
+type HashEmbedder struct {
+	modelName string
+	dimension int
+
+	mu    sync.Mutex
+	calls int // Number of Embed calls, to check batching and caching
+}
+
+func NewHashEmbedder(modelName string, dimension int) *HashEmbedder {
+	return &HashEmbedder{modelName: modelName, dimension: dimension}
+}
+
+func (e *HashEmbedder) ModelName() string { return e.modelName }
+func (e *HashEmbedder) Dimension() int    { return e.dimension }
+
+// Calls returns how many times Embed has been called
+func (e *HashEmbedder) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func (e *HashEmbedder) Embed(texts ...string) ([][]float32, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.dimension)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		bucket := int(sum % uint64(e.dimension))
+		if sum&(1<<63) != 0 {
+			vector[bucket]--
+		} else {
+			vector[bucket]++
+		}
+	}
+
+	// Normalise so cosine similarity and dot product agree; an empty text stays the zero vector
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for j := range vector {
+			vector[j] *= scale
+		}
+	}
+	return vector
+}
+
+// ScriptedChatModel is an offline ChatModel that returns canned responses and records every request
+type ScriptedChatModel struct {
+	modelName string
+	// Respond, when set, answers every request; otherwise Responses are returned in order
+	Respond   func(request utils.ChatRequest) (string, error)
+	Responses []string
+
+	mu       sync.Mutex
+	requests []utils.ChatRequest
+}
+
+func NewScriptedChatModel(modelName string, responses ...string) *ScriptedChatModel {
+	return &ScriptedChatModel{modelName: modelName, Responses: responses}
+}
+
+func (m *ScriptedChatModel) ModelName() string { return m.modelName }
+
+func (m *ScriptedChatModel) Complete(request utils.ChatRequest) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, request)
+
+	if m.Respond != nil {
+		return m.Respond(request)
+	}
+	if len(m.Responses) == 0 {
+		return "", fmt.Errorf("scripted model %s has no response left for request %d", m.modelName, len(m.requests))
+	}
+	response := m.Responses[0]
+	m.Responses = m.Responses[1:]
+	return response, nil
+}
+
+// Requests returns a copy of the requests received so far
+func (m *ScriptedChatModel) Requests() []utils.ChatRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]utils.ChatRequest(nil), m.requests...)
+}
+
+// UseFakeModels registers a HashEmbedder in place of every embedding model, with the same name and
+// dimension, and a ScriptedChatModel for each chat model name given. It returns the chat fakes by
+// name so their responses can be scripted.
+func UseFakeModels(chatModelNames ...string) map[string]*ScriptedChatModel {
+	for _, name := range utils.AvailableEmbedders() {
+		embedder, _ := utils.GetEmbedder(name)
+		utils.RegisterEmbedder(NewHashEmbedder(name, embedder.Dimension()))
+	}
+	utils.SetEmbeddingCache(utils.NewMemoryEmbeddingCache())
+
+	fakes := make(map[string]*ScriptedChatModel, len(chatModelNames))
+	for _, name := range chatModelNames {
+		fakes[name] = NewScriptedChatModel(name)
+		utils.RegisterChatModel(fakes[name])
+	}
+	return fakes
+}
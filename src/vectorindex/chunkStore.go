@@ -0,0 +1,247 @@
+package vectorindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"my-modus-app/src/processors"
+	"my-modus-app/src/schemas"
+)
+
+// chunkRecord is a stored chunk with the user and researches it belongs to
+type chunkRecord struct {
+	Chunk      schemas.TextChunk `json:"chunk"`
+	Researches []string          `json:"researches,omitempty"`
+}
+
+// LocalChunkStore keeps chunks in memory with their embeddings in an Index per embedding model and
+// their content in a BM25 index. It answers the same searches as the Dgraph store, so retrieval can
+// run without external services.
+type LocalChunkStore struct {
+	mu             sync.RWMutex
+	newIndex       func() Index            // Builds the index of a model's first chunk
+	indexes        map[string]Index        // Embeddings by model, as one index holds one dimension
+	lexical        *processors.BM25Index   // Content of every chunk, by chunkKey
+	chunks         map[string]*chunkRecord // By chunkKey
+	researchModels map[string]string       // Embedding model of each research, by user and research id
+	model          string                  // Model of the first embedded chunk, used outside a research
+}
+
+// NewLocalChunkStore returns an empty store searching the embeddings of each model through an
+// index built by newIndex
+func NewLocalChunkStore(newIndex func() Index) *LocalChunkStore {
+	return &LocalChunkStore{
+		newIndex:       newIndex,
+		indexes:        map[string]Index{},
+		lexical:        processors.NewBM25Index(),
+		chunks:         map[string]*chunkRecord{},
+		researchModels: map[string]string{},
+	}
+}
+
+// chunkKey identifies a chunk within the store, as chunk ids are only unique per user
+func chunkKey(userID, chunkID string) string {
+	return userID + "\x00" + chunkID
+}
+
+// AddChunks stores the user's chunks, under a research when researchID is not empty. Chunks
+// without an embedding, such as section-level parents, are kept for ChunksByID but not indexed.
+// As in Dgraph, the first embedded chunk of a research fixes its model.
+func (s *LocalChunkStore) AddChunks(userID, researchID string, chunks []schemas.TextChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	researchKey := chunkKey(userID, researchID)
+	for _, chunk := range chunks {
+		if chunk.ID == "" {
+			return fmt.Errorf("chunk without an id")
+		}
+		model := chunk.Metadata.EmbeddingModel
+		if len(chunk.Embedding) > 0 && researchID != "" {
+			if expected := s.researchModels[researchKey]; expected != "" && model != expected {
+				return fmt.Errorf("chunk %s was embedded with %s, research %s uses %s", chunk.ID, model, researchID, expected)
+			}
+		}
+
+		chunk.UserID = userID
+		key := chunkKey(userID, chunk.ID)
+		record, ok := s.chunks[key]
+		if !ok {
+			record = &chunkRecord{}
+			s.chunks[key] = record
+		} else if index := s.indexes[record.Chunk.Metadata.EmbeddingModel]; index != nil {
+			// The chunk may have been embedded with another model before
+			index.Remove(key)
+		}
+		record.Chunk = chunk
+		if researchID != "" && !contains(record.Researches, researchID) {
+			record.Researches = append(record.Researches, researchID)
+		}
+
+		s.lexical.Add(key, chunk.Content)
+		if len(chunk.Embedding) == 0 {
+			continue
+		}
+		index, ok := s.indexes[model]
+		if !ok {
+			index = s.newIndex()
+			s.indexes[model] = index
+		}
+		if err := index.Add(key, chunk.Embedding); err != nil {
+			return fmt.Errorf("error indexing chunk %s: %w", chunk.ID, err)
+		}
+		if researchID != "" && s.researchModels[researchKey] == "" {
+			s.researchModels[researchKey] = model
+		}
+		if s.model == "" {
+			s.model = model
+		}
+	}
+	return nil
+}
+
+// SearchChunks returns up to k of the user's chunks nearest to vector, best first, among those
+// embedded with the model EmbeddingModel returns. A non-empty researchID limits the search to the
+// chunks of that research.
+func (s *LocalChunkStore) SearchChunks(userID, researchID string, vector []float32, k int) ([]schemas.TextChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index := s.indexes[s.embeddingModel(userID, researchID)]
+	if index == nil {
+		return nil, nil
+	}
+	results := index.Search(vector, k, s.scopeFilter(userID, researchID))
+
+	chunks := make([]schemas.TextChunk, 0, len(results))
+	for _, result := range results {
+		chunks = append(chunks, s.chunks[result.ID].Chunk)
+	}
+	return chunks, nil
+}
+
+// LexicalSearch returns up to k of the user's chunks matching words of the query, best first with
+// their BM25 score. A non-empty researchID limits the search to the chunks of that research.
+func (s *LocalChunkStore) LexicalSearch(userID, researchID, query string, k int) ([]schemas.TextChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := s.lexical.Search(query, k, s.scopeFilter(userID, researchID))
+	chunks := make([]schemas.TextChunk, 0, len(results))
+	for _, result := range results {
+		chunk := s.chunks[result.ID].Chunk
+		chunk.Score = result.Score
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// scopeFilter accepts the keys of the user's chunks, only those of the research when one is given
+func (s *LocalChunkStore) scopeFilter(userID, researchID string) func(key string) bool {
+	return func(key string) bool {
+		record := s.chunks[key]
+		return record != nil && record.Chunk.UserID == userID &&
+			(researchID == "" || contains(record.Researches, researchID))
+	}
+}
+
+// ChunksByID returns the user's chunks with the given ids, skipping unknown ones
+func (s *LocalChunkStore) ChunksByID(userID string, ids []string) ([]schemas.TextChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chunks []schemas.TextChunk
+	for _, id := range ids {
+		if record, ok := s.chunks[chunkKey(userID, id)]; ok {
+			chunks = append(chunks, record.Chunk)
+		}
+	}
+	return chunks, nil
+}
+
+// EmbeddingModel returns the research's model, or the store's when the research has none yet
+func (s *LocalChunkStore) EmbeddingModel(userID, researchID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.embeddingModel(userID, researchID), nil
+}
+
+func (s *LocalChunkStore) embeddingModel(userID, researchID string) string {
+	if model := s.researchModels[chunkKey(userID, researchID)]; researchID != "" && model != "" {
+		return model
+	}
+	return s.model
+}
+
+// storeSnapshot is the saved form of a LocalChunkStore
+type storeSnapshot struct {
+	Chunks         []*chunkRecord           `json:"chunks"`
+	ResearchModels map[string]string        `json:"research_models"`
+	Model          string                   `json:"model"`
+	Indexes        map[string]indexSnapshot `json:"indexes"`
+}
+
+// Save writes the store, with its index, as JSON; like the index Save, it leaves the destination
+// to the caller as the WASM host has no filesystem
+func (s *LocalChunkStore) Save(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := storeSnapshot{ResearchModels: s.researchModels, Model: s.model, Indexes: map[string]indexSnapshot{}}
+	for model, index := range s.indexes {
+		snapshot.Indexes[model] = index.snapshot()
+	}
+	for _, record := range s.chunks {
+		snapshot.Chunks = append(snapshot.Chunks, record)
+	}
+	// Write chunks in a stable order so saving the same store twice gives the same file
+	sort.Slice(snapshot.Chunks, func(i, j int) bool {
+		a, b := snapshot.Chunks[i].Chunk, snapshot.Chunks[j].Chunk
+		return chunkKey(a.UserID, a.ID) < chunkKey(b.UserID, b.ID)
+	})
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		return fmt.Errorf("error writing the chunk store: %w", err)
+	}
+	return nil
+}
+
+// LoadChunkStore reads a store written by Save. Saved indexes keep the kind they were saved with;
+// newIndex builds those of models added later.
+func LoadChunkStore(r io.Reader, newIndex func() Index) (*LocalChunkStore, error) {
+	var snapshot storeSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error reading the chunk store: %w", err)
+	}
+
+	store := NewLocalChunkStore(newIndex)
+	for model, saved := range snapshot.Indexes {
+		index, err := saved.restore()
+		if err != nil {
+			return nil, fmt.Errorf("error restoring the %s index: %w", model, err)
+		}
+		store.indexes[model] = index
+	}
+	store.model = snapshot.Model
+	for key, model := range snapshot.ResearchModels {
+		store.researchModels[key] = model
+	}
+	// The BM25 index is rebuilt from the saved content rather than saved itself
+	for _, record := range snapshot.Chunks {
+		key := chunkKey(record.Chunk.UserID, record.Chunk.ID)
+		store.chunks[key] = record
+		store.lexical.Add(key, record.Chunk.Content)
+	}
+	return store, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package vectorindex
+
+import (
+	"bytes"
+	"testing"
+
+	"my-modus-app/src/schemas"
+)
+
+func newHNSWIndex() Index       { return NewHNSWIndex(DefaultHNSWConfig()) }
+func newBruteForceIndex() Index { return NewBruteForceIndex() }
+
+func testChunk(id, content string, embedding ...float32) schemas.TextChunk {
+	return schemas.TextChunk{ID: id, Content: content, Embedding: embedding, Metadata: schemas.ChunkMetadata{EmbeddingModel: "model-a"}}
+}
+
+func TestLocalChunkStoreScopesSearches(t *testing.T) {
+	store := NewLocalChunkStore(newHNSWIndex)
+	if err := store.AddChunks("alice", "r1", []schemas.TextChunk{
+		testChunk("a1", "metformin lowers glucose", 1, 0, 0),
+		testChunk("a2", "exercise after knee surgery", 0, 1, 0),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddChunks("alice", "r2", []schemas.TextChunk{testChunk("a3", "metformin and weight", 0.9, 0.1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddChunks("bob", "", []schemas.TextChunk{testChunk("a1", "metformin for bob", 1, 0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		research   string
+		wantVector []string
+		wantText   []string
+	}{
+		{"all of alice", "alice", "", []string{"a1", "a3", "a2"}, []string{"a1", "a3"}},
+		{"one research", "alice", "r2", []string{"a3"}, []string{"a3"}},
+		{"other user", "bob", "", []string{"a1"}, []string{"a1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vector, err := store.SearchChunks(test.user, test.research, []float32{1, 0, 0}, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chunkIDs(vector); !equal(got, test.wantVector) {
+				t.Errorf("vector search got %v, want %v", got, test.wantVector)
+			}
+			lexical, err := store.LexicalSearch(test.user, test.research, "metformin", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chunkIDs(lexical); len(got) != len(test.wantText) {
+				t.Errorf("lexical search got %v, want %v", got, test.wantText)
+			}
+			for _, chunk := range append(vector, lexical...) {
+				if chunk.UserID != test.user {
+					t.Errorf("search for %s returned %s's chunk", test.user, chunk.UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalChunkStoreRejectsAnotherModelInAResearch(t *testing.T) {
+	store := NewLocalChunkStore(newBruteForceIndex)
+	if err := store.AddChunks("alice", "r1", []schemas.TextChunk{testChunk("a1", "text", 1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	other := testChunk("a2", "text", 0, 1)
+	other.Metadata.EmbeddingModel = "model-b"
+	if err := store.AddChunks("alice", "r1", []schemas.TextChunk{other}); err == nil {
+		t.Error("expected an error adding a chunk of another model to the research")
+	}
+	if model, _ := store.EmbeddingModel("alice", "r1"); model != "model-a" {
+		t.Errorf("research model %q, want model-a", model)
+	}
+}
+
+func TestLocalChunkStoreIndexesEachModelApart(t *testing.T) {
+	store := NewLocalChunkStore(newHNSWIndex)
+	if err := store.AddChunks("alice", "r1", []schemas.TextChunk{testChunk("a1", "metformin", 1, 0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	small := testChunk("b1", "metformin", 0, 1)
+	small.Metadata.EmbeddingModel = "model-b"
+	if err := store.AddChunks("alice", "r2", []schemas.TextChunk{small}); err != nil {
+		t.Fatalf("a research of another model and dimension was rejected: %v", err)
+	}
+
+	for research, query := range map[string][]float32{"r1": {1, 0, 0}, "r2": {0, 1}} {
+		found, err := store.SearchChunks("alice", research, query, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 {
+			t.Errorf("research %s found %v, want its one chunk", research, chunkIDs(found))
+		}
+	}
+
+	// Embedding a chunk again with the other model moves it to that model's index
+	moved := testChunk("a1", "metformin", 0, 1)
+	moved.Metadata.EmbeddingModel = "model-b"
+	if err := store.AddChunks("alice", "r2", []schemas.TextChunk{moved}); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.SearchChunks("alice", "", []float32{1, 0, 0}, 5); len(found) != 0 {
+		t.Errorf("model-a index still holds %v", chunkIDs(found))
+	}
+}
+
+func TestLocalChunkStoreSaveAndLoad(t *testing.T) {
+	store := NewLocalChunkStore(newBruteForceIndex)
+	if err := store.AddChunks("alice", "r1", []schemas.TextChunk{
+		testChunk("a1", "metformin lowers glucose", 1, 0),
+		testChunk("a2", "exercise after knee surgery", 0, 1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved bytes.Buffer
+	if err := store.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadChunkStore(&saved, newBruteForceIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vector, _ := loaded.SearchChunks("alice", "r1", []float32{0, 1}, 1)
+	lexical, _ := loaded.LexicalSearch("alice", "r1", "metformin", 1)
+	if got := chunkIDs(append(vector, lexical...)); !equal(got, []string{"a2", "a1"}) {
+		t.Errorf("loaded store found %v, want [a2 a1]", got)
+	}
+}
+
+func chunkIDs(chunks []schemas.TextChunk) []string {
+	ids := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		ids[i] = chunk.ID
+	}
+	return ids
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,321 @@
+package vectorindex
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSWConfig tunes the hierarchical navigable small world graph
+type HNSWConfig struct {
+	M              int   `json:"m"`               // Links per node on the upper layers; layer 0 allows twice as many
+	EfConstruction int   `json:"ef_construction"` // Candidates considered while linking a new node
+	EfSearch       int   `json:"ef_search"`       // Candidates considered per search, at least k
+	Seed           int64 `json:"seed"`            // Seed for the level of each node, so builds are reproducible
+}
+
+// DefaultHNSWConfig returns the settings used when a field is zero
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{M: 16, EfConstruction: 200, EfSearch: 64, Seed: 1}
+}
+
+// WithDefaults fills zero fields from DefaultHNSWConfig
+func (c HNSWConfig) WithDefaults() HNSWConfig {
+	defaults := DefaultHNSWConfig()
+	if c.M <= 1 {
+		c.M = defaults.M
+	}
+	if c.EfConstruction <= 0 {
+		c.EfConstruction = defaults.EfConstruction
+	}
+	if c.EfSearch <= 0 {
+		c.EfSearch = defaults.EfSearch
+	}
+	if c.Seed == 0 {
+		c.Seed = defaults.Seed
+	}
+	return c
+}
+
+// hnswNode is one vector and its links on every layer up to its level
+type hnswNode struct {
+	ID        string    `json:"id"`
+	Vector    []float32 `json:"vector"`
+	Neighbors [][]int   `json:"neighbors"` // Node positions per layer, from layer 0 up
+	Deleted   bool      `json:"deleted"`   // Removed or replaced; still routes searches but is never returned
+}
+
+// HNSWIndex is an approximate nearest-neighbour index. Removal marks nodes deleted rather than
+// relinking the graph, so heavy churn is best followed by a rebuild.
+type HNSWIndex struct {
+	mu        sync.RWMutex
+	config    HNSWConfig
+	dimension int
+	nodes     []hnswNode
+	positions map[string]int // Live node of each id
+	entry     int            // Entry point on the top layer, -1 when empty
+	rng       *rand.Rand
+}
+
+func NewHNSWIndex(config HNSWConfig) *HNSWIndex {
+	config = config.WithDefaults()
+	return &HNSWIndex{
+		config:    config,
+		positions: map[string]int{},
+		entry:     -1,
+		rng:       rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+func (h *HNSWIndex) Kind() string { return KindHNSW }
+
+func (h *HNSWIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.positions)
+}
+
+// distance is the cosine distance between a unit query and a node
+func (h *HNSWIndex) distance(query []float32, node int) float64 {
+	return 1 - dot(query, h.nodes[node].Vector)
+}
+
+// maxLinks is how many neighbours a node keeps on a layer
+func (h *HNSWIndex) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * h.config.M
+	}
+	return h.config.M
+}
+
+// randomLevel draws a node's top layer from an exponential distribution
+func (h *HNSWIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) / math.Log(float64(h.config.M))))
+}
+
+func (h *HNSWIndex) topLevel() int {
+	return len(h.nodes[h.entry].Neighbors) - 1
+}
+
+func (h *HNSWIndex) Add(id string, vector []float32) error {
+	normalized, err := normalize(vector)
+	if err != nil {
+		return fmt.Errorf("vector %s: %w", id, err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.dimension == 0 {
+		h.dimension = len(normalized)
+	} else if len(normalized) != h.dimension {
+		return fmt.Errorf("vector %s has dimension %d, the index %d", id, len(normalized), h.dimension)
+	}
+
+	// A replaced vector stays in the graph as a deleted router
+	if old, ok := h.positions[id]; ok {
+		h.nodes[old].Deleted = true
+	}
+
+	level := h.randomLevel()
+	position := len(h.nodes)
+	h.nodes = append(h.nodes, hnswNode{ID: id, Vector: normalized, Neighbors: make([][]int, level+1)})
+	h.positions[id] = position
+	if h.entry == -1 {
+		h.entry = position
+		return nil
+	}
+
+	// Descend greedily to the node's top layer, then link it on every layer below
+	entry := h.entry
+	top := h.topLevel()
+	for layer := top; layer > level; layer-- {
+		entry = h.searchLayer(normalized, []int{entry}, 1, layer)[0].node
+	}
+	entries := []int{entry}
+	for layer := min(level, top); layer >= 0; layer-- {
+		candidates := h.searchLayer(normalized, entries, h.config.EfConstruction, layer)
+		neighbors := h.selectNeighbors(candidates, h.config.M)
+		h.nodes[position].Neighbors[layer] = neighbors
+		for _, neighbor := range neighbors {
+			h.link(neighbor, position, layer)
+		}
+
+		entries = entries[:0]
+		for _, candidate := range candidates {
+			entries = append(entries, candidate.node)
+		}
+	}
+
+	if level > top {
+		h.entry = position
+	}
+	return nil
+}
+
+// link adds target to the node's neighbours on a layer, pruning them again when over the limit
+func (h *HNSWIndex) link(node, target, layer int) {
+	neighbors := append(h.nodes[node].Neighbors[layer], target)
+	if len(neighbors) > h.maxLinks(layer) {
+		queue := make(candidateQueue, 0, len(neighbors))
+		for _, neighbor := range neighbors {
+			queue = append(queue, candidate{neighbor, 1 - dot(h.nodes[node].Vector, h.nodes[neighbor].Vector)})
+		}
+		queue.sortNearest()
+		neighbors = h.selectNeighbors(queue, h.maxLinks(layer))
+	}
+	h.nodes[node].Neighbors[layer] = neighbors
+}
+
+// selectNeighbors picks up to m of the candidates, sorted nearest first. A candidate closer to an
+// already picked neighbour than to the node is skipped, so links spread out instead of all pointing
+// into the nearest cluster and leaving clusters unreachable from each other. Skipped candidates
+// fill any remaining places.
+func (h *HNSWIndex) selectNeighbors(candidates candidateQueue, m int) []int {
+	selected := make([]int, 0, m)
+	var skipped []int
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, picked := range selected {
+			if 1-dot(h.nodes[c.node].Vector, h.nodes[picked].Vector) < c.distance {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, node := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, node)
+	}
+	return selected
+}
+
+func (h *HNSWIndex) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if position, ok := h.positions[id]; ok {
+		h.nodes[position].Deleted = true
+		delete(h.positions, id)
+	}
+}
+
+func (h *HNSWIndex) Search(query []float32, k int, filter func(id string) bool) []Result {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	normalized, err := normalize(query)
+	if err != nil || k <= 0 || h.entry == -1 || len(normalized) != h.dimension {
+		return nil
+	}
+
+	entry := h.entry
+	for layer := h.topLevel(); layer > 0; layer-- {
+		entry = h.searchLayer(normalized, []int{entry}, 1, layer)[0].node
+	}
+
+	// Widen the search until enough candidates pass the filter or every node has been seen
+	for ef := max(h.config.EfSearch, k); ; ef *= 2 {
+		var results []Result
+		for _, found := range h.searchLayer(normalized, []int{entry}, ef, 0) {
+			node := h.nodes[found.node]
+			if node.Deleted || (filter != nil && !filter(node.ID)) {
+				continue
+			}
+			results = append(results, Result{ID: node.ID, Score: 1 - found.distance})
+		}
+		if len(results) >= k || ef >= len(h.nodes) {
+			sortResults(results)
+			return results[:min(k, len(results))]
+		}
+	}
+}
+
+// searchLayer returns up to ef nodes nearest to the query on one layer, nearest first
+func (h *HNSWIndex) searchLayer(query []float32, entries []int, ef, layer int) candidateQueue {
+	visited := make(map[int]bool, ef*4)
+	var nearest candidateQueue       // Min-heap of nodes still to expand
+	furthest := &maxCandidateQueue{} // Max-heap of the best ef found so far
+	for _, entry := range entries {
+		if visited[entry] {
+			continue
+		}
+		visited[entry] = true
+		c := candidate{entry, h.distance(query, entry)}
+		heap.Push(&nearest, c)
+		heap.Push(furthest, c)
+	}
+
+	for nearest.Len() > 0 {
+		current := heap.Pop(&nearest).(candidate)
+		if furthest.Len() >= ef && current.distance > furthest.candidateQueue[0].distance {
+			break
+		}
+		if layer >= len(h.nodes[current.node].Neighbors) {
+			continue
+		}
+		for _, neighbor := range h.nodes[current.node].Neighbors[layer] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			c := candidate{neighbor, h.distance(query, neighbor)}
+			if furthest.Len() < ef || c.distance < furthest.candidateQueue[0].distance {
+				heap.Push(&nearest, c)
+				heap.Push(furthest, c)
+				if furthest.Len() > ef {
+					heap.Pop(furthest)
+				}
+			}
+		}
+	}
+
+	results := furthest.candidateQueue
+	results.sortNearest()
+	return results
+}
+
+// candidate is a node and its distance to the query
+type candidate struct {
+	node     int
+	distance float64
+}
+
+// candidateQueue is a min-heap on distance
+type candidateQueue []candidate
+
+func (q candidateQueue) Len() int { return len(q) }
+func (q candidateQueue) Less(i, j int) bool {
+	if q[i].distance != q[j].distance {
+		return q[i].distance < q[j].distance
+	}
+	return q[i].node < q[j].node
+}
+func (q candidateQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *candidateQueue) Push(x any)   { *q = append(*q, x.(candidate)) }
+func (q *candidateQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// sortNearest sorts the queue nearest first
+func (q candidateQueue) sortNearest() {
+	sort.Slice(q, q.Less)
+}
+
+// maxCandidateQueue is a max-heap on distance
+type maxCandidateQueue struct{ candidateQueue }
+
+func (q maxCandidateQueue) Less(i, j int) bool { return q.candidateQueue.Less(j, i) }
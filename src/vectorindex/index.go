@@ -0,0 +1,152 @@
+package vectorindex
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Index kinds, as recorded in a saved index
+const (
+	KindBruteForce = "brute_force"
+	KindHNSW       = "hnsw"
+)
+
+// Result is one nearest neighbour with its cosine similarity to the query
+type Result struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// Index finds the vectors nearest to a query by cosine similarity
+type Index interface {
+	// Kind names the implementation, one of the Kind constants
+	Kind() string
+	// Add inserts a vector, replacing any earlier vector with the same id
+	Add(id string, vector []float32) error
+	// Remove drops a vector; removing an unknown id does nothing
+	Remove(id string)
+	// Search returns up to k results, best first. A non-nil filter keeps only the ids it accepts.
+	Search(query []float32, k int, filter func(id string) bool) []Result
+	// Len is the number of vectors in the index
+	Len() int
+
+	snapshot() indexSnapshot
+}
+
+// normalize returns a unit-length copy of vector, so cosine similarity is a dot product
+func normalize(vector []float32) ([]float32, error) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("cannot index a zero vector")
+	}
+	scale := 1 / math.Sqrt(norm)
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = float32(float64(v) * scale)
+	}
+	return normalized, nil
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// sortResults orders results best first, by id on equal scores so the order is deterministic
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+}
+
+// BruteForceIndex compares the query with every vector. It is exact, and the reference the HNSW
+// index's recall is measured against.
+type BruteForceIndex struct {
+	mu        sync.RWMutex
+	dimension int
+	ids       []string
+	vectors   [][]float32
+	positions map[string]int
+}
+
+func NewBruteForceIndex() *BruteForceIndex {
+	return &BruteForceIndex{positions: map[string]int{}}
+}
+
+func (b *BruteForceIndex) Kind() string { return KindBruteForce }
+
+func (b *BruteForceIndex) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.ids)
+}
+
+func (b *BruteForceIndex) Add(id string, vector []float32) error {
+	normalized, err := normalize(vector)
+	if err != nil {
+		return fmt.Errorf("vector %s: %w", id, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dimension == 0 {
+		b.dimension = len(normalized)
+	} else if len(normalized) != b.dimension {
+		return fmt.Errorf("vector %s has dimension %d, the index %d", id, len(normalized), b.dimension)
+	}
+
+	if position, ok := b.positions[id]; ok {
+		b.vectors[position] = normalized
+		return nil
+	}
+	b.positions[id] = len(b.ids)
+	b.ids = append(b.ids, id)
+	b.vectors = append(b.vectors, normalized)
+	return nil
+}
+
+func (b *BruteForceIndex) Remove(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	position, ok := b.positions[id]
+	if !ok {
+		return
+	}
+
+	// Move the last vector into the freed slot
+	last := len(b.ids) - 1
+	b.ids[position], b.vectors[position] = b.ids[last], b.vectors[last]
+	b.positions[b.ids[position]] = position
+	b.ids, b.vectors = b.ids[:last], b.vectors[:last]
+	delete(b.positions, id)
+}
+
+func (b *BruteForceIndex) Search(query []float32, k int, filter func(id string) bool) []Result {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	normalized, err := normalize(query)
+	if err != nil || k <= 0 || len(normalized) != b.dimension {
+		return nil
+	}
+
+	results := make([]Result, 0, len(b.ids))
+	for i, id := range b.ids {
+		if filter != nil && !filter(id) {
+			continue
+		}
+		results = append(results, Result{ID: id, Score: dot(normalized, b.vectors[i])})
+	}
+	sortResults(results)
+	return results[:min(k, len(results))]
+}
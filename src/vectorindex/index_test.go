@@ -0,0 +1,135 @@
+package vectorindex
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// clusteredVectors draws n Gaussian vectors around a few centres, like topics in real embeddings
+func clusteredVectors(rng *rand.Rand, n, dimension, clusters int) [][]float32 {
+	centres := make([][]float32, clusters)
+	for i := range centres {
+		centres[i] = randomVector(rng, dimension, nil, 1)
+	}
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = randomVector(rng, dimension, centres[rng.Intn(clusters)], 0.3)
+	}
+	return vectors
+}
+
+// randomVector draws a Gaussian vector, around centre when one is given
+func randomVector(rng *rand.Rand, dimension int, centre []float32, spread float64) []float32 {
+	vector := make([]float32, dimension)
+	for i := range vector {
+		value := rng.NormFloat64() * spread
+		if centre != nil {
+			value += float64(centre[i])
+		}
+		vector[i] = float32(value)
+	}
+	return vector
+}
+
+// buildIndex adds the vectors to index as v0, v1, ...
+func buildIndex(tb testing.TB, index Index, vectors [][]float32) Index {
+	tb.Helper()
+	for i, vector := range vectors {
+		if err := index.Add(fmt.Sprintf("v%d", i), vector); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return index
+}
+
+func TestHNSWRecallAgainstBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := clusteredVectors(rng, 2000, 64, 20)
+	queries := clusteredVectors(rng, 50, 64, 20)
+
+	exact := buildIndex(t, NewBruteForceIndex(), vectors)
+	approximate := buildIndex(t, NewHNSWIndex(DefaultHNSWConfig()), vectors)
+
+	var hits, total int
+	for _, query := range queries {
+		found := map[string]bool{}
+		for _, result := range approximate.Search(query, 10, nil) {
+			found[result.ID] = true
+		}
+		for _, result := range exact.Search(query, 10, nil) {
+			total++
+			if found[result.ID] {
+				hits++
+			}
+		}
+	}
+	if recall := float64(hits) / float64(total); recall < 0.9 {
+		t.Errorf("HNSW recall %.2f, want at least 0.90", recall)
+	}
+}
+
+func TestSaveAndLoadKeepSearchResults(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vectors := clusteredVectors(rng, 300, 16, 5)
+	query := vectors[7]
+
+	for _, index := range []Index{NewBruteForceIndex(), NewHNSWIndex(DefaultHNSWConfig())} {
+		t.Run(index.Kind(), func(t *testing.T) {
+			buildIndex(t, index, vectors)
+			index.Remove("v3")
+
+			var saved bytes.Buffer
+			if err := Save(index, &saved); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Load(&saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded.Kind() != index.Kind() || loaded.Len() != index.Len() {
+				t.Fatalf("loaded a %s index of %d, saved a %s index of %d", loaded.Kind(), loaded.Len(), index.Kind(), index.Len())
+			}
+			want, got := index.Search(query, 5, nil), loaded.Search(query, 5, nil)
+			for i := range want {
+				if got[i].ID != want[i].ID {
+					t.Errorf("result %d is %s after loading, %s before", i, got[i].ID, want[i].ID)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkSearch times top-10 queries against an index of 10000 384-dimensional vectors
+func benchmarkSearch(b *testing.B, index Index) {
+	rng := rand.New(rand.NewSource(1))
+	buildIndex(b, index, clusteredVectors(rng, 10000, 384, 50))
+	queries := clusteredVectors(rng, 200, 384, 50)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Search(queries[i%len(queries)], 10, nil)
+	}
+}
+
+func BenchmarkBruteForceSearch(b *testing.B) {
+	benchmarkSearch(b, NewBruteForceIndex())
+}
+
+func BenchmarkHNSWSearch(b *testing.B) {
+	benchmarkSearch(b, NewHNSWIndex(DefaultHNSWConfig()))
+}
+
+func BenchmarkHNSWAdd(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := clusteredVectors(rng, b.N, 384, 50)
+	index := NewHNSWIndex(DefaultHNSWConfig())
+
+	b.ResetTimer()
+	for i, vector := range vectors {
+		if err := index.Add(fmt.Sprintf("v%d", i), vector); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
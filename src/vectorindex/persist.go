@@ -0,0 +1,97 @@
+package vectorindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// indexSnapshot is the saved form of either index; only the fields of its kind are set
+type indexSnapshot struct {
+	Kind      string      `json:"kind"`
+	Dimension int         `json:"dimension"`
+	IDs       []string    `json:"ids,omitempty"`
+	Vectors   [][]float32 `json:"vectors,omitempty"`
+	Config    *HNSWConfig `json:"config,omitempty"`
+	Nodes     []hnswNode  `json:"nodes,omitempty"`
+	Entry     int         `json:"entry"`
+}
+
+func (b *BruteForceIndex) snapshot() indexSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return indexSnapshot{Kind: KindBruteForce, Dimension: b.dimension, IDs: b.ids, Vectors: b.vectors, Entry: -1}
+}
+
+func (h *HNSWIndex) snapshot() indexSnapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	config := h.config
+	return indexSnapshot{Kind: KindHNSW, Dimension: h.dimension, Config: &config, Nodes: h.nodes, Entry: h.entry}
+}
+
+// restore rebuilds an index from its snapshot
+func (s indexSnapshot) restore() (Index, error) {
+	switch s.Kind {
+	case KindBruteForce:
+		if len(s.IDs) != len(s.Vectors) {
+			return nil, fmt.Errorf("brute force index has %d ids for %d vectors", len(s.IDs), len(s.Vectors))
+		}
+		index := NewBruteForceIndex()
+		index.dimension, index.ids, index.vectors = s.Dimension, s.IDs, s.Vectors
+		for i, id := range s.IDs {
+			index.positions[id] = i
+		}
+		return index, nil
+
+	case KindHNSW:
+		config := DefaultHNSWConfig()
+		if s.Config != nil {
+			config = s.Config.WithDefaults()
+		}
+		index := NewHNSWIndex(config)
+		if s.Entry < -1 || s.Entry >= len(s.Nodes) || (s.Entry == -1) != (len(s.Nodes) == 0) {
+			return nil, fmt.Errorf("hnsw index has entry %d for %d nodes", s.Entry, len(s.Nodes))
+		}
+		for _, node := range s.Nodes {
+			for _, layer := range node.Neighbors {
+				for _, neighbor := range layer {
+					if neighbor < 0 || neighbor >= len(s.Nodes) {
+						return nil, fmt.Errorf("hnsw node %s links to missing node %d", node.ID, neighbor)
+					}
+				}
+			}
+		}
+		index.dimension, index.nodes, index.entry = s.Dimension, s.Nodes, s.Entry
+		for i, node := range s.Nodes {
+			if !node.Deleted {
+				index.positions[node.ID] = i
+			}
+		}
+		// Continue the level sequence where the saved build left off rather than repeating it
+		index.rng = rand.New(rand.NewSource(config.Seed + int64(len(s.Nodes))))
+		return index, nil
+
+	default:
+		return nil, fmt.Errorf("unknown index kind %q", s.Kind)
+	}
+}
+
+// Save writes the index as JSON. The Modus WASM host has no usable filesystem, so the caller
+// chooses where the writer goes.
+func Save(index Index, w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(index.snapshot()); err != nil {
+		return fmt.Errorf("error writing the %s index: %w", index.Kind(), err)
+	}
+	return nil
+}
+
+// Load reads an index written by Save
+func Load(r io.Reader) (Index, error) {
+	var snapshot indexSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error reading the index: %w", err)
+	}
+	return snapshot.restore()
+}